@@ -0,0 +1,53 @@
+package app
+
+import (
+	"os"
+
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keysDir       string
+	keysAlgorithm string
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage token signing keys",
+}
+
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a new signing key",
+	Long: `Generate a new signing key in the keys directory. Unless AUTH_ACTIVE_KEY_ID
+is set, the newest key becomes the active signing key on the next start while
+older keys keep verifying tokens until they are listed in AUTH_RETIRED_KEY_IDS
+or removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := auth.GenerateKey(keysAlgorithm)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to generate key")
+		}
+
+		path, err := auth.WriteKey(keysDir, key)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to write key")
+		}
+
+		log.Info().Str("kid", key.ID).Str("alg", key.Algorithm).Str("path", path).Msg("Generated signing key")
+	},
+}
+
+func init() {
+	defaultDir := os.Getenv("AUTH_KEYS_DIR")
+	if defaultDir == "" {
+		defaultDir = auth.DefaultKeysDir
+	}
+
+	keysGenerateCmd.Flags().StringVar(&keysDir, "dir", defaultDir, "directory to write the key to")
+	keysGenerateCmd.Flags().StringVar(&keysAlgorithm, "alg", auth.AlgES256, "signing algorithm (ES256, ES384, ES512 or EdDSA)")
+	keysCmd.AddCommand(keysGenerateCmd)
+	rootCmd.AddCommand(keysCmd)
+}
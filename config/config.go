@@ -3,6 +3,8 @@ package config
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/netf/gofiber-boilerplate/internal/errors"
 	"github.com/rs/zerolog/log"
@@ -15,13 +17,64 @@ var (
 )
 
 type Config struct {
-	ServerAddress  string
-	DatabaseURL    string
-	LogLevel       string
-	SentryDSN      string
-	Environment    string
-	AuthPrivateKey string
-	AuthSalt       string
+	ServerAddress          string
+	DatabaseURL            string
+	LogLevel               string
+	SentryDSN              string
+	Environment            string
+	AuthPrivateKey         string
+	AuthSalt               string
+	PasswordHashAlgorithm  string
+	PasswordMinLength      int
+	PasswordRequireUpper   bool
+	PasswordRequireLower   bool
+	PasswordRequireDigit   bool
+	PasswordRequireSymbol  bool
+	PasswordRejectUserInfo bool
+	PasswordHistorySize    int
+	BreachedPasswordsFile  string
+	AccessTokenTTL         time.Duration
+	RefreshTokenTTL        time.Duration
+	RevocationSyncInterval time.Duration
+	MFAIssuer              string
+	MFATokenTTL            time.Duration
+	AppURL                 string
+	MailerDriver           string
+	MailFrom               string
+	MailDir                string
+	SMTPHost               string
+	SMTPPort               int
+	SMTPUsername           string
+	SMTPPassword           string
+	EmailVerificationTTL   time.Duration
+	PasswordResetTTL       time.Duration
+	RequireEmailVerified   bool
+	LoginAccountMaxFails   int
+	LoginIPMaxFails        int
+	LoginFailureWindow     time.Duration
+	LoginLockoutBase       time.Duration
+	LoginLockoutMax        time.Duration
+	OIDCProviders          []OIDCProvider
+	OIDCStateTTL           time.Duration
+	OAuthCodeTTL           time.Duration
+	OrganizationInviteTTL  time.Duration
+	ImpersonationTTL       time.Duration
+	AuthCookies            bool
+	AuthCookieSecure       bool
+	AuthCookieSameSite     string
+	AuthCookieDomain       string
+	TodoMaxDepth           int
+}
+
+// OIDCProvider is the registration of this application with an external
+// login provider. Issuer is the discovery URL of OpenID Connect providers and
+// is not used for GitHub.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 func LoadConfig() (*Config, error) {
@@ -33,6 +86,36 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("SERVER_ADDRESS", ":8080")
 	viper.SetDefault("LOG_LEVEL", "info")
 	viper.SetDefault("ENVIRONMENT", "dev")
+	viper.SetDefault("PASSWORD_HASH_ALGORITHM", "argon2id")
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_REJECT_USER_INFO", true)
+	viper.SetDefault("PASSWORD_HISTORY_SIZE", 0)
+	viper.SetDefault("ACCESS_TOKEN_TTL", "15m")
+	viper.SetDefault("REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("REVOCATION_SYNC_INTERVAL", "30s")
+	viper.SetDefault("MFA_ISSUER", "Fiber API")
+	viper.SetDefault("MFA_TOKEN_TTL", "5m")
+	viper.SetDefault("APP_URL", "http://localhost:8080")
+	viper.SetDefault("MAILER_DRIVER", "file")
+	viper.SetDefault("MAIL_FROM", "noreply@localhost")
+	viper.SetDefault("MAIL_DIR", "mail")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", "48h")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("REQUIRE_EMAIL_VERIFIED", false)
+	viper.SetDefault("LOGIN_ACCOUNT_MAX_FAILURES", 5)
+	viper.SetDefault("LOGIN_IP_MAX_FAILURES", 20)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
+	viper.SetDefault("LOGIN_LOCKOUT_BASE", "1m")
+	viper.SetDefault("LOGIN_LOCKOUT_MAX", "1h")
+	viper.SetDefault("OIDC_STATE_TTL", "10m")
+	viper.SetDefault("OAUTH_CODE_TTL", "1m")
+	viper.SetDefault("ORGANIZATION_INVITE_TTL", "168h")
+	viper.SetDefault("IMPERSONATION_TTL", "15m")
+	viper.SetDefault("AUTH_COOKIES", false)
+	viper.SetDefault("AUTH_COOKIE_SECURE", true)
+	viper.SetDefault("AUTH_COOKIE_SAMESITE", "Strict")
+	viper.SetDefault("TODO_MAX_DEPTH", 5)
 
 	// Try to read the config file, but don't return an error if it's not found
 	if err := viper.ReadInConfig(); err != nil {
@@ -44,13 +127,53 @@ func LoadConfig() (*Config, error) {
 	}
 
 	cfg := &Config{
-		ServerAddress:  viper.GetString("SERVER_ADDRESS"),
-		DatabaseURL:    viper.GetString("DATABASE_URL"),
-		LogLevel:       viper.GetString("LOG_LEVEL"),
-		SentryDSN:      viper.GetString("SENTRY_DSN"),
-		Environment:    viper.GetString("ENVIRONMENT"),
-		AuthPrivateKey: viper.GetString("AUTH_PRIVATE_KEY"),
-		AuthSalt:       viper.GetString("AUTH_SALT"),
+		ServerAddress:          viper.GetString("SERVER_ADDRESS"),
+		DatabaseURL:            viper.GetString("DATABASE_URL"),
+		LogLevel:               viper.GetString("LOG_LEVEL"),
+		SentryDSN:              viper.GetString("SENTRY_DSN"),
+		Environment:            viper.GetString("ENVIRONMENT"),
+		AuthPrivateKey:         viper.GetString("AUTH_PRIVATE_KEY"),
+		AuthSalt:               viper.GetString("AUTH_SALT"),
+		PasswordHashAlgorithm:  viper.GetString("PASSWORD_HASH_ALGORITHM"),
+		PasswordMinLength:      viper.GetInt("PASSWORD_MIN_LENGTH"),
+		PasswordRequireUpper:   viper.GetBool("PASSWORD_REQUIRE_UPPER"),
+		PasswordRequireLower:   viper.GetBool("PASSWORD_REQUIRE_LOWER"),
+		PasswordRequireDigit:   viper.GetBool("PASSWORD_REQUIRE_DIGIT"),
+		PasswordRequireSymbol:  viper.GetBool("PASSWORD_REQUIRE_SYMBOL"),
+		PasswordRejectUserInfo: viper.GetBool("PASSWORD_REJECT_USER_INFO"),
+		PasswordHistorySize:    viper.GetInt("PASSWORD_HISTORY_SIZE"),
+		BreachedPasswordsFile:  viper.GetString("BREACHED_PASSWORDS_FILE"),
+		AccessTokenTTL:         viper.GetDuration("ACCESS_TOKEN_TTL"),
+		RefreshTokenTTL:        viper.GetDuration("REFRESH_TOKEN_TTL"),
+		RevocationSyncInterval: viper.GetDuration("REVOCATION_SYNC_INTERVAL"),
+		MFAIssuer:              viper.GetString("MFA_ISSUER"),
+		MFATokenTTL:            viper.GetDuration("MFA_TOKEN_TTL"),
+		AppURL:                 viper.GetString("APP_URL"),
+		MailerDriver:           viper.GetString("MAILER_DRIVER"),
+		MailFrom:               viper.GetString("MAIL_FROM"),
+		MailDir:                viper.GetString("MAIL_DIR"),
+		SMTPHost:               viper.GetString("SMTP_HOST"),
+		SMTPPort:               viper.GetInt("SMTP_PORT"),
+		SMTPUsername:           viper.GetString("SMTP_USERNAME"),
+		SMTPPassword:           viper.GetString("SMTP_PASSWORD"),
+		EmailVerificationTTL:   viper.GetDuration("EMAIL_VERIFICATION_TTL"),
+		PasswordResetTTL:       viper.GetDuration("PASSWORD_RESET_TTL"),
+		RequireEmailVerified:   viper.GetBool("REQUIRE_EMAIL_VERIFIED"),
+		LoginAccountMaxFails:   viper.GetInt("LOGIN_ACCOUNT_MAX_FAILURES"),
+		LoginIPMaxFails:        viper.GetInt("LOGIN_IP_MAX_FAILURES"),
+		LoginFailureWindow:     viper.GetDuration("LOGIN_FAILURE_WINDOW"),
+		LoginLockoutBase:       viper.GetDuration("LOGIN_LOCKOUT_BASE"),
+		LoginLockoutMax:        viper.GetDuration("LOGIN_LOCKOUT_MAX"),
+		OIDCProviders:          loadOIDCProviders(),
+		OIDCStateTTL:           viper.GetDuration("OIDC_STATE_TTL"),
+		OAuthCodeTTL:           viper.GetDuration("OAUTH_CODE_TTL"),
+		OrganizationInviteTTL:  viper.GetDuration("ORGANIZATION_INVITE_TTL"),
+		ImpersonationTTL:       viper.GetDuration("IMPERSONATION_TTL"),
+		AuthCookies:            viper.GetBool("AUTH_COOKIES"),
+		AuthCookieSecure:       viper.GetBool("AUTH_COOKIE_SECURE"),
+		AuthCookieSameSite:     viper.GetString("AUTH_COOKIE_SAMESITE"),
+		AuthCookieDomain:       viper.GetString("AUTH_COOKIE_DOMAIN"),
+		TodoMaxDepth:           viper.GetInt("TODO_MAX_DEPTH"),
 	}
 
 	// Validate essential configurations
@@ -58,5 +181,41 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.New("DATABASE_URL is required but not set")
 	}
 
+	switch strings.ToLower(cfg.AuthCookieSameSite) {
+	case "strict", "lax":
+	case "none":
+		if !cfg.AuthCookieSecure {
+			return nil, errors.New("AUTH_COOKIE_SAMESITE=None requires AUTH_COOKIE_SECURE")
+		}
+	default:
+		return nil, fmt.Errorf("invalid AUTH_COOKIE_SAMESITE %q", cfg.AuthCookieSameSite)
+	}
+
+	if cfg.TodoMaxDepth < 1 {
+		return nil, errors.New("TODO_MAX_DEPTH must be at least 1")
+	}
+
 	return cfg, nil
 }
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS. Each provider
+// is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET and an optional space separated OIDC_<NAME>_SCOPES.
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(viper.GetString("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(viper.GetString(prefix + "SCOPES")),
+		})
+	}
+	return providers
+}
@@ -1,37 +1,117 @@
 package auth
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/x509"
 	"encoding/base64"
-	"encoding/pem"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultKeysDir is where signing keys are read from and generated into
+// when AUTH_KEYS_DIR is not set
+const DefaultKeysDir = "auth_keys"
+
 var (
-	PrivateKey *ecdsa.PrivateKey
-	PwSalt     []byte
+	Keys   *Keyring
+	PwSalt []byte
 )
 
 type Claims struct {
 	jwt.StandardClaims
-	Special string `json:"spc,omitempty"`
+	UserID uint `json:"uid"`
+	// SessionID identifies the login the token was issued for and is shared
+	// with the refresh token family of that login
+	SessionID   string   `json:"sid,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	Special     string   `json:"spc,omitempty"`
+	// MFAPending marks a token issued after a correct password for a user
+	// with two-factor authentication. It is only accepted by the MFA
+	// verification endpoint, never as an access token.
+	MFAPending bool `json:"mfa,omitempty"`
+	// ClientID is set on tokens issued by the OAuth authorization server to
+	// a third-party client, whose Permissions are the scopes it was granted
+	ClientID string `json:"cid,omitempty"`
+	// OrganizationID is the organization the token acts in, 0 for the
+	// user's personal space
+	OrganizationID uint `json:"oid,omitempty"`
+	// Actor is set on tokens an admin was issued to act as the user
+	Actor *Actor `json:"act,omitempty"`
+
+	// APIKeyID is set when the request was authenticated with an API key
+	// rather than a token and is never part of a signed token
+	APIKeyID uint `json:"-"`
+	// ProjectIDs are the projects an API key is restricted to
+	ProjectIDs []uint `json:"-"`
+	// FromCookie is set when the token was read from the access token
+	// cookie rather than the Authorization header
+	FromCookie bool `json:"-"`
+}
+
+// Actor identifies who is acting on behalf of the token's user, after the
+// act claim of RFC 8693
+type Actor struct {
+	Subject string `json:"sub"`
+	UserID  uint   `json:"uid"`
+}
+
+// HasPermission reports whether the token grants the permission
+func (c *Claims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// NewToken signs the claims with the active key and tags the token with its kid
+func NewToken(claims Claims) (string, error) {
+	key := Keys.Active()
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Signer)
+}
+
+// ParseToken verifies the signature and expiry of a token against the key
+// named by its kid header and returns its claims. Tokens without a kid were
+// issued before key rotation existed and are checked against the active key.
+func ParseToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		key := Keys.Active()
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, ok = Keys.Lookup(kid); !ok {
+				return nil, fmt.Errorf("unknown or retired signing key %q", kid)
+			}
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.PublicKey(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	return claims, nil
 }
 
 func InitAuth() error {
 	var err error
 
-	// Load or generate private key
-	PrivateKey, err = loadOrGeneratePrivateKey()
+	// Load or generate signing keys
+	Keys, err = loadOrGenerateKeyring()
 	if err != nil {
-		return fmt.Errorf("failed to load or generate private key: %w", err)
+		return fmt.Errorf("failed to load or generate signing keys: %w", err)
 	}
 
 	// Load or generate salt
@@ -43,48 +123,98 @@ func InitAuth() error {
 	return nil
 }
 
-func loadOrGeneratePrivateKey() (*ecdsa.PrivateKey, error) {
+// loadOrGenerateKeyring collects keys from AUTH_PRIVATE_KEY, AUTH_PRIVATE_KEY_FILE
+// and the AUTH_KEYS_DIR directory. AUTH_RETIRED_KEY_IDS lists keys that are
+// no longer accepted and AUTH_ACTIVE_KEY_ID selects the signing key; it
+// defaults to the key from the environment, then to the newest key in the
+// directory. If no key exists a new one is generated and written to the
+// directory so that tokens survive restarts.
+func loadOrGenerateKeyring() (*Keyring, error) {
+	dir := os.Getenv("AUTH_KEYS_DIR")
+	if dir == "" {
+		dir = DefaultKeysDir
+	}
+
+	keys, err := ReadKeys(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		log.Info().Int("count", len(keys)).Str("dir", dir).Msg("Loaded signing keys from directory")
+	}
+
+	activeID := os.Getenv("AUTH_ACTIVE_KEY_ID")
+
+	envKey, err := loadPrivateKeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if envKey != nil {
+		keys = append(keys, envKey)
+		if activeID == "" {
+			activeID = envKey.ID
+		}
+	}
+
+	if len(keys) == 0 {
+		key, err := GenerateKey(AlgES256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate private key: %w", err)
+		}
+		if path, err := WriteKey(dir, key); err != nil {
+			log.Warn().Err(err).Msg("Generated new private key but could not persist it; tokens will not survive a restart")
+		} else {
+			log.Info().Str("kid", key.ID).Str("path", path).Msg("Generated new private key")
+		}
+		keys = append(keys, key)
+	}
+
+	retired := make(map[string]bool)
+	for _, id := range strings.Split(os.Getenv("AUTH_RETIRED_KEY_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			retired[id] = true
+		}
+	}
+	for _, key := range keys {
+		key.Retired = retired[key.ID]
+	}
+
+	keyring, err := NewKeyring(keys, activeID)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("kid", keyring.Active().ID).Str("alg", keyring.Active().Algorithm).Msg("Signing tokens with active key")
+	return keyring, nil
+}
+
+func loadPrivateKeyFromEnv() (*Key, error) {
 	// Check if the private key is provided as an environment variable
 	privateKeyPEM := os.Getenv("AUTH_PRIVATE_KEY")
 	if privateKeyPEM != "" {
-		block, _ := pem.Decode([]byte(privateKeyPEM))
-		if block == nil {
-			return nil, fmt.Errorf("failed to decode PEM block containing private key")
-		}
-		privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+		key, err := ParseKey("", []byte(privateKeyPEM))
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse private key: %w", err)
+			return nil, err
 		}
-		log.Info().Msg("Loaded private key from environment variable")
-		return privateKey, nil
+		log.Info().Str("kid", key.ID).Msg("Loaded private key from environment variable")
+		return key, nil
 	}
 
 	// Check if the private key file path is provided
 	privateKeyPath := os.Getenv("AUTH_PRIVATE_KEY_FILE")
 	if privateKeyPath != "" {
-		keyBytes, err := ioutil.ReadFile(privateKeyPath)
-		if err == nil {
-			block, _ := pem.Decode(keyBytes)
-			if block == nil {
-				return nil, fmt.Errorf("failed to decode PEM block containing private key")
-			}
-			privateKey, err := x509.ParseECPrivateKey(block.Bytes)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse private key: %w", err)
-			}
-			log.Info().Msg("Loaded private key from file")
-			return privateKey, nil
+		keyBytes, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key file: %w", err)
 		}
+		key, err := ParseKey("", keyBytes)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Str("kid", key.ID).Msg("Loaded private key from file")
+		return key, nil
 	}
 
-	// If no key is provided, generate a new one
-	privateKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate private key: %w", err)
-	}
-
-	log.Info().Msg("Generated new private key")
-	return privateKey, nil
+	return nil, nil
 }
 
 func loadOrGenerateSalt() ([]byte, error) {
@@ -111,3 +241,12 @@ func loadOrGenerateSalt() ([]byte, error) {
 	log.Info().Msg("Generated new salt")
 	return salt, nil
 }
+
+// ClaimsFromContext returns the claims stored in the request context by middleware.JWTAuth
+func ClaimsFromContext(c *fiber.Ctx) (*Claims, bool) {
+	claims, ok := c.Locals("claims").(*Claims)
+	if !ok || claims == nil {
+		return nil, false
+	}
+	return claims, true
+}
@@ -0,0 +1,12 @@
+package auth
+
+// Names of the cookies and header used by browser clients that keep their
+// tokens in cookies instead of storage readable by scripts
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	// CSRFCookie is readable by scripts, which must repeat its value in
+	// CSRFHeader on every state-changing request authenticated by cookie
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
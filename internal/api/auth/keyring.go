@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Supported signing algorithms
+const (
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgES512 = "ES512"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is a signing key identified by the kid header of the tokens it signs.
+// Retired keys are kept on the keyring but no longer accepted.
+type Key struct {
+	ID        string
+	Algorithm string
+	Signer    crypto.Signer
+	Retired   bool
+}
+
+// NewKey wraps an ECDSA or Ed25519 private key, deriving the algorithm from
+// the key type and curve
+func NewKey(id string, signer crypto.Signer) (*Key, error) {
+	key := &Key{ID: id, Signer: signer}
+
+	switch k := signer.(type) {
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Algorithm = AlgES256
+		case elliptic.P384():
+			key.Algorithm = AlgES384
+		case elliptic.P521():
+			key.Algorithm = AlgES512
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", signer)
+	}
+
+	if key.ID == "" {
+		kid, err := key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = kid
+	}
+	return key, nil
+}
+
+// GenerateKey creates a new key for the algorithm. The ID starts with the
+// creation time so that newer keys sort after older ones.
+func GenerateKey(algorithm string) (*Key, error) {
+	var signer crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgES384:
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case AlgES512:
+		signer, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("%s-%x", time.Now().UTC().Format("20060102T150405Z"), suffix)
+	return NewKey(id, signer)
+}
+
+// ParseKey decodes a PEM encoded SEC 1 or PKCS #8 private key
+func ParseKey(id string, pemBytes []byte) (*Key, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block containing private key")
+	}
+
+	var signer crypto.Signer
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		signer = privateKey
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		s, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+		}
+		signer = s
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	return NewKey(id, signer)
+}
+
+// MarshalPEM encodes the private key as PKCS #8
+func (k *Key) MarshalPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Signer)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// WriteKey stores the key as <dir>/<kid>.pem, readable by the owner only
+func WriteKey(dir string, key *Key) (string, error) {
+	pemBytes, err := key.MarshalPEM()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, key.ID+".pem")
+	if err := os.WriteFile(path, pemBytes, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ReadKeys loads every <kid>.pem file in dir. A missing directory yields no keys.
+func ReadKeys(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (k *Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return SigningMethodEdDSA
+	}
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// PublicKey returns the *ecdsa.PublicKey or ed25519.PublicKey of the key
+func (k *Key) PublicKey() crypto.PublicKey {
+	return k.Signer.Public()
+}
+
+// JWK returns the public part of the key as a JSON Web Key
+func (k *Key) JWK() JWK {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+
+	switch pub := k.PublicKey().(type) {
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = encodeCoordinate(pub.X, size)
+		jwk.Y = encodeCoordinate(pub.Y, size)
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint of the public key
+func (k *Key) Thumbprint() (string, error) {
+	jwk := k.JWK()
+
+	// Members in lexicographic order, as required by RFC 7638
+	var members interface{}
+	if jwk.KeyType == "EC" {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func encodeCoordinate(n *big.Int, size int) string {
+	return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, size)))
+}
+
+// Keyring holds every known signing key. Tokens are signed with the active
+// key and verified against any key that is not retired.
+type Keyring struct {
+	keys   map[string]*Key
+	active *Key
+}
+
+// NewKeyring builds a keyring from keys. When activeID is empty the key with
+// the greatest ID that is not retired becomes active.
+func NewKeyring(keys []*Key, activeID string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keyring needs at least one key")
+	}
+
+	r := &Keyring{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, exists := r.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		r.keys[key.ID] = key
+	}
+
+	if activeID == "" {
+		for _, id := range r.IDs() {
+			if !r.keys[id].Retired {
+				activeID = id
+			}
+		}
+	}
+
+	active, ok := r.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	if active.Retired {
+		return nil, fmt.Errorf("active key %q is retired", activeID)
+	}
+	r.active = active
+
+	return r, nil
+}
+
+// Active returns the key new tokens are signed with
+func (r *Keyring) Active() *Key {
+	return r.active
+}
+
+// Lookup returns the key with the given ID unless it is unknown or retired
+func (r *Keyring) Lookup(id string) (*Key, bool) {
+	key, ok := r.keys[id]
+	if !ok || key.Retired {
+		return nil, false
+	}
+	return key, true
+}
+
+// IDs returns the IDs of all keys in ascending order
+func (r *Keyring) IDs() []string {
+	ids := make([]string, 0, len(r.keys))
+	for id := range r.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// JWKS returns the public keys that tokens may currently be verified with
+func (r *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range r.IDs() {
+		if key := r.keys[id]; !key.Retired {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	return set
+}
+
+// JWK is a public JSON Web Key as defined in RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClaims() Claims {
+	return Claims{
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()},
+		UserID:         1,
+	}
+}
+
+func TestSignAndVerifyWithEachAlgorithm(t *testing.T) {
+	for _, alg := range []string{AlgES256, AlgES384, AlgES512, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := GenerateKey(alg)
+			require.NoError(t, err)
+			Keys, err = NewKeyring([]*Key{key}, "")
+			require.NoError(t, err)
+
+			tokenString, err := NewToken(testClaims())
+			require.NoError(t, err)
+
+			token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, token.Header["kid"])
+			assert.Equal(t, alg, token.Header["alg"])
+
+			claims, err := ParseToken(tokenString)
+			require.NoError(t, err)
+			assert.Equal(t, uint(1), claims.UserID)
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, err := GenerateKey(AlgES256)
+	require.NoError(t, err)
+	newKey, err := GenerateKey(AlgEdDSA)
+	require.NoError(t, err)
+
+	Keys, err = NewKeyring([]*Key{oldKey}, "")
+	require.NoError(t, err)
+	oldToken, err := NewToken(testClaims())
+	require.NoError(t, err)
+
+	// After rotation tokens signed with the previous key remain valid
+	Keys, err = NewKeyring([]*Key{oldKey, newKey}, newKey.ID)
+	require.NoError(t, err)
+	_, err = ParseToken(oldToken)
+	assert.NoError(t, err)
+
+	newToken, err := NewToken(testClaims())
+	require.NoError(t, err)
+	_, err = ParseToken(newToken)
+	assert.NoError(t, err)
+
+	// ...until the previous key is retired
+	oldKey.Retired = true
+	Keys, err = NewKeyring([]*Key{oldKey, newKey}, "")
+	require.NoError(t, err)
+	assert.Equal(t, newKey.ID, Keys.Active().ID)
+	_, err = ParseToken(oldToken)
+	assert.Error(t, err)
+	assert.Len(t, Keys.JWKS().Keys, 1)
+}
+
+func TestNewKeyringRejectsRetiredActiveKey(t *testing.T) {
+	key, err := GenerateKey(AlgES256)
+	require.NoError(t, err)
+	key.Retired = true
+
+	_, err = NewKeyring([]*Key{key}, key.ID)
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	ecKey, err := GenerateKey(AlgES256)
+	require.NoError(t, err)
+	edKey, err := GenerateKey(AlgEdDSA)
+	require.NoError(t, err)
+
+	keyring, err := NewKeyring([]*Key{ecKey, edKey}, "")
+	require.NoError(t, err)
+
+	jwks := keyring.JWKS()
+	require.Len(t, jwks.Keys, 2)
+
+	byID := map[string]JWK{}
+	for _, jwk := range jwks.Keys {
+		byID[jwk.KeyID] = jwk
+	}
+
+	assert.Equal(t, "EC", byID[ecKey.ID].KeyType)
+	assert.Equal(t, "P-256", byID[ecKey.ID].Curve)
+	assert.Len(t, byID[ecKey.ID].X, 43)
+	assert.Len(t, byID[ecKey.ID].Y, 43)
+
+	assert.Equal(t, "OKP", byID[edKey.ID].KeyType)
+	assert.Equal(t, "Ed25519", byID[edKey.ID].Curve)
+	assert.Empty(t, byID[edKey.ID].Y)
+}
+
+func TestKeyPEMRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, alg := range []string{AlgES512, AlgEdDSA} {
+		key, err := GenerateKey(alg)
+		require.NoError(t, err)
+		_, err = WriteKey(dir, key)
+		require.NoError(t, err)
+	}
+
+	keys, err := ReadKeys(dir)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
+
+func TestParseKeyDerivesThumbprintID(t *testing.T) {
+	key, err := GenerateKey(AlgES256)
+	require.NoError(t, err)
+	pemBytes, err := key.MarshalPEM()
+	require.NoError(t, err)
+
+	parsed, err := ParseKey("", pemBytes)
+	require.NoError(t, err)
+
+	thumbprint, err := key.Thumbprint()
+	require.NoError(t, err)
+	assert.Equal(t, thumbprint, parsed.ID)
+}
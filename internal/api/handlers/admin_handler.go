@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type AdminHandler struct {
+	users    services.UserAdminService
+	throttle services.LoginThrottleService
+	validate *validator.Validate
+}
+
+func NewAdminHandler(users services.UserAdminService, throttle services.LoginThrottleService) *AdminHandler {
+	return &AdminHandler{users: users, throttle: throttle, validate: validator.New()}
+}
+
+// ListUsers lists user accounts
+// @Summary List users
+// @Description Get a paginated list of users, optionally filtered by a search term matched against name and email
+// @Tags Admin
+// @Produce json
+// @Param q query string false "Search term"
+// @Param page query int false "Page number" default(1) minimum(1)
+// @Param page_size query int false "Page size" default(10) minimum(1) maximum(100)
+// @Success 200 {object} apiUtils.Response[[]models.User]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users [get]
+// @Security ApiKeyAuth
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	pageSize := c.QueryInt("page_size", 10)
+
+	if page < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid page number", fiber.StatusBadRequest))
+	}
+	if pageSize < 1 || pageSize > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid page size", fiber.StatusBadRequest))
+	}
+
+	users, total, err := h.users.ListUsers(c.Query("q"), page, pageSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list users")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not list users", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[models.User](users, page, pageSize, int(total))
+	return c.JSON(response)
+}
+
+// GetUser returns a user account
+// @Summary Get a user
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} apiUtils.Response[models.User]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id} [get]
+// @Security ApiKeyAuth
+func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
+	id, ok := h.userID(c)
+	if !ok {
+		return nil
+	}
+
+	user, err := h.users.GetUser(id)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.User](*user)
+	return c.JSON(response)
+}
+
+// DisableUser disables a user account
+// @Summary Disable a user
+// @Description Block the user from logging in and revoke all of their tokens and sessions
+// @Tags Admin
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id}/disable [post]
+// @Security ApiKeyAuth
+func (h *AdminHandler) DisableUser(c *fiber.Ctx) error {
+	id, ok := h.otherUserID(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.users.DisableUser(id); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// EnableUser enables a disabled user account
+// @Summary Enable a user
+// @Tags Admin
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id}/enable [post]
+// @Security ApiKeyAuth
+func (h *AdminHandler) EnableUser(c *fiber.Ctx) error {
+	id, ok := h.userID(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.users.EnableUser(id); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ForcePasswordReset requires a user to reset their password
+// @Summary Force a password reset
+// @Description Log the user out everywhere, block logins until the password is reset and email the user a reset link
+// @Tags Admin
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id}/password-reset [post]
+// @Security ApiKeyAuth
+func (h *AdminHandler) ForcePasswordReset(c *fiber.Ctx) error {
+	id, ok := h.userID(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.users.ForcePasswordReset(id); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SetRoles replaces the roles of a user
+// @Summary Assign roles
+// @Description Replace the user's roles with at least one role. The user's tokens and sessions are revoked, so the new roles apply from the next login.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param roles body models.SetRolesRequest true "Role names"
+// @Success 200 {object} apiUtils.Response[models.User]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id}/roles [put]
+// @Security ApiKeyAuth
+func (h *AdminHandler) SetRoles(c *fiber.Ctx) error {
+	id, ok := h.userID(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.SetRolesRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	user, err := h.users.SetRoles(id, request.Roles)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.User](*user)
+	return c.JSON(response)
+}
+
+// DeleteUser soft-deletes a user account
+// @Summary Delete a user
+// @Description Soft-delete the user and revoke all of their tokens and sessions
+// @Tags Admin
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id} [delete]
+// @Security ApiKeyAuth
+func (h *AdminHandler) DeleteUser(c *fiber.Ctx) error {
+	id, ok := h.otherUserID(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.users.DeleteUser(id); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// UnlockUser clears the failed login counter and lockout of a user
+// @Summary Unlock a user
+// @Description Clear the failed login counter of a user's account and lift any lockout
+// @Tags Admin
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id}/unlock [post]
+// @Security ApiKeyAuth
+func (h *AdminHandler) UnlockUser(c *fiber.Ctx) error {
+	id, ok := h.userID(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.throttle.UnlockUser(id); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// userID parses the user ID path parameter, writing an error response if it is invalid
+func (h *AdminHandler) userID(c *fiber.Ctx) (uint, bool) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// otherUserID is like userID but also rejects the current user, so that
+// admins cannot lock themselves out
+func (h *AdminHandler) otherUserID(c *fiber.Ctx) (uint, bool) {
+	id, ok := h.userID(c)
+	if !ok {
+		return 0, false
+	}
+	if currentID, _ := currentUserID(c); currentID == id {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Cannot apply to your own account", fiber.StatusBadRequest))
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *AdminHandler) error(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errors.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("User not found", fiber.StatusNotFound))
+	case errors.Is(err, errors.ErrInvalidInput):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Unknown role", fiber.StatusBadRequest))
+	}
+	log.Error().Err(err).Msg("Admin request failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not update user", fiber.StatusInternalServerError))
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	_ services.LoginThrottleService = (*MockLoginThrottleService)(nil)
+	_ services.UserAdminService     = (*MockUserAdminService)(nil)
+)
+
+type MockLoginThrottleService struct {
+	mock.Mock
+}
+
+func (m *MockLoginThrottleService) Check(name, ip string) error {
+	args := m.Called(name, ip)
+	return args.Error(0)
+}
+
+func (m *MockLoginThrottleService) RecordFailure(name, ip string) error {
+	args := m.Called(name, ip)
+	return args.Error(0)
+}
+
+func (m *MockLoginThrottleService) RecordSuccess(name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *MockLoginThrottleService) UnlockUser(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// newOpenThrottle returns a throttle that never locks anyone out
+func newOpenThrottle() *MockLoginThrottleService {
+	throttle := new(MockLoginThrottleService)
+	throttle.On("Check", mock.Anything, mock.Anything).Return(nil).Maybe()
+	throttle.On("RecordFailure", mock.Anything, mock.Anything).Return(nil).Maybe()
+	throttle.On("RecordSuccess", mock.Anything).Return(nil).Maybe()
+	return throttle
+}
+
+type MockUserAdminService struct {
+	mock.Mock
+}
+
+func (m *MockUserAdminService) ListUsers(query string, page, pageSize int) ([]models.User, int64, error) {
+	args := m.Called(query, page, pageSize)
+	users, _ := args.Get(0).([]models.User)
+	return users, args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockUserAdminService) GetUser(userID uint) (*models.User, error) {
+	args := m.Called(userID)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockUserAdminService) DisableUser(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockUserAdminService) EnableUser(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockUserAdminService) ForcePasswordReset(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockUserAdminService) SetRoles(userID uint, roles []string) (*models.User, error) {
+	args := m.Called(userID, roles)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockUserAdminService) DeleteUser(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func TestListUsers(t *testing.T) {
+	users := new(MockUserAdminService)
+	handler := NewAdminHandler(users, newOpenThrottle())
+
+	app := fiber.New()
+	app.Get("/admin/users", handler.ListUsers)
+
+	users.On("ListUsers", "ali", 2, 5).Return([]models.User{{ID: 7, Name: "alice"}}, int64(6), nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/admin/users?q=ali&page=2&page_size=5", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []models.User `json:"data"`
+		Meta struct {
+			TotalItems int64 `json:"total_items"`
+		} `json:"meta"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body.Data, 1)
+	assert.Equal(t, int64(6), body.Meta.TotalItems)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/admin/users?page_size=500", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	users.AssertExpectations(t)
+}
+
+func TestDisableUser(t *testing.T) {
+	users := new(MockUserAdminService)
+	handler := NewAdminHandler(users, newOpenThrottle())
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/admin/users/:id/disable", handler.DisableUser)
+
+	users.On("DisableUser", uint(2)).Return(nil)
+	users.On("DisableUser", uint(3)).Return(errors.ErrUserNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("POST", "/admin/users/2/disable", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("POST", "/admin/users/3/disable", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	// Admins cannot disable their own account
+	resp, _ = app.Test(httptest.NewRequest("POST", "/admin/users/1/disable", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	users.AssertExpectations(t)
+	users.AssertNotCalled(t, "DisableUser", uint(1))
+}
+
+func TestSetRoles(t *testing.T) {
+	users := new(MockUserAdminService)
+	handler := NewAdminHandler(users, newOpenThrottle())
+
+	app := fiber.New()
+	app.Put("/admin/users/:id/roles", handler.SetRoles)
+
+	users.On("SetRoles", uint(2), []string{"admin"}).Return(&models.User{ID: 2, Roles: []models.Role{{Name: "admin"}}}, nil)
+	users.On("SetRoles", uint(2), []string{"nope"}).Return(nil, errors.ErrInvalidInput)
+
+	send := func(roles []string) int {
+		body, _ := json.Marshal(models.SetRolesRequest{Roles: roles})
+		req := httptest.NewRequest("PUT", "/admin/users/2/roles", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusOK, send([]string{"admin"}))
+	assert.Equal(t, fiber.StatusBadRequest, send([]string{"nope"}))
+	assert.Equal(t, fiber.StatusBadRequest, send([]string{""}))
+	assert.Equal(t, fiber.StatusBadRequest, send([]string{}))
+	assert.Equal(t, fiber.StatusBadRequest, send(nil))
+
+	users.AssertExpectations(t)
+}
+
+func TestDeleteUser(t *testing.T) {
+	users := new(MockUserAdminService)
+	handler := NewAdminHandler(users, newOpenThrottle())
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Delete("/admin/users/:id", handler.DeleteUser)
+
+	users.On("DeleteUser", uint(2)).Return(nil)
+	users.On("DeleteUser", uint(3)).Return(errors.ErrUserNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/admin/users/2", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/admin/users/3", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/admin/users/1", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	users.AssertExpectations(t)
+}
+
+func TestUnlockUser(t *testing.T) {
+	throttle := new(MockLoginThrottleService)
+	handler := NewAdminHandler(new(MockUserAdminService), throttle)
+
+	app := fiber.New()
+	app.Post("/admin/users/:id/unlock", handler.UnlockUser)
+
+	throttle.On("UnlockUser", uint(1)).Return(nil)
+	throttle.On("UnlockUser", uint(2)).Return(errors.ErrUserNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("POST", "/admin/users/1/unlock", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("POST", "/admin/users/2/unlock", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("POST", "/admin/users/abc/unlock", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	throttle.AssertExpectations(t)
+}
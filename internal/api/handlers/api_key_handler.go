@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type APIKeyHandler struct {
+	service  services.APIKeyService
+	validate *validator.Validate
+}
+
+func NewAPIKeyHandler(service services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service, validate: validator.New()}
+}
+
+// CreateAPIKey creates a new API key for the current user
+// @Summary Create an API key
+// @Description Create a named API key, optionally limited to a subset of the user's permissions, to some of the user's personal projects and with an expiry.
+// @Description The key is returned only once.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Param key body models.CreateAPIKeyRequest true "API key details"
+// @Success 201 {object} apiUtils.Response[models.CreateAPIKeyResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /api-keys [post]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.CreateAPIKeyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	apiKey, err := h.service.CreateAPIKey(userID, request.Name, request.Scopes, request.ProjectIDs, request.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidInput) {
+			return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+		}
+		log.Error().Err(err).Msg("Failed to create API key")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not create API key", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[models.CreateAPIKeyResponse](models.CreateAPIKeyResponse{
+		APIKey: *apiKey,
+		Key:    apiKey.Key,
+	})
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// ListAPIKeys lists the API keys of the current user
+// @Summary List API keys
+// @Tags API Keys
+// @Produce json
+// @Success 200 {object} apiUtils.Response[[]models.APIKey]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /api-keys [get]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	keys, err := h.service.ListAPIKeys(userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list API keys")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not list API keys", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[[]models.APIKey](keys)
+	return c.JSON(response)
+}
+
+// RevokeAPIKey revokes one of the current user's API keys
+// @Summary Revoke an API key
+// @Tags API Keys
+// @Param id path int true "API key ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /api-keys/{id} [delete]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	if err := h.service.RevokeAPIKey(userID, uint(id)); err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("API key not found", fiber.StatusNotFound))
+		}
+		log.Error().Err(err).Msg("Failed to revoke API key")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not revoke API key", fiber.StatusInternalServerError))
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// tokenUser returns the current user ID. Keys may not manage keys, otherwise
+// a narrowly scoped key could mint an unrestricted one.
+func (h *APIKeyHandler) tokenUser(c *fiber.Ctx) (uint, bool) {
+	return tokenUserID(c, "API keys cannot be managed with an API key")
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.APIKeyService = (*MockAPIKeyService)(nil)
+
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(userID uint, name string, scopes []string, projectIDs []uint, expiresAt *time.Time) (*models.APIKey, error) {
+	args := m.Called(userID, name, scopes, projectIDs, expiresAt)
+	key, _ := args.Get(0).(*models.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(userID, id uint) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) AuthenticateAPIKey(key string) (*models.User, *models.APIKey, error) {
+	args := m.Called(key)
+	user, _ := args.Get(0).(*models.User)
+	apiKey, _ := args.Get(1).(*models.APIKey)
+	return user, apiKey, args.Error(2)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	handler := NewAPIKeyHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/api-keys", handler.CreateAPIKey)
+
+	testCases := []struct {
+		name           string
+		request        models.CreateAPIKeyRequest
+		mockKey        *models.APIKey
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Success",
+			request:        models.CreateAPIKeyRequest{Name: "cron", Scopes: []string{models.PermTodosRead}},
+			mockKey:        &models.APIKey{ID: 1, Name: "cron", Key: "gfb_secret"},
+			expectedStatus: fiber.StatusCreated,
+		},
+		{
+			name:           "Scope Not Granted",
+			request:        models.CreateAPIKeyRequest{Name: "admin", Scopes: []string{models.PermUsersAdmin}},
+			mockError:      fmt.Errorf("%w: scope not granted", errors.ErrInvalidInput),
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "Restricted To Projects",
+			request:        models.CreateAPIKeyRequest{Name: "sync", ProjectIDs: []uint{3, 4}},
+			mockKey:        &models.APIKey{ID: 2, Name: "sync", ProjectIDs: []uint{3, 4}, Key: "gfb_secret"},
+			expectedStatus: fiber.StatusCreated,
+		},
+		{
+			name:           "Unknown Project",
+			request:        models.CreateAPIKeyRequest{Name: "sync", ProjectIDs: []uint{99}},
+			mockError:      fmt.Errorf("%w: project 99 does not exist", errors.ErrInvalidInput),
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService.On("CreateAPIKey", uint(1), tc.request.Name, tc.request.Scopes, tc.request.ProjectIDs, (*time.Time)(nil)).Return(tc.mockKey, tc.mockError)
+
+			body, _ := json.Marshal(tc.request)
+			req := httptest.NewRequest("POST", "/api-keys", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			if tc.expectedStatus == fiber.StatusCreated {
+				var result struct {
+					Data models.CreateAPIKeyResponse `json:"data"`
+				}
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+				assert.Equal(t, "gfb_secret", result.Data.Key)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateAPIKeyWithAPIKey(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	handler := NewAPIKeyHandler(mockService)
+
+	app := fiber.New()
+	app.Post("/api-keys", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, APIKeyID: 3})
+		return handler.CreateAPIKey(c)
+	})
+
+	body, _ := json.Marshal(models.CreateAPIKeyRequest{Name: "escalate"})
+	req := httptest.NewRequest("POST", "/api-keys", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	mockService.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListAPIKeys(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	handler := NewAPIKeyHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Get("/api-keys", handler.ListAPIKeys)
+
+	mockService.On("ListAPIKeys", uint(1)).Return([]models.APIKey{{ID: 1, Name: "cron"}}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api-keys", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	handler := NewAPIKeyHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Delete("/api-keys/:id", handler.RevokeAPIKey)
+
+	mockService.On("RevokeAPIKey", uint(1), uint(1)).Return(nil)
+	mockService.On("RevokeAPIKey", uint(1), uint(2)).Return(errors.ErrResourceNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/api-keys/1", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/api-keys/2", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
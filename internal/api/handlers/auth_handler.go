@@ -1,37 +1,63 @@
 package handlers
 
 import (
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/netf/gofiber-boilerplate/config"
 	"github.com/netf/gofiber-boilerplate/internal/api/auth"
 	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
 	"github.com/netf/gofiber-boilerplate/internal/errors"
 	"github.com/netf/gofiber-boilerplate/internal/models"
 	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
 )
 
 type AuthHandler struct {
-	authService services.AuthService
-	validate    *validator.Validate
+	authService    services.AuthService
+	mfaService     services.MFAService
+	throttle       services.LoginThrottleService
+	revocations    services.RevocationService
+	validate       *validator.Validate
+	accessTokenTTL time.Duration
+	mfaTokenTTL    time.Duration
+	cookies        authCookies
 }
 
-func NewAuthHandler(authService services.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService, validate: validator.New()}
+func NewAuthHandler(authService services.AuthService, mfaService services.MFAService, throttle services.LoginThrottleService, revocations services.RevocationService, cfg *config.Config) *AuthHandler {
+	return &AuthHandler{
+		authService:    authService,
+		mfaService:     mfaService,
+		throttle:       throttle,
+		revocations:    revocations,
+		validate:       validator.New(),
+		accessTokenTTL: cfg.AccessTokenTTL,
+		mfaTokenTTL:    cfg.MFATokenTTL,
+		cookies:        newAuthCookies(cfg),
+	}
 }
 
 // Login handles user authentication and returns a JWT token
 // @Summary User login
-// @Description Authenticate a user and return a JWT token
+// @Description Authenticate a user and return a short-lived JWT access token and a refresh token.
+// @Description If the user has two-factor authentication enabled, mfa_required is set instead and the returned mfa_token must be sent to /auth/mfa/verify.
+// @Description With cookie authentication enabled, browser clients can send X-Auth-Mode: cookie to receive the tokens as HttpOnly cookies instead, together with a csrf_token to send in the X-CSRF-Token header of state-changing requests.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param login body models.LoginRequest true "Login credentials"
+// @Param X-Auth-Mode header string false "cookie to receive the tokens as cookies"
 // @Success 200 {object} apiUtils.Response[models.LoginResponse]
 // @Failure 400 {object} apiUtils.ErrorResponse
 // @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 423 {object} apiUtils.ErrorResponse
+// @Failure 429 {object} apiUtils.ErrorResponse
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var login models.LoginRequest
@@ -39,31 +65,152 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
 	}
 
+	if err := h.throttle.Check(login.Name, c.IP()); err != nil {
+		return h.throttled(c, err)
+	}
+
 	user, err := h.authService.AuthenticateUser(login.Name, login.Pass)
+	if errors.Is(err, errors.ErrInvalidCredentials) {
+		h.recordFailure(login.Name, c.IP())
+	}
+
+	return h.authenticated(c, user, err)
+}
+
+// authenticated finishes a login with the result of authenticating the user.
+// It starts a session, asks for the second factor or rejects the login.
+func (h *AuthHandler) authenticated(c *fiber.Ctx, user *models.User, err error) error {
+	if errors.Is(err, errors.ErrMFARequired) {
+		mfaToken, err := h.newMFAToken(user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
+		}
+
+		response := apiUtils.CreateResponse[models.LoginResponse](models.LoginResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+	if errors.Is(err, errors.ErrEmailNotVerified) {
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("Email address not verified", fiber.StatusForbidden))
+	}
+	if errors.Is(err, errors.ErrAccountDisabled) {
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("Account disabled", fiber.StatusForbidden))
+	}
+	if errors.Is(err, errors.ErrPasswordResetRequired) {
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("Password reset required", fiber.StatusForbidden))
+	}
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Invalid credentials", fiber.StatusUnauthorized))
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodES512, auth.Claims{
-		StandardClaims: jwt.StandardClaims{
-			Subject:   user.Name,
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-		},
-	})
+	return h.login(c, user)
+}
 
-	ts, err := token.SignedString(auth.PrivateKey)
+// VerifyMFA completes a login for a user with two-factor authentication
+// @Summary Verify a two-factor code
+// @Description Exchange the mfa_token returned by login and a TOTP or recovery code for an access token and a refresh token. The mfa_token can only be exchanged once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param verify body models.MFAVerifyRequest true "MFA token and code"
+// @Success 200 {object} apiUtils.Response[models.LoginResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 423 {object} apiUtils.ErrorResponse
+// @Failure 429 {object} apiUtils.ErrorResponse
+// @Router /auth/mfa/verify [post]
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var verify models.MFAVerifyRequest
+	if err := c.BodyParser(&verify); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(verify); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	claims, err := auth.ParseToken(verify.MFAToken)
+	if err != nil || !claims.MFAPending || claims.UserID == 0 || claims.Id == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Invalid MFA token", fiber.StatusUnauthorized))
+	}
+
+	revoked, err := h.revocations.IsRevoked(claims.Id, "", claims.UserID, time.Unix(claims.IssuedAt, 0))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not verify code", fiber.StatusInternalServerError))
+	}
+	if revoked {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Invalid MFA token", fiber.StatusUnauthorized))
+	}
+
+	if err := h.throttle.Check(claims.Subject, c.IP()); err != nil {
+		return h.throttled(c, err)
+	}
+
+	user, err := h.mfaService.VerifyLogin(claims.UserID, verify.Code)
+	if errors.Is(err, errors.ErrInvalidMFACode) {
+		h.recordFailure(claims.Subject, c.IP())
+	}
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidMFACode) || errors.Is(err, errors.ErrMFANotEnabled) ||
+			errors.Is(err, errors.ErrUserNotFound) || errors.Is(err, errors.ErrAccountDisabled) {
+			return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Invalid code", fiber.StatusUnauthorized))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not verify code", fiber.StatusInternalServerError))
+	}
+
+	// The token is used up by the first successful verification, so it
+	// cannot be used to guess further codes or start more sessions
+	if err := h.revocations.RevokeToken(claims.Id, claims.UserID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		log.Error().Err(err).Uint("user_id", claims.UserID).Msg("Failed to revoke MFA token")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not verify code", fiber.StatusInternalServerError))
+	}
+
+	return h.login(c, user)
+}
+
+// login starts a new session for an authenticated user and writes the tokens
+func (h *AuthHandler) login(c *fiber.Ctx, user *models.User) error {
+	if err := h.throttle.RecordSuccess(user.Name); err != nil {
+		log.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to reset login failures")
+	}
+
+	refreshToken, err := h.authService.CreateSession(user.ID, c.Get(fiber.HeaderUserAgent), c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
+	}
+
+	accessToken, err := h.newAccessToken(user, refreshToken.FamilyID, 0)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
 	}
 
-	response := apiUtils.CreateResponse[models.LoginResponse](models.LoginResponse{Token: ts})
+	if h.cookies.requested(c) {
+		csrfToken, err := h.cookies.setTokens(c, accessToken, time.Now().Add(h.accessTokenTTL), refreshToken)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
+		}
+
+		response := apiUtils.CreateResponse[models.LoginResponse](models.LoginResponse{
+			ExpiresIn: int64(h.accessTokenTTL.Seconds()),
+			CSRFToken: csrfToken,
+		})
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+
+	response := apiUtils.CreateResponse[models.LoginResponse](models.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.accessTokenTTL.Seconds()),
+	})
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
 // Register handles user registration
 // @Summary User registration
-// @Description Register a new user
+// @Description Register a new user. A password that does not meet the password policy is rejected with the reasons listed in fields.pass.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -84,6 +231,10 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 	user, err := h.authService.RegisterUser(register.Name, register.Pass, register.Email)
 	if err != nil {
+		var policyErr *errors.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return rejectPassword(c, "pass", policyErr)
+		}
 		if errors.Is(err, errors.ErrUserAlreadyExists) {
 			return c.Status(fiber.StatusConflict).JSON(apiUtils.CreateErrorResponse("User already exists", fiber.StatusConflict))
 		}
@@ -100,45 +251,336 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 // Logout handles user logout
 // @Summary User logout
-// @Description Logout a user (client-side only in this implementation)
+// @Description Revoke the current access token and the refresh token of the same login
 // @Tags Authentication
 // @Produce json
 // @Success 200 {object} apiUtils.Response[models.LogoutResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
 // @Router /auth/logout [post]
+// @Security ApiKeyAuth
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+	if claims.Id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Only access tokens can be logged out", fiber.StatusBadRequest))
+	}
+
+	if err := h.authService.Logout(claims.UserID, claims.Id, time.Unix(claims.ExpiresAt, 0), claims.SessionID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not log out", fiber.StatusInternalServerError))
+	}
+	if claims.FromCookie {
+		h.cookies.clear(c)
+	}
+
 	response := apiUtils.CreateResponse[models.LogoutResponse](models.LogoutResponse{
 		Message: "Successfully logged out",
 	})
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
+// LogoutAll handles logout from every session
+// @Summary Logout from all sessions
+// @Description Revoke every access and refresh token issued to the current user
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} apiUtils.Response[models.LogoutResponse]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/logout/all [post]
+// @Security ApiKeyAuth
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	userID, ok := tokenUserID(c, "Sessions cannot be managed with an API key")
+	if !ok {
+		return nil
+	}
+
+	if err := h.authService.LogoutAll(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not log out", fiber.StatusInternalServerError))
+	}
+	if claims, _ := auth.ClaimsFromContext(c); claims.FromCookie {
+		h.cookies.clear(c)
+	}
+
+	response := apiUtils.CreateResponse[models.LogoutResponse](models.LogoutResponse{
+		Message: "Successfully logged out of all sessions",
+	})
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
 // RefreshToken handles token refresh
 // @Summary Refresh JWT token
-// @Description Refresh the JWT token for a logged-in user
+// @Description Exchange a refresh token for a new access token and a new refresh token.
+// @Description Each refresh token can be used only once; presenting a rotated token revokes all tokens issued from the same login.
+// @Description Browser clients that logged in with cookies send no body; the refresh token cookie is used and the new tokens are set as cookies.
 // @Tags Authentication
+// @Accept json
 // @Produce json
+// @Param refresh body models.RefreshTokenRequest true "Refresh token"
 // @Success 200 {object} apiUtils.Response[models.RefreshTokenResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
 // @Failure 401 {object} apiUtils.ErrorResponse
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
-	user, ok := c.Locals("user").(*models.User)
-	if !ok || user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Invalid user", fiber.StatusUnauthorized))
+	// Browser clients in cookie mode send no body and the refresh token cookie
+	var refresh models.RefreshTokenRequest
+	fromCookie := h.cookies.enabled && len(c.Body()) == 0 && c.Cookies(auth.RefreshTokenCookie) != ""
+	if fromCookie {
+		refresh.RefreshToken = c.Cookies(auth.RefreshTokenCookie)
+	} else {
+		if err := c.BodyParser(&refresh); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+		}
+
+		if err := h.validate.Struct(refresh); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodES512, auth.Claims{
-		StandardClaims: jwt.StandardClaims{
-			Subject:   user.Name,
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-		},
-	})
+	user, refreshToken, err := h.authService.RotateRefreshToken(refresh.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidToken) || errors.Is(err, errors.ErrTokenReused) {
+			if fromCookie {
+				h.cookies.clear(c)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Invalid refresh token", fiber.StatusUnauthorized))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not refresh token", fiber.StatusInternalServerError))
+	}
 
-	ts, err := token.SignedString(auth.PrivateKey)
+	accessToken, err := h.newAccessToken(user, refreshToken.FamilyID, refreshToken.OrganizationID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
 	}
 
-	response := apiUtils.CreateResponse[models.RefreshTokenResponse](models.RefreshTokenResponse{Token: ts})
+	if fromCookie {
+		csrfToken, err := h.cookies.setTokens(c, accessToken, time.Now().Add(h.accessTokenTTL), refreshToken)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
+		}
+
+		response := apiUtils.CreateResponse[models.RefreshTokenResponse](models.RefreshTokenResponse{
+			ExpiresIn: int64(h.accessTokenTTL.Seconds()),
+			CSRFToken: csrfToken,
+		})
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+
+	response := apiUtils.CreateResponse[models.RefreshTokenResponse](models.RefreshTokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.accessTokenTTL.Seconds()),
+	})
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func (h *AuthHandler) recordFailure(name, ip string) {
+	if err := h.throttle.RecordFailure(name, ip); err != nil {
+		log.Error().Err(err).Msg("Failed to record login failure")
+	}
+}
+
+// throttled writes the response for a login rejected by the throttle: 423
+// if the account is locked, 429 if the client IP is
+func (h *AuthHandler) throttled(c *fiber.Ctx, err error) error {
+	var lockout *errors.LockoutError
+	if !errors.As(err, &lockout) {
+		log.Error().Err(err).Msg("Failed to check login throttle")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not log in", fiber.StatusInternalServerError))
+	}
+
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+	if errors.Is(err, errors.ErrAccountLocked) {
+		return c.Status(fiber.StatusLocked).JSON(apiUtils.CreateErrorResponse("Account temporarily locked after too many failed logins", fiber.StatusLocked))
+	}
+	return c.Status(fiber.StatusTooManyRequests).JSON(apiUtils.CreateErrorResponse("Too many failed logins", fiber.StatusTooManyRequests))
+}
+
+// VerifyEmail handles email address verification
+// @Summary Verify email address
+// @Description Verify the user's email address with the token from the verification email
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param verify body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} apiUtils.Response[models.MessageResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Router /auth/verify [post]
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var verify models.VerifyEmailRequest
+	if err := c.BodyParser(&verify); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(verify); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	if err := h.authService.VerifyEmail(verify.Token); err != nil {
+		if errors.Is(err, errors.ErrInvalidToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid or expired token", fiber.StatusBadRequest))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not verify email address", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[models.MessageResponse](models.MessageResponse{
+		Message: "Email address verified",
+	})
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// ResendVerification handles requests for a new verification email
+// @Summary Resend verification email
+// @Description Send a new verification link if the address belongs to an unverified account. The response does not reveal whether it does.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param email body models.EmailRequest true "Email address"
+// @Success 202 {object} apiUtils.Response[models.MessageResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Router /auth/verify/resend [post]
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	var request models.EmailRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	// The email is sent in the background so that the response takes as
+	// long whether or not the address is registered
+	go func(email string) {
+		if err := h.authService.SendVerificationEmail(email); err != nil {
+			log.Error().Err(err).Msg("Failed to send verification email")
+		}
+	}(request.Email)
+
+	response := apiUtils.CreateResponse[models.MessageResponse](models.MessageResponse{
+		Message: "If the address belongs to an unverified account, a verification email has been sent",
+	})
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// ForgotPassword handles password reset requests
+// @Summary Request a password reset
+// @Description Email a password reset link if the address belongs to an account. The response does not reveal whether it does.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param email body models.EmailRequest true "Email address"
+// @Success 202 {object} apiUtils.Response[models.MessageResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Router /auth/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var request models.EmailRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	// As in ResendVerification, the lookup and the email happen in the
+	// background so the response time does not reveal registered addresses
+	go func(email string) {
+		if err := h.authService.RequestPasswordReset(email); err != nil {
+			log.Error().Err(err).Msg("Failed to send password reset email")
+		}
+	}(request.Email)
+
+	response := apiUtils.CreateResponse[models.MessageResponse](models.MessageResponse{
+		Message: "If the address belongs to an account, a password reset email has been sent",
+	})
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// ResetPassword handles password resets
+// @Summary Reset password
+// @Description Set a new password with the token from the password reset email. All sessions of the user are logged out.
+// @Description A password that does not meet the password policy is rejected with the reasons listed in fields.pass, and the token stays valid.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param reset body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} apiUtils.Response[models.MessageResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/reset [post]
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var reset models.ResetPasswordRequest
+	if err := c.BodyParser(&reset); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(reset); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	if err := h.authService.ResetPassword(reset.Token, reset.Pass); err != nil {
+		var policyErr *errors.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return rejectPassword(c, "pass", policyErr)
+		}
+		if errors.Is(err, errors.ErrInvalidToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid or expired token", fiber.StatusBadRequest))
+		}
+		log.Error().Err(err).Msg("Failed to reset password")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not reset password", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[models.MessageResponse](models.MessageResponse{
+		Message: "Password has been reset",
+	})
 	return c.Status(fiber.StatusOK).JSON(response)
 }
+
+// rejectPassword responds with the reasons a password did not satisfy the
+// password policy, listed under the request field it was sent in
+func rejectPassword(c *fiber.Ctx, field string, err *errors.PasswordPolicyError) error {
+	return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateFieldErrorResponse("Password does not meet the password policy", fiber.StatusBadRequest, map[string][]string{
+		field: err.Reasons,
+	}))
+}
+
+// newAccessToken signs a short-lived access token for the user's session
+// acting in the organization, or the personal space if organizationID is 0
+func (h *AuthHandler) newAccessToken(user *models.User, sessionID string, organizationID uint) (string, error) {
+	now := time.Now()
+	return auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   user.Name,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(h.accessTokenTTL).Unix(),
+		},
+		UserID:         user.ID,
+		SessionID:      sessionID,
+		Roles:          user.RoleNames(),
+		Permissions:    user.PermissionNames(),
+		OrganizationID: organizationID,
+	})
+}
+
+// newMFAToken signs a short-lived token proving that the user's password was
+// correct. It can only be exchanged at the MFA verification endpoint.
+func (h *AuthHandler) newMFAToken(user *models.User) (string, error) {
+	now := time.Now()
+	return auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   user.Name,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(h.mfaTokenTTL).Unix(),
+		},
+		UserID:     user.ID,
+		MFAPending: true,
+	})
+}
@@ -3,13 +3,18 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/config"
 	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
 	"github.com/netf/gofiber-boilerplate/internal/errors"
 	"github.com/netf/gofiber-boilerplate/internal/models"
 	"github.com/netf/gofiber-boilerplate/internal/services"
@@ -20,6 +25,8 @@ import (
 
 var _ services.AuthService = (*MockAuthService)(nil)
 
+var testConfig = &config.Config{AccessTokenTTL: 15 * time.Minute, RefreshTokenTTL: 24 * time.Hour}
+
 // Update MockAuthService to implement AuthService
 type MockAuthService struct {
 	mock.Mock
@@ -35,6 +42,76 @@ func (m *MockAuthService) RegisterUser(name, pass, email string) (*models.User,
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockAuthService) CreateSession(userID uint, userAgent, ip string) (*models.RefreshToken, error) {
+	args := m.Called(userID, userAgent, ip)
+	token, _ := args.Get(0).(*models.RefreshToken)
+	return token, args.Error(1)
+}
+
+func (m *MockAuthService) RotateRefreshToken(refreshToken string) (*models.User, *models.RefreshToken, error) {
+	args := m.Called(refreshToken)
+	user, _ := args.Get(0).(*models.User)
+	token, _ := args.Get(1).(*models.RefreshToken)
+	return user, token, args.Error(2)
+}
+
+func (m *MockAuthService) Logout(userID uint, jti string, expiresAt time.Time, sessionID string) error {
+	args := m.Called(userID, jti, expiresAt, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) LogoutAll(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) SendVerificationEmail(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) VerifyEmail(token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) RequestPasswordReset(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResetPassword(token, password string) error {
+	args := m.Called(token, password)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) GetProfile(userID uint) (*models.User, error) {
+	args := m.Called(userID)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAuthService) UpdateProfile(userID uint, request models.UpdateProfileRequest) (*models.User, error) {
+	args := m.Called(userID, request)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAuthService) ChangePassword(userID uint, currentPassword, newPassword, currentSessionID string) error {
+	args := m.Called(userID, currentPassword, newPassword, currentSessionID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) RequestEmailChange(userID uint, email, password string) error {
+	args := m.Called(userID, email, password)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ConfirmEmailChange(token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
 func (m *MockAuthService) GenerateToken(user *models.User) (string, error) {
 	args := m.Called(user)
 	return args.String(0), args.Error(1)
@@ -55,6 +132,7 @@ func TestLogin(t *testing.T) {
 	validate := validator.New()
 	handler := &AuthHandler{
 		authService: mockService,
+		throttle:    newOpenThrottle(),
 		validate:    validate,
 	}
 
@@ -82,11 +160,35 @@ func TestLogin(t *testing.T) {
 			mockError:      errors.New("invalid credentials"),
 			expectedStatus: fiber.StatusUnauthorized,
 		},
+		{
+			name:           "Email Not Verified",
+			loginRequest:   models.LoginRequest{Name: "unverified", Pass: "password123"},
+			mockUser:       nil,
+			mockError:      errors.ErrEmailNotVerified,
+			expectedStatus: fiber.StatusForbidden,
+		},
+		{
+			name:           "Account Disabled",
+			loginRequest:   models.LoginRequest{Name: "disabled", Pass: "password123"},
+			mockUser:       nil,
+			mockError:      errors.ErrAccountDisabled,
+			expectedStatus: fiber.StatusForbidden,
+		},
+		{
+			name:           "Password Reset Required",
+			loginRequest:   models.LoginRequest{Name: "mustreset", Pass: "password123"},
+			mockUser:       nil,
+			mockError:      errors.ErrPasswordResetRequired,
+			expectedStatus: fiber.StatusForbidden,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockService.On("AuthenticateUser", tc.loginRequest.Name, tc.loginRequest.Pass).Return(tc.mockUser, tc.mockError)
+			if tc.mockError == nil {
+				mockService.On("CreateSession", tc.mockUser.ID, mock.Anything, mock.Anything).Return(&models.RefreshToken{FamilyID: "family", Token: "refresh-token"}, nil)
+			}
 
 			body, _ := json.Marshal(tc.loginRequest)
 			req := httptest.NewRequest("POST", "/auth/login", bytes.NewReader(body))
@@ -100,6 +202,49 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+func TestLoginThrottled(t *testing.T) {
+	mockService := new(MockAuthService)
+	throttle := new(MockLoginThrottleService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), throttle, new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/login", handler.Login)
+
+	throttle.On("Check", "locked", mock.Anything).Return(&errors.LockoutError{Err: errors.ErrAccountLocked, RetryAfter: 90 * time.Second})
+	throttle.On("Check", "from-busy-ip", mock.Anything).Return(&errors.LockoutError{Err: errors.ErrTooManyAttempts, RetryAfter: time.Second})
+	throttle.On("Check", "guessing", mock.Anything).Return(nil)
+	throttle.On("RecordFailure", "guessing", mock.Anything).Return(nil)
+	mockService.On("AuthenticateUser", "guessing", "wrong-password").Return((*models.User)(nil), errors.ErrInvalidCredentials)
+
+	testCases := []struct {
+		name               string
+		user               string
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{name: "Account Locked", user: "locked", expectedStatus: fiber.StatusLocked, expectedRetryAfter: "90"},
+		{name: "IP Throttled", user: "from-busy-ip", expectedStatus: fiber.StatusTooManyRequests, expectedRetryAfter: "1"},
+		{name: "Failure Recorded", user: "guessing", expectedStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.LoginRequest{Name: tc.user, Pass: "wrong-password"})
+			req := httptest.NewRequest("POST", "/auth/login", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tc.expectedRetryAfter, resp.Header.Get("Retry-After"))
+		})
+	}
+
+	mockService.AssertNotCalled(t, "AuthenticateUser", "locked", mock.Anything)
+	mockService.AssertExpectations(t)
+	throttle.AssertExpectations(t)
+}
+
 func TestRegister(t *testing.T) {
 	mockService := new(MockAuthService)
 	validate := validator.New()
@@ -132,6 +277,13 @@ func TestRegister(t *testing.T) {
 			mockError:       errors.ErrUserAlreadyExists,
 			expectedStatus:  fiber.StatusConflict,
 		},
+		{
+			name:            "Password Rejected",
+			registerRequest: models.RegisterRequest{Name: "weakuser", Pass: "password", Email: "weak@example.com"},
+			mockUser:        nil,
+			mockError:       &errors.PasswordPolicyError{Reasons: []string{"has appeared in a data breach and must not be used"}},
+			expectedStatus:  fiber.StatusBadRequest,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -152,42 +304,408 @@ func TestRegister(t *testing.T) {
 
 func TestLogout(t *testing.T) {
 	mockService := new(MockAuthService)
-	validate := validator.New()
-	handler := &AuthHandler{
-		authService: mockService,
-		validate:    validate,
-	}
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	expiresAt := time.Now().Add(time.Minute).Truncate(time.Second)
 
 	app := fiber.New()
-	app.Post("/auth/logout", handler.Logout)
+	app.Post("/auth/logout", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{
+			StandardClaims: jwt.StandardClaims{Id: "token-id", ExpiresAt: expiresAt.Unix()},
+			UserID:         1,
+			SessionID:      "family",
+		})
+		return handler.Logout(c)
+	})
+
+	mockService.On("Logout", uint(1), "token-id", expiresAt, "family").Return(nil)
 
 	req := httptest.NewRequest("POST", "/auth/logout", nil)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
 }
 
-func TestRefreshToken(t *testing.T) {
+func TestLogoutAll(t *testing.T) {
 	mockService := new(MockAuthService)
-	handler := NewAuthHandler(mockService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
 
 	app := fiber.New()
-	app.Post("/auth/refresh", func(c *fiber.Ctx) error {
-		// Set a valid user in the context
-		c.Locals("user", &models.User{ID: 1, Name: "testuser"})
-		return handler.RefreshToken(c)
+	app.Post("/auth/logout/all", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1})
+		return handler.LogoutAll(c)
 	})
 
-	req := httptest.NewRequest("POST", "/auth/refresh", nil)
+	mockService.On("LogoutAll", uint(1)).Return(nil)
+
+	req := httptest.NewRequest("POST", "/auth/logout/all", nil)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestLogoutAllWithDelegatedToken(t *testing.T) {
+	testCases := []struct {
+		name   string
+		claims *auth.Claims
+	}{
+		{name: "API Key", claims: &auth.Claims{UserID: 1, APIKeyID: 3}},
+		{name: "OAuth Client", claims: &auth.Claims{UserID: 1, ClientID: "gfbc_client"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
 
+			app := fiber.New()
+			app.Post("/auth/logout/all", func(c *fiber.Ctx) error {
+				c.Locals("claims", tc.claims)
+				return handler.LogoutAll(c)
+			})
+
+			req := httptest.NewRequest("POST", "/auth/logout/all", nil)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+			mockService.AssertNotCalled(t, "LogoutAll", mock.Anything)
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/refresh", handler.RefreshToken)
+
+	testCases := []struct {
+		name           string
+		refreshToken   string
+		mockUser       *models.User
+		mockToken      *models.RefreshToken
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Success",
+			refreshToken:   "valid-token",
+			mockUser:       &models.User{ID: 1, Name: "testuser"},
+			mockToken:      &models.RefreshToken{FamilyID: "family", Token: "rotated-token"},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "Expired Or Unknown Token",
+			refreshToken:   "unknown-token",
+			mockError:      errors.ErrInvalidToken,
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Reused Token",
+			refreshToken:   "rotated-token",
+			mockError:      errors.ErrTokenReused,
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService.On("RotateRefreshToken", tc.refreshToken).Return(tc.mockUser, tc.mockToken, tc.mockError)
+
+			body, _ := json.Marshal(models.RefreshTokenRequest{RefreshToken: tc.refreshToken})
+			req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			if tc.expectedStatus == fiber.StatusOK {
+				var result struct {
+					Data models.RefreshTokenResponse `json:"data"`
+				}
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+				assert.Equal(t, tc.mockToken.Token, result.Data.RefreshToken)
+				assert.NotEmpty(t, result.Data.Token)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefreshTokenMissingToken(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/refresh", handler.RefreshToken)
+
+	req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewReader([]byte("{}")))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+var cookieConfig = &config.Config{
+	AccessTokenTTL:     15 * time.Minute,
+	AuthCookies:        true,
+	AuthCookieSecure:   true,
+	AuthCookieSameSite: "Strict",
+}
+
+// responseCookies returns the cookies set by a response by name
+func responseCookies(resp *http.Response) map[string]*http.Cookie {
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range resp.Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+func TestLoginWithCookies(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), cookieConfig)
+
+	app := fiber.New()
+	app.Post("/auth/login", handler.Login)
+
+	user := &models.User{ID: 1, Name: "testuser"}
+	mockService.On("AuthenticateUser", "testuser", "password123").Return(user, nil)
+	mockService.On("CreateSession", uint(1), mock.Anything, mock.Anything).
+		Return(&models.RefreshToken{FamilyID: "family", Token: "refresh-token", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+
+	body, _ := json.Marshal(models.LoginRequest{Name: "testuser", Pass: "password123"})
+	req := httptest.NewRequest("POST", "/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Auth-Mode", "cookie")
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var decoded struct {
+		Data models.LoginResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	assert.Empty(t, decoded.Data.Token)
+	assert.Empty(t, decoded.Data.RefreshToken)
+	assert.NotEmpty(t, decoded.Data.CSRFToken)
+
+	cookies := responseCookies(resp)
+	for _, name := range []string{auth.AccessTokenCookie, auth.RefreshTokenCookie} {
+		assert.True(t, cookies[name].HttpOnly, name)
+		assert.True(t, cookies[name].Secure, name)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[name].SameSite, name)
+	}
+	assert.Equal(t, "refresh-token", cookies[auth.RefreshTokenCookie].Value)
+	assert.Equal(t, decoded.Data.CSRFToken, cookies[auth.CSRFCookie].Value)
+	assert.False(t, cookies[auth.CSRFCookie].HttpOnly)
+
+	claims, err := auth.ParseToken(cookies[auth.AccessTokenCookie].Value)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), claims.UserID)
+
+	// Without the header the tokens are returned in the body as before
+	req = httptest.NewRequest("POST", "/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Cookies())
+}
+
+func TestRefreshTokenFromCookie(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), cookieConfig)
+
+	app := fiber.New()
+	app.Post("/auth/refresh", handler.RefreshToken)
+
+	mockService.On("RotateRefreshToken", "valid-token").
+		Return(&models.User{ID: 1, Name: "testuser"}, &models.RefreshToken{FamilyID: "family", Token: "rotated-token", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	mockService.On("RotateRefreshToken", "rotated-token").Return(nil, nil, errors.ErrTokenReused)
+
+	refresh := func(token string) *http.Response {
+		req := httptest.NewRequest("POST", "/auth/refresh", nil)
+		req.AddCookie(&http.Cookie{Name: auth.RefreshTokenCookie, Value: token})
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	resp := refresh("valid-token")
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	cookies := responseCookies(resp)
+	assert.Equal(t, "rotated-token", cookies[auth.RefreshTokenCookie].Value)
+	assert.NotEmpty(t, cookies[auth.AccessTokenCookie].Value)
+	assert.NotEmpty(t, cookies[auth.CSRFCookie].Value)
+
+	// A rejected refresh token clears the cookies
+	resp = refresh("rotated-token")
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, responseCookies(resp)[auth.RefreshTokenCookie].Value)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/verify", handler.VerifyEmail)
+
+	mockService.On("VerifyEmail", "valid-token").Return(nil)
+	mockService.On("VerifyEmail", "used-token").Return(errors.ErrInvalidToken)
+
+	body, _ := json.Marshal(models.VerifyEmailRequest{Token: "valid-token"})
+	req := httptest.NewRequest("POST", "/auth/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	body, _ = json.Marshal(models.VerifyEmailRequest{Token: "used-token"})
+	req = httptest.NewRequest("POST", "/auth/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestForgotPassword(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/forgot", handler.ForgotPassword)
+
+	// Failures are not reported so that registered addresses cannot be
+	// discovered, and the response does not wait for the email to be sent
+	sent := make(chan time.Time)
+	mockService.On("RequestPasswordReset", "jane@example.com").WaitUntil(sent).Return(nil)
+	mockService.On("RequestPasswordReset", "broken@example.com").WaitUntil(sent).Return(errors.New("smtp unavailable"))
+
+	for _, email := range []string{"jane@example.com", "broken@example.com"} {
+		body, _ := json.Marshal(models.EmailRequest{Email: email})
+		req := httptest.NewRequest("POST", "/auth/forgot", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+	}
+	close(sent)
+
+	body, _ := json.Marshal(models.EmailRequest{Email: "not-an-email"})
+	req := httptest.NewRequest("POST", "/auth/forgot", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	assert.Eventually(t, func() bool {
+		return mockService.AssertExpectations(new(testing.T))
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestResendVerification(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/verify/resend", handler.ResendVerification)
+
+	sent := make(chan time.Time)
+	mockService.On("SendVerificationEmail", "jane@example.com").WaitUntil(sent).Return(nil)
+
+	body, _ := json.Marshal(models.EmailRequest{Email: "jane@example.com"})
+	req := httptest.NewRequest("POST", "/auth/verify/resend", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+	close(sent)
+
+	assert.Eventually(t, func() bool {
+		return mockService.AssertExpectations(new(testing.T))
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestResetPassword(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/reset", handler.ResetPassword)
+
+	testCases := []struct {
+		name           string
+		request        models.ResetPasswordRequest
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Success",
+			request:        models.ResetPasswordRequest{Token: "valid-token", Pass: "new-password"},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "Expired Token",
+			request:        models.ResetPasswordRequest{Token: "expired-token", Pass: "new-password"},
+			mockError:      errors.ErrInvalidToken,
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "Short Password",
+			request:        models.ResetPasswordRequest{Token: "valid-token", Pass: "short"},
+			mockError:      &errors.PasswordPolicyError{Reasons: []string{"must be at least 8 characters long"}},
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService.On("ResetPassword", tc.request.Token, tc.request.Pass).Return(tc.mockError)
+
+			body, _ := json.Marshal(tc.request)
+			req := httptest.NewRequest("POST", "/auth/reset", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPasswordPolicyReasons(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/register", handler.Register)
+
+	reasons := []string{"must be at least 8 characters long", "must not contain your name or email address"}
+	mockService.On("RegisterUser", "alice", "alice", "alice@example.com").Return((*models.User)(nil), &errors.PasswordPolicyError{Reasons: reasons})
+
+	body, _ := json.Marshal(models.RegisterRequest{Name: "alice", Pass: "alice", Email: "alice@example.com"})
+	req := httptest.NewRequest("POST", "/auth/register", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var response apiUtils.ErrorResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, map[string][]string{"pass": reasons}, response.Fields)
 }
 
 func TestLoginInvalidBody(t *testing.T) {
 	mockService := new(MockAuthService)
-	handler := NewAuthHandler(mockService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
 
 	app := fiber.New()
 	app.Post("/auth/login", handler.Login)
@@ -202,7 +720,7 @@ func TestLoginInvalidBody(t *testing.T) {
 
 func TestRegisterInvalidBody(t *testing.T) {
 	mockService := new(MockAuthService)
-	handler := NewAuthHandler(mockService)
+	handler := NewAuthHandler(mockService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
 
 	app := fiber.New()
 	app.Post("/auth/register", handler.Register)
@@ -238,11 +756,20 @@ func TestRegisterInvalidData(t *testing.T) {
 }
 
 func TestMain(m *testing.M) {
+	// Keep generated signing keys out of the source tree
+	keysDir, err := os.MkdirTemp("", "auth_keys")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create keys directory")
+	}
+	os.Setenv("AUTH_KEYS_DIR", keysDir)
+
 	// Initialize auth package
 	if err := auth.InitAuth(); err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize auth")
 	}
 
 	// Run the tests
-	os.Exit(m.Run())
+	code := m.Run()
+	os.RemoveAll(keysDir)
+	os.Exit(code)
 }
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+)
+
+// currentUserID returns the ID of the authenticated user from the JWT claims
+func currentUserID(c *fiber.Ctx) (uint, bool) {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok || claims.UserID == 0 {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// currentWorkspace returns the workspace the request acts in: the
+// organization in the token's claims, or the user's personal space. An API
+// key restricted to projects limits it to those projects.
+func currentWorkspace(c *fiber.Ctx) (models.Workspace, bool) {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok || claims.UserID == 0 {
+		return models.Workspace{}, false
+	}
+	return models.Workspace{UserID: claims.UserID, OrganizationID: claims.OrganizationID, ProjectIDs: claims.ProjectIDs}, true
+}
+
+// tokenUserID returns the current user ID, writing an error response if the
+// request is unauthenticated or was made with an API key, an OAuth client
+// token or an impersonation token. It guards endpoints that manage
+// credentials, which a scoped key, a third-party client or an admin acting
+// as the user must not be able to reach.
+func tokenUserID(c *fiber.Ctx, forbidden string) (uint, bool) {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok || claims.UserID == 0 {
+		c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+		return 0, false
+	}
+	if impersonating(c) {
+		return 0, false
+	}
+	if claims.APIKeyID != 0 || claims.ClientID != "" {
+		c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse(forbidden, fiber.StatusForbidden))
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// impersonating reports whether the request was made with an impersonation
+// token, writing an error response if so. It guards actions an admin must
+// not take on a user's behalf.
+func impersonating(c *fiber.Ctx) bool {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok || claims.Actor == nil {
+		return false
+	}
+	c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("Not allowed while impersonating", fiber.StatusForbidden))
+	return true
+}
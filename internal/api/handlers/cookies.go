@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/config"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+)
+
+// authModeHeader lets browser clients ask for the tokens of a login to be set
+// as cookies rather than returned in the response body
+const authModeHeader = "X-Auth-Mode"
+
+// oidcStateCookie holds the state of the external login the browser started,
+// so that the callback of a login started elsewhere is refused
+const oidcStateCookie = "oidc_state"
+
+// authCookies writes the token cookies of browser clients
+type authCookies struct {
+	enabled  bool
+	secure   bool
+	sameSite string
+	domain   string
+}
+
+func newAuthCookies(cfg *config.Config) authCookies {
+	return authCookies{
+		enabled:  cfg.AuthCookies,
+		secure:   cfg.AuthCookieSecure,
+		sameSite: cfg.AuthCookieSameSite,
+		domain:   cfg.AuthCookieDomain,
+	}
+}
+
+// requested reports whether cookies are enabled and the client asked for them
+func (a authCookies) requested(c *fiber.Ctx) bool {
+	return a.enabled && strings.EqualFold(c.Get(authModeHeader), "cookie")
+}
+
+// setTokens sets the access and refresh token cookies of a session together
+// with a new CSRF token, which it returns
+func (a authCookies) setTokens(c *fiber.Ctx, accessToken string, accessExpiresAt time.Time, refreshToken *models.RefreshToken) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(buf)
+
+	a.set(c, auth.AccessTokenCookie, accessToken, accessExpiresAt, true)
+	a.set(c, auth.RefreshTokenCookie, refreshToken.Token, refreshToken.ExpiresAt, true)
+	a.set(c, auth.CSRFCookie, csrfToken, refreshToken.ExpiresAt, false)
+	return csrfToken, nil
+}
+
+// setAccessToken replaces the access token cookie, keeping the session's
+// refresh token and CSRF token
+func (a authCookies) setAccessToken(c *fiber.Ctx, accessToken string, expiresAt time.Time) {
+	a.set(c, auth.AccessTokenCookie, accessToken, expiresAt, true)
+}
+
+// clear expires all token cookies
+func (a authCookies) clear(c *fiber.Ctx) {
+	expired := time.Unix(0, 0)
+	a.set(c, auth.AccessTokenCookie, "", expired, true)
+	a.set(c, auth.RefreshTokenCookie, "", expired, true)
+	a.set(c, auth.CSRFCookie, "", expired, false)
+}
+
+// setOIDCState ties an external login to the browser starting it. The cookie
+// is Lax so that it is sent with the provider's redirect back.
+func (a authCookies) setOIDCState(c *fiber.Ctx, state string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/",
+		Secure:   a.secure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// checkOIDCState reports whether state is the one of the external login the
+// browser started, and clears the cookie
+func (a authCookies) checkOIDCState(c *fiber.Ctx, state string) bool {
+	expected := c.Cookies(oidcStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		Secure:   a.secure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(state)) == 1
+}
+
+func (a authCookies) set(c *fiber.Ctx, name, value string, expires time.Time, httpOnly bool) {
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   a.domain,
+		Expires:  expires,
+		Secure:   a.secure,
+		HTTPOnly: httpOnly,
+		SameSite: a.sameSite,
+	})
+}
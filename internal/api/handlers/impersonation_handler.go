@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/netf/gofiber-boilerplate/config"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type ImpersonationHandler struct {
+	service  services.ImpersonationService
+	validate *validator.Validate
+	tokenTTL time.Duration
+}
+
+func NewImpersonationHandler(service services.ImpersonationService, cfg *config.Config) *ImpersonationHandler {
+	return &ImpersonationHandler{service: service, validate: validator.New(), tokenTTL: cfg.ImpersonationTTL}
+}
+
+// Impersonate issues a token to act as another user
+// @Summary Impersonate a user
+// @Description Issue a short-lived access token for the user that names the admin in its act claim. The token cannot be refreshed, cannot delete anything or manage the user's credentials, and every request made with it is recorded in the audit trail.
+// @Description Disabled users and other admins cannot be impersonated.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body models.ImpersonateRequest true "Why the user is impersonated"
+// @Success 200 {object} apiUtils.Response[models.ImpersonateResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/users/{id}/impersonate [post]
+// @Security ApiKeyAuth
+func (h *ImpersonationHandler) Impersonate(c *fiber.Ctx) error {
+	actorID, ok := tokenUserID(c, "Impersonation requires a login token")
+	if !ok {
+		return nil
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+	if uint(id) == actorID {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Cannot apply to your own account", fiber.StatusBadRequest))
+	}
+
+	var request models.ImpersonateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	tokenID := uuid.NewString()
+	actor, user, err := h.service.Start(actorID, uint(id), tokenID, request.Reason, c.IP())
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	now := time.Now()
+	token, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			Subject:   user.Name,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(h.tokenTTL).Unix(),
+		},
+		UserID:      user.ID,
+		Roles:       user.RoleNames(),
+		Permissions: user.PermissionNames(),
+		Actor:       &auth.Actor{Subject: actor.Name, UserID: actor.ID},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to sign impersonation token")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
+	}
+
+	log.Info().Uint("actor_id", actor.ID).Uint("user_id", user.ID).Str("token_id", tokenID).Msg("Impersonation started")
+
+	response := apiUtils.CreateResponse[models.ImpersonateResponse](models.ImpersonateResponse{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresIn: int64(h.tokenTTL.Seconds()),
+		User:      *user,
+	})
+	return c.JSON(response)
+}
+
+// ListLogs lists the impersonation audit trail
+// @Summary List impersonation audit entries
+// @Description Get a paginated list of impersonations and of the requests made while impersonating, newest first
+// @Tags Admin
+// @Produce json
+// @Param user_id query int false "Only entries for this impersonated user"
+// @Param page query int false "Page number" default(1) minimum(1)
+// @Param page_size query int false "Page size" default(10) minimum(1) maximum(100)
+// @Success 200 {object} apiUtils.Response[[]models.ImpersonationLog]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/impersonations [get]
+// @Security ApiKeyAuth
+func (h *ImpersonationHandler) ListLogs(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	pageSize := c.QueryInt("page_size", 10)
+	userID := c.QueryInt("user_id", 0)
+
+	if page < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid page number", fiber.StatusBadRequest))
+	}
+	if pageSize < 1 || pageSize > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid page size", fiber.StatusBadRequest))
+	}
+	if userID < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid user ID", fiber.StatusBadRequest))
+	}
+
+	entries, total, err := h.service.ListLogs(uint(userID), page, pageSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list impersonation logs")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not list impersonation logs", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[models.ImpersonationLog](entries, page, pageSize, int(total))
+	return c.JSON(response)
+}
+
+func (h *ImpersonationHandler) error(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errors.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("User not found", fiber.StatusNotFound))
+	case errors.Is(err, errors.ErrCannotImpersonate):
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("User cannot be impersonated", fiber.StatusForbidden))
+	}
+	log.Error().Err(err).Msg("Impersonation request failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not impersonate user", fiber.StatusInternalServerError))
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/config"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ services.ImpersonationService = (*MockImpersonationService)(nil)
+
+type MockImpersonationService struct {
+	mock.Mock
+}
+
+func (m *MockImpersonationService) Start(actorID, userID uint, tokenID, reason, ip string) (*models.User, *models.User, error) {
+	args := m.Called(actorID, userID, tokenID, reason, ip)
+	actor, _ := args.Get(0).(*models.User)
+	user, _ := args.Get(1).(*models.User)
+	return actor, user, args.Error(2)
+}
+
+func (m *MockImpersonationService) CheckActor(actorID uint) error {
+	args := m.Called(actorID)
+	return args.Error(0)
+}
+
+func (m *MockImpersonationService) Record(entry *models.ImpersonationLog) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockImpersonationService) ListLogs(userID uint, page, pageSize int) ([]models.ImpersonationLog, int64, error) {
+	args := m.Called(userID, page, pageSize)
+	entries, _ := args.Get(0).([]models.ImpersonationLog)
+	return entries, args.Get(1).(int64), args.Error(2)
+}
+
+func TestImpersonate(t *testing.T) {
+	service := new(MockImpersonationService)
+	handler := NewImpersonationHandler(service, &config.Config{ImpersonationTTL: 10 * time.Minute})
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/admin/users/:id/impersonate", handler.Impersonate)
+
+	service.On("Start", uint(1), uint(2), mock.Anything, "ticket 42", mock.Anything).
+		Return(&models.User{ID: 1, Name: "admin"}, &models.User{ID: 2, Name: "bob"}, nil)
+	service.On("Start", uint(1), uint(3), mock.Anything, "ticket 42", mock.Anything).Return(nil, nil, errors.ErrCannotImpersonate)
+	service.On("Start", uint(1), uint(4), mock.Anything, "ticket 42", mock.Anything).Return(nil, nil, errors.ErrUserNotFound)
+
+	testCases := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Success", path: "/admin/users/2/impersonate", body: `{"reason":"ticket 42"}`, expectedStatus: fiber.StatusOK},
+		{name: "Admin Or Disabled", path: "/admin/users/3/impersonate", body: `{"reason":"ticket 42"}`, expectedStatus: fiber.StatusForbidden},
+		{name: "Unknown User", path: "/admin/users/4/impersonate", body: `{"reason":"ticket 42"}`, expectedStatus: fiber.StatusNotFound},
+		{name: "Self", path: "/admin/users/1/impersonate", body: `{"reason":"ticket 42"}`, expectedStatus: fiber.StatusBadRequest},
+		{name: "Missing Reason", path: "/admin/users/2/impersonate", body: `{}`, expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			if tc.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data models.ImpersonateResponse `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, int64(600), body.Data.ExpiresIn)
+			assert.Equal(t, uint(2), body.Data.User.ID)
+
+			claims, err := auth.ParseToken(body.Data.Token)
+			require.NoError(t, err)
+			assert.Equal(t, uint(2), claims.UserID)
+			assert.Empty(t, claims.SessionID)
+			require.NotNil(t, claims.Actor)
+			assert.Equal(t, &auth.Actor{Subject: "admin", UserID: 1}, claims.Actor)
+
+			// The token ID recorded when impersonation started is the token's jti
+			assert.Equal(t, claims.Id, service.Calls[0].Arguments.String(2))
+		})
+	}
+}
+
+func TestImpersonateRequiresLoginToken(t *testing.T) {
+	service := new(MockImpersonationService)
+	handler := NewImpersonationHandler(service, testConfig)
+
+	app := fiber.New()
+	app.Post("/admin/users/:id/impersonate", func(c *fiber.Ctx) error {
+		// An impersonation token must not be able to start another impersonation
+		c.Locals("claims", &auth.Claims{UserID: 2, Actor: &auth.Actor{Subject: "admin", UserID: 1}})
+		return handler.Impersonate(c)
+	})
+
+	req := httptest.NewRequest("POST", "/admin/users/3/impersonate", strings.NewReader(`{"reason":"ticket 42"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+
+	service.AssertNotCalled(t, "Start", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListImpersonationLogs(t *testing.T) {
+	service := new(MockImpersonationService)
+	handler := NewImpersonationHandler(service, testConfig)
+
+	app := fiber.New()
+	app.Get("/admin/impersonations", handler.ListLogs)
+
+	entries := []models.ImpersonationLog{{ID: 2, ActorID: 1, UserID: 5, TokenID: "t", Method: "GET", Path: "/api/v1/todos", Status: 200}}
+	service.On("ListLogs", uint(5), 1, 10).Return(entries, int64(1), nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/admin/impersonations?user_id=5", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []models.ImpersonationLog `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, entries[0].Path, body.Data[0].Path)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/admin/impersonations?page_size=500", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	service.AssertExpectations(t)
+}
+
+func TestLogoutAllWhileImpersonating(t *testing.T) {
+	authService := new(MockAuthService)
+	handler := NewAuthHandler(authService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/logout/all", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 2, Actor: &auth.Actor{Subject: "admin", UserID: 1}})
+		return handler.LogoutAll(c)
+	})
+
+	resp, _ := app.Test(httptest.NewRequest("POST", "/auth/logout/all", nil))
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+
+	authService.AssertNotCalled(t, "LogoutAll", mock.Anything)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+)
+
+// JWKS serves the public keys that tokens issued by this API can be verified
+// with, in the standard JSON Web Key Set format expected by other services.
+// Retired keys are left out.
+func JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(auth.Keys.JWKS())
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type LabelHandler struct {
+	service  services.LabelService
+	validate *validator.Validate
+}
+
+func NewLabelHandler(service services.LabelService) *LabelHandler {
+	return &LabelHandler{service: service, validate: validator.New()}
+}
+
+// CreateLabel creates a label
+// @Summary Create a label
+// @Description Create a label in the active organization, or among the user's personal labels. Names are unique regardless of case.
+// @Tags Labels
+// @Accept json
+// @Produce json
+// @Param label body models.LabelRequest true "Name and color"
+// @Success 201 {object} apiUtils.Response[models.Label]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /labels [post]
+// @Security ApiKeyAuth
+func (h *LabelHandler) CreateLabel(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	request, ok := h.labelRequest(c)
+	if !ok {
+		return nil
+	}
+
+	label, err := h.service.CreateLabel(ws, request)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Label](*label)
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// ListLabels lists labels
+// @Summary List labels
+// @Description List the labels of the active organization, or the user's personal labels, by name
+// @Tags Labels
+// @Produce json
+// @Success 200 {object} apiUtils.Response[[]models.Label]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /labels [get]
+// @Security ApiKeyAuth
+func (h *LabelHandler) ListLabels(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	labels, err := h.service.ListLabels(ws)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[[]models.Label](labels)
+	return c.JSON(response)
+}
+
+// UpdateLabel renames or recolors a label
+// @Summary Update a label
+// @Description Replace the name and color of a label. Todos with the label show the change.
+// @Tags Labels
+// @Accept json
+// @Produce json
+// @Param id path int true "Label ID"
+// @Param label body models.LabelRequest true "Name and color"
+// @Success 200 {object} apiUtils.Response[models.Label]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /labels/{id} [put]
+// @Security ApiKeyAuth
+func (h *LabelHandler) UpdateLabel(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	request, ok := h.labelRequest(c)
+	if !ok {
+		return nil
+	}
+
+	label, err := h.service.UpdateLabel(ws, uint(id), request)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Label](*label)
+	return c.JSON(response)
+}
+
+// DeleteLabel deletes a label
+// @Summary Delete a label
+// @Description Delete a label and remove it from all todos
+// @Tags Labels
+// @Param id path int true "Label ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /labels/{id} [delete]
+// @Security ApiKeyAuth
+func (h *LabelHandler) DeleteLabel(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	if err := h.service.DeleteLabel(ws, uint(id)); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// labelRequest parses and validates the request body, writing an error
+// response if it is invalid
+func (h *LabelHandler) labelRequest(c *fiber.Ctx) (models.LabelRequest, bool) {
+	var request models.LabelRequest
+	if err := c.BodyParser(&request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+		return request, false
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+		return request, false
+	}
+	return request, true
+}
+
+func (h *LabelHandler) error(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errors.ErrResourceNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Label not found", fiber.StatusNotFound))
+	case errors.Is(err, errors.ErrLabelExists):
+		return c.Status(fiber.StatusConflict).JSON(apiUtils.CreateErrorResponse("A label with this name already exists", fiber.StatusConflict))
+	case errors.Is(err, errors.ErrProjectRestricted):
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("API key is restricted to projects", fiber.StatusForbidden))
+	}
+	log.Error().Err(err).Msg("Label request failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not process label", fiber.StatusInternalServerError))
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ services.LabelService = (*MockLabelService)(nil)
+
+type MockLabelService struct {
+	mock.Mock
+}
+
+func (m *MockLabelService) CreateLabel(ws models.Workspace, request models.LabelRequest) (*models.Label, error) {
+	args := m.Called(ws, request)
+	label, _ := args.Get(0).(*models.Label)
+	return label, args.Error(1)
+}
+
+func (m *MockLabelService) ListLabels(ws models.Workspace) ([]models.Label, error) {
+	args := m.Called(ws)
+	labels, _ := args.Get(0).([]models.Label)
+	return labels, args.Error(1)
+}
+
+func (m *MockLabelService) UpdateLabel(ws models.Workspace, id uint, request models.LabelRequest) (*models.Label, error) {
+	args := m.Called(ws, id, request)
+	label, _ := args.Get(0).(*models.Label)
+	return label, args.Error(1)
+}
+
+func (m *MockLabelService) DeleteLabel(ws models.Workspace, id uint) error {
+	args := m.Called(ws, id)
+	return args.Error(0)
+}
+
+func TestCreateLabel(t *testing.T) {
+	service := new(MockLabelService)
+	handler := NewLabelHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/labels", handler.CreateLabel)
+
+	service.On("CreateLabel", models.Workspace{UserID: 1}, models.LabelRequest{Name: "work", Color: "#ff0000"}).
+		Return(&models.Label{ID: 3, UserID: 1, Name: "work", Color: "#ff0000"}, nil)
+	service.On("CreateLabel", models.Workspace{UserID: 1}, models.LabelRequest{Name: "Work"}).Return(nil, errors.ErrLabelExists)
+
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Success", body: `{"name":"work","color":"#ff0000"}`, expectedStatus: fiber.StatusCreated},
+		{name: "Duplicate Name", body: `{"name":"Work"}`, expectedStatus: fiber.StatusConflict},
+		{name: "Missing Name", body: `{"color":"#ff0000"}`, expectedStatus: fiber.StatusBadRequest},
+		{name: "Invalid Color", body: `{"name":"work","color":"red"}`, expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/labels", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestListLabelsOfOrganization(t *testing.T) {
+	service := new(MockLabelService)
+	handler := NewLabelHandler(service)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, OrganizationID: 3})
+		return c.Next()
+	})
+	app.Get("/labels", handler.ListLabels)
+
+	labels := []models.Label{{ID: 1, Name: "backend"}, {ID: 2, Name: "urgent"}}
+	service.On("ListLabels", models.Workspace{UserID: 1, OrganizationID: 3}).Return(labels, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/labels", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []models.Label `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, labels, body.Data)
+	service.AssertExpectations(t)
+}
+
+func TestUpdateLabel(t *testing.T) {
+	service := new(MockLabelService)
+	handler := NewLabelHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Put("/labels/:id", handler.UpdateLabel)
+
+	service.On("UpdateLabel", models.Workspace{UserID: 1}, uint(2), models.LabelRequest{Name: "later"}).
+		Return(&models.Label{ID: 2, UserID: 1, Name: "later"}, nil)
+	service.On("UpdateLabel", models.Workspace{UserID: 1}, uint(5), models.LabelRequest{Name: "later"}).Return(nil, errors.ErrResourceNotFound)
+
+	testCases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{name: "Success", path: "/labels/2", expectedStatus: fiber.StatusOK},
+		{name: "Not Found", path: "/labels/5", expectedStatus: fiber.StatusNotFound},
+		{name: "Invalid ID", path: "/labels/abc", expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", tc.path, strings.NewReader(`{"name":"later"}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestDeleteLabel(t *testing.T) {
+	service := new(MockLabelService)
+	handler := NewLabelHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Delete("/labels/:id", handler.DeleteLabel)
+
+	service.On("DeleteLabel", models.Workspace{UserID: 1}, uint(2)).Return(nil)
+	service.On("DeleteLabel", models.Workspace{UserID: 1}, uint(5)).Return(errors.ErrResourceNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/labels/2", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/labels/5", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	service.AssertExpectations(t)
+}
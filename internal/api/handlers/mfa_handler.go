@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type MFAHandler struct {
+	service  services.MFAService
+	validate *validator.Validate
+}
+
+func NewMFAHandler(service services.MFAService) *MFAHandler {
+	return &MFAHandler{service: service, validate: validator.New()}
+}
+
+// Enroll starts two-factor enrollment for the current user
+// @Summary Start two-factor enrollment
+// @Description Generate a new TOTP secret. It is not active until a code for it is sent to /auth/mfa/confirm.
+// @Tags MFA
+// @Produce json
+// @Success 200 {object} apiUtils.Response[models.MFAEnrollResponse]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/mfa/enroll [post]
+// @Security ApiKeyAuth
+func (h *MFAHandler) Enroll(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	enrollment, err := h.service.BeginEnrollment(userID)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.MFAEnrollResponse](*enrollment)
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// Confirm enables two-factor authentication for the current user
+// @Summary Confirm two-factor enrollment
+// @Description Enable two-factor authentication with a code for the secret from /auth/mfa/enroll and return recovery codes.
+// @Description The recovery codes are returned only once.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param code body models.MFACodeRequest true "TOTP code"
+// @Success 200 {object} apiUtils.Response[models.MFARecoveryCodesResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/mfa/confirm [post]
+// @Security ApiKeyAuth
+func (h *MFAHandler) Confirm(c *fiber.Ctx) error {
+	userID, request, ok := h.codeRequest(c)
+	if !ok {
+		return nil
+	}
+
+	codes, err := h.service.ConfirmEnrollment(userID, request.Code)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.MFARecoveryCodesResponse](models.MFARecoveryCodesResponse{
+		RecoveryCodes: codes,
+	})
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// Disable turns off two-factor authentication for the current user
+// @Summary Disable two-factor authentication
+// @Tags MFA
+// @Accept json
+// @Param code body models.MFACodeRequest true "TOTP or recovery code"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/mfa/disable [post]
+// @Security ApiKeyAuth
+func (h *MFAHandler) Disable(c *fiber.Ctx) error {
+	userID, request, ok := h.codeRequest(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.service.Disable(userID, request.Code); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the current user
+// @Summary Regenerate recovery codes
+// @Description Invalidate all recovery codes and return new ones. The recovery codes are returned only once.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param code body models.MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} apiUtils.Response[models.MFARecoveryCodesResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/mfa/recovery-codes [post]
+// @Security ApiKeyAuth
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID, request, ok := h.codeRequest(c)
+	if !ok {
+		return nil
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(userID, request.Code)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.MFARecoveryCodesResponse](models.MFARecoveryCodesResponse{
+		RecoveryCodes: codes,
+	})
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func (h *MFAHandler) tokenUser(c *fiber.Ctx) (uint, bool) {
+	return tokenUserID(c, "Two-factor authentication cannot be managed with an API key")
+}
+
+// codeRequest resolves the current user and parses a code from the body,
+// writing an error response if either fails
+func (h *MFAHandler) codeRequest(c *fiber.Ctx) (uint, models.MFACodeRequest, bool) {
+	var request models.MFACodeRequest
+
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return 0, request, false
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+		return 0, request, false
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+		return 0, request, false
+	}
+
+	return userID, request, true
+}
+
+func (h *MFAHandler) error(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errors.ErrInvalidMFACode):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid code", fiber.StatusBadRequest))
+	case errors.Is(err, errors.ErrMFANotEnabled):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Two-factor authentication is not enabled", fiber.StatusBadRequest))
+	case errors.Is(err, errors.ErrMFAAlreadyEnabled):
+		return c.Status(fiber.StatusConflict).JSON(apiUtils.CreateErrorResponse("Two-factor authentication is already enabled", fiber.StatusConflict))
+	case errors.Is(err, errors.ErrUserNotFound):
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+	log.Error().Err(err).Msg("Two-factor authentication request failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not update two-factor authentication", fiber.StatusInternalServerError))
+}
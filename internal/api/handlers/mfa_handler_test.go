@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.MFAService = (*MockMFAService)(nil)
+
+type MockMFAService struct {
+	mock.Mock
+}
+
+func (m *MockMFAService) BeginEnrollment(userID uint) (*models.MFAEnrollResponse, error) {
+	args := m.Called(userID)
+	enrollment, _ := args.Get(0).(*models.MFAEnrollResponse)
+	return enrollment, args.Error(1)
+}
+
+func (m *MockMFAService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	args := m.Called(userID, code)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
+func (m *MockMFAService) Disable(userID uint, code string) error {
+	args := m.Called(userID, code)
+	return args.Error(0)
+}
+
+func (m *MockMFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	args := m.Called(userID, code)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
+func (m *MockMFAService) VerifyLogin(userID uint, code string) (*models.User, error) {
+	args := m.Called(userID, code)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+var _ services.RevocationService = (*MockRevocationService)(nil)
+
+type MockRevocationService struct {
+	mock.Mock
+}
+
+func (m *MockRevocationService) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	args := m.Called(jti, userID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevocationService) RevokeSession(userID uint, sessionID string) error {
+	args := m.Called(userID, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockRevocationService) RevokeAllForUser(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockRevocationService) IsRevoked(jti string, sessionID string, userID uint, issuedAt time.Time) (bool, error) {
+	args := m.Called(jti, sessionID, userID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestLoginWithMFA(t *testing.T) {
+	mockService := new(MockAuthService)
+	mockMFA := new(MockMFAService)
+	revocations := new(MockRevocationService)
+	handler := NewAuthHandler(mockService, mockMFA, newOpenThrottle(), revocations, testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/login", handler.Login)
+	app.Post("/auth/mfa/verify", handler.VerifyMFA)
+
+	user := &models.User{ID: 1, Name: "testuser", TOTPEnabled: true}
+	mockService.On("AuthenticateUser", "testuser", "password123").Return(user, errors.ErrMFARequired)
+
+	body, _ := json.Marshal(models.LoginRequest{Name: "testuser", Pass: "password123"})
+	req := httptest.NewRequest("POST", "/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var login struct {
+		Data models.LoginResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+	assert.True(t, login.Data.MFARequired)
+	assert.Empty(t, login.Data.Token)
+	assert.Empty(t, login.Data.RefreshToken)
+	assert.NotEmpty(t, login.Data.MFAToken)
+	mockService.AssertNotCalled(t, "CreateSession", mock.Anything, mock.Anything, mock.Anything)
+
+	mockMFA.On("VerifyLogin", uint(1), "000000").Return(nil, errors.ErrInvalidMFACode)
+	mockMFA.On("VerifyLogin", uint(1), "123456").Return(user, nil)
+	mockService.On("CreateSession", uint(1), mock.Anything, mock.Anything).Return(&models.RefreshToken{FamilyID: "family", Token: "refresh-token"}, nil)
+
+	claims, err := auth.ParseToken(login.Data.MFAToken)
+	assert.NoError(t, err)
+	revocations.On("IsRevoked", claims.Id, "", uint(1), mock.Anything).Return(false, nil).Twice()
+	revocations.On("RevokeToken", claims.Id, uint(1), time.Unix(claims.ExpiresAt, 0)).Return(nil).Once()
+
+	body, _ = json.Marshal(models.MFAVerifyRequest{MFAToken: login.Data.MFAToken, Code: "000000"})
+	req = httptest.NewRequest("POST", "/auth/mfa/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	body, _ = json.Marshal(models.MFAVerifyRequest{MFAToken: login.Data.MFAToken, Code: "123456"})
+	req = httptest.NewRequest("POST", "/auth/mfa/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var verified struct {
+		Data models.LoginResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&verified))
+	assert.NotEmpty(t, verified.Data.Token)
+	assert.Equal(t, "refresh-token", verified.Data.RefreshToken)
+
+	// The token is used up by the successful verification
+	revocations.On("IsRevoked", claims.Id, "", uint(1), mock.Anything).Return(true, nil).Once()
+
+	req = httptest.NewRequest("POST", "/auth/mfa/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+	mockMFA.AssertExpectations(t)
+	revocations.AssertExpectations(t)
+	mockMFA.AssertNumberOfCalls(t, "VerifyLogin", 2)
+}
+
+func TestVerifyMFARejectsAccessToken(t *testing.T) {
+	mockMFA := new(MockMFAService)
+	handler := NewAuthHandler(new(MockAuthService), mockMFA, newOpenThrottle(), new(MockRevocationService), testConfig)
+
+	app := fiber.New()
+	app.Post("/auth/mfa/verify", handler.VerifyMFA)
+
+	accessToken, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()},
+		UserID:         1,
+	})
+	assert.NoError(t, err)
+
+	body, _ := json.Marshal(models.MFAVerifyRequest{MFAToken: accessToken, Code: "123456"})
+	req := httptest.NewRequest("POST", "/auth/mfa/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	mockMFA.AssertNotCalled(t, "VerifyLogin", mock.Anything, mock.Anything)
+}
+
+func TestMFAEnroll(t *testing.T) {
+	mockService := new(MockMFAService)
+	handler := NewMFAHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/auth/mfa/enroll", handler.Enroll)
+
+	mockService.On("BeginEnrollment", uint(1)).Return(&models.MFAEnrollResponse{Secret: "SECRET", ProvisioningURI: "otpauth://totp/x"}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("POST", "/auth/mfa/enroll", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestMFAEnrollWithAPIKey(t *testing.T) {
+	mockService := new(MockMFAService)
+	handler := NewMFAHandler(mockService)
+
+	app := fiber.New()
+	app.Post("/auth/mfa/enroll", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, APIKeyID: 3})
+		return handler.Enroll(c)
+	})
+
+	resp, _ := app.Test(httptest.NewRequest("POST", "/auth/mfa/enroll", nil))
+
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	mockService.AssertNotCalled(t, "BeginEnrollment", mock.Anything)
+}
+
+func TestMFAConfirm(t *testing.T) {
+	mockService := new(MockMFAService)
+	handler := NewMFAHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/auth/mfa/confirm", handler.Confirm)
+
+	testCases := []struct {
+		name           string
+		code           string
+		mockCodes      []string
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Success",
+			code:           "123456",
+			mockCodes:      []string{"ABCD-EFGH"},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "Invalid Code",
+			code:           "000000",
+			mockError:      errors.ErrInvalidMFACode,
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "Already Enabled",
+			code:           "111111",
+			mockError:      errors.ErrMFAAlreadyEnabled,
+			expectedStatus: fiber.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService.On("ConfirmEnrollment", uint(1), tc.code).Return(tc.mockCodes, tc.mockError)
+
+			body, _ := json.Marshal(models.MFACodeRequest{Code: tc.code})
+			req := httptest.NewRequest("POST", "/auth/mfa/confirm", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			if tc.expectedStatus == fiber.StatusOK {
+				var result struct {
+					Data models.MFARecoveryCodesResponse `json:"data"`
+				}
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+				assert.Equal(t, tc.mockCodes, result.Data.RecoveryCodes)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMFADisable(t *testing.T) {
+	mockService := new(MockMFAService)
+	handler := NewMFAHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/auth/mfa/disable", handler.Disable)
+
+	mockService.On("Disable", uint(1), "123456").Return(nil)
+
+	body, _ := json.Marshal(models.MFACodeRequest{Code: "123456"})
+	req := httptest.NewRequest("POST", "/auth/mfa/disable", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/netf/gofiber-boilerplate/config"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type OAuthHandler struct {
+	service        services.OAuthService
+	validate       *validator.Validate
+	accessTokenTTL time.Duration
+}
+
+func NewOAuthHandler(service services.OAuthService, cfg *config.Config) *OAuthHandler {
+	return &OAuthHandler{
+		service:        service,
+		validate:       validator.New(),
+		accessTokenTTL: cfg.AccessTokenTTL,
+	}
+}
+
+// Authorize validates an authorization request for the consent screen
+// @Summary Start an OAuth authorization
+// @Description Validate the authorization request of a third-party client and describe what the current user is asked to approve.
+// @Description The consent screen shows this to the user and posts the decision back with the same parameters.
+// @Tags OAuth
+// @Produce json
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string false "Registered redirect URI"
+// @Param scope query string false "Space separated scopes"
+// @Param state query string false "Opaque client state"
+// @Param code_challenge query string true "PKCE code challenge"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 200 {object} apiUtils.Response[models.OAuthConsentResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /oauth/authorize [get]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) Authorize(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.OAuthAuthorizeRequest
+	if err := c.QueryParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid query parameters", fiber.StatusBadRequest))
+	}
+
+	consent, err := h.service.Authorize(userID, request)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.OAuthConsentResponse](*consent)
+	return c.JSON(response)
+}
+
+// Decide records the user's decision on the consent screen
+// @Summary Approve or deny an OAuth authorization
+// @Description Approve or deny the authorization request and return the client URL to redirect the user to, which carries an authorization code or an access_denied error.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Param decision body models.OAuthAuthorizeRequest true "Authorization request and decision"
+// @Success 200 {object} apiUtils.Response[models.OAuthRedirectResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /oauth/authorize [post]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) Decide(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.OAuthAuthorizeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	redirectTo, err := h.service.Decide(userID, request)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.OAuthRedirectResponse](models.OAuthRedirectResponse{RedirectTo: redirectTo})
+	return c.JSON(response)
+}
+
+// Token issues access tokens to clients
+// @Summary OAuth token endpoint
+// @Description Exchange an authorization code (grant_type=authorization_code, with code_verifier) or client credentials (grant_type=client_credentials) for an access token.
+// @Description Clients authenticate with HTTP Basic or client_id and client_secret in the body. Responses follow RFC 6749 and are not wrapped in data.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token body models.OAuthTokenRequest true "Token request"
+// @Success 200 {object} models.OAuthTokenResponse
+// @Failure 400 {object} models.OAuthErrorResponse
+// @Failure 401 {object} models.OAuthErrorResponse
+// @Router /oauth/token [post]
+func (h *OAuthHandler) Token(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	var request models.OAuthTokenRequest
+	if err := c.BodyParser(&request); err != nil {
+		return h.tokenError(c, errors.NewOAuthError(errors.OAuthInvalidRequest, "invalid request body"))
+	}
+
+	if clientID, secret, ok := basicAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		request.ClientID, request.ClientSecret = clientID, secret
+	}
+
+	var grant *services.OAuthGrant
+	var err error
+	switch request.GrantType {
+	case "authorization_code":
+		grant, err = h.service.ExchangeCode(request)
+	case "client_credentials":
+		grant, err = h.service.ClientCredentials(request)
+	default:
+		err = errors.NewOAuthError(errors.OAuthUnsupportedGrantType, "supported grant types are authorization_code and client_credentials")
+	}
+	if err != nil {
+		return h.tokenError(c, err)
+	}
+
+	now := time.Now()
+	accessToken, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   grant.User.Name,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(h.accessTokenTTL).Unix(),
+		},
+		UserID:      grant.User.ID,
+		Permissions: grant.Scopes,
+		ClientID:    grant.ClientID,
+	})
+	if err != nil {
+		return h.tokenError(c, err)
+	}
+
+	return c.JSON(models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(h.accessTokenTTL.Seconds()),
+		Scope:       strings.Join(grant.Scopes, " "),
+	})
+}
+
+// CreateClient registers a third-party client
+// @Summary Register an OAuth client
+// @Description Register a client. Confidential clients get a client_secret, which is shown only once. Setting user_id enables the client credentials grant, with tokens acting as that user.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param client body models.CreateOAuthClientRequest true "Client details"
+// @Success 201 {object} apiUtils.Response[models.CreateOAuthClientResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/oauth/clients [post]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) CreateClient(c *fiber.Ctx) error {
+	var request models.CreateOAuthClientRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	client, err := h.service.CreateClient(request)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidInput) {
+			return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+		}
+		log.Error().Err(err).Msg("Failed to create OAuth client")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not create client", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[models.CreateOAuthClientResponse](models.CreateOAuthClientResponse{
+		OAuthClient:  *client,
+		ClientSecret: client.Secret,
+	})
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// ListClients lists the registered clients
+// @Summary List OAuth clients
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} apiUtils.Response[[]models.OAuthClient]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/oauth/clients [get]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) ListClients(c *fiber.Ctx) error {
+	clients, err := h.service.ListClients()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list OAuth clients")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not list clients", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[[]models.OAuthClient](clients)
+	return c.JSON(response)
+}
+
+// RevokeClient revokes a client
+// @Summary Revoke an OAuth client
+// @Description Stop the client from obtaining tokens. Access tokens already issued stay valid until they expire.
+// @Tags Admin
+// @Param id path int true "Client ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /admin/oauth/clients/{id} [delete]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) RevokeClient(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	if err := h.service.RevokeClient(uint(id)); err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Client not found", fiber.StatusNotFound))
+		}
+		log.Error().Err(err).Msg("Failed to revoke OAuth client")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not revoke client", fiber.StatusInternalServerError))
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *OAuthHandler) tokenUser(c *fiber.Ctx) (uint, bool) {
+	return tokenUserID(c, "Clients cannot be authorized with an API key")
+}
+
+// error maps errors of the authorization endpoints
+func (h *OAuthHandler) error(c *fiber.Ctx, err error) error {
+	var oauthErr *errors.OAuthError
+	if errors.As(err, &oauthErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(oauthErr.Description, fiber.StatusBadRequest))
+	}
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+	log.Error().Err(err).Msg("OAuth authorization failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not authorize client", fiber.StatusInternalServerError))
+}
+
+// tokenError writes an RFC 6749 error response for the token endpoint
+func (h *OAuthHandler) tokenError(c *fiber.Ctx, err error) error {
+	var oauthErr *errors.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Error().Err(err).Msg("Failed to issue OAuth token")
+		return c.Status(fiber.StatusInternalServerError).JSON(models.OAuthErrorResponse{Error: "server_error"})
+	}
+
+	status := fiber.StatusBadRequest
+	if oauthErr.Code == errors.OAuthInvalidClient {
+		status = fiber.StatusUnauthorized
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	return c.Status(status).JSON(models.OAuthErrorResponse{
+		Error:            oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+	})
+}
+
+// basicAuth parses HTTP Basic client credentials, which RFC 6749 section
+// 2.3.1 requires to be form-encoded before they are base64 encoded
+func basicAuth(header string) (clientID, secret string, ok bool) {
+	scheme, encoded, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+	rawID, rawSecret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+	if clientID, err = url.QueryUnescape(rawID); err != nil {
+		return "", "", false
+	}
+	if secret, err = url.QueryUnescape(rawSecret); err != nil {
+		return "", "", false
+	}
+	return clientID, secret, true
+}
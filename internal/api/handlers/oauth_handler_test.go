@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ services.OAuthService = (*MockOAuthService)(nil)
+
+type MockOAuthService struct {
+	mock.Mock
+}
+
+func (m *MockOAuthService) CreateClient(request models.CreateOAuthClientRequest) (*models.OAuthClient, error) {
+	args := m.Called(request)
+	client, _ := args.Get(0).(*models.OAuthClient)
+	return client, args.Error(1)
+}
+
+func (m *MockOAuthService) ListClients() ([]models.OAuthClient, error) {
+	args := m.Called()
+	clients, _ := args.Get(0).([]models.OAuthClient)
+	return clients, args.Error(1)
+}
+
+func (m *MockOAuthService) RevokeClient(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOAuthService) Authorize(userID uint, request models.OAuthAuthorizeRequest) (*models.OAuthConsentResponse, error) {
+	args := m.Called(userID, request)
+	consent, _ := args.Get(0).(*models.OAuthConsentResponse)
+	return consent, args.Error(1)
+}
+
+func (m *MockOAuthService) Decide(userID uint, request models.OAuthAuthorizeRequest) (string, error) {
+	args := m.Called(userID, request)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOAuthService) ExchangeCode(request models.OAuthTokenRequest) (*services.OAuthGrant, error) {
+	args := m.Called(request)
+	grant, _ := args.Get(0).(*services.OAuthGrant)
+	return grant, args.Error(1)
+}
+
+func (m *MockOAuthService) ClientCredentials(request models.OAuthTokenRequest) (*services.OAuthGrant, error) {
+	args := m.Called(request)
+	grant, _ := args.Get(0).(*services.OAuthGrant)
+	return grant, args.Error(1)
+}
+
+// postTokenForm posts a token request, authenticating with HTTP Basic if
+// basic is set, and returns the status and body of the response
+func postTokenForm(app *fiber.App, form url.Values, basic string) (int, []byte) {
+	req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basic != "" {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(basic)))
+	}
+	resp, _ := app.Test(req)
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func TestOAuthTokenAuthorizationCode(t *testing.T) {
+	service := new(MockOAuthService)
+	handler := NewOAuthHandler(service, testConfig)
+
+	app := fiber.New()
+	app.Post("/oauth/token", handler.Token)
+
+	expected := models.OAuthTokenRequest{
+		GrantType:    "authorization_code",
+		Code:         "the-code",
+		RedirectURI:  "https://partner.example.com/cb",
+		CodeVerifier: "verifier",
+		ClientID:     "gfbc_client",
+		ClientSecret: "s3cret",
+	}
+	service.On("ExchangeCode", expected).Return(&services.OAuthGrant{
+		User:     &models.User{ID: 4, Name: "alice"},
+		ClientID: "gfbc_client",
+		Scopes:   []string{models.PermTodosRead},
+	}, nil)
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"the-code"},
+		"redirect_uri":  {"https://partner.example.com/cb"},
+		"code_verifier": {"verifier"},
+	}
+	status, raw := postTokenForm(app, form, "gfbc_client:s3cret")
+	require.Equal(t, fiber.StatusOK, status)
+
+	var body models.OAuthTokenResponse
+	require.NoError(t, json.Unmarshal(raw, &body))
+	assert.Equal(t, "Bearer", body.TokenType)
+	assert.Equal(t, models.PermTodosRead, body.Scope)
+
+	claims, err := auth.ParseToken(body.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, uint(4), claims.UserID)
+	assert.Equal(t, "gfbc_client", claims.ClientID)
+	assert.Equal(t, []string{models.PermTodosRead}, claims.Permissions)
+	assert.Empty(t, claims.Roles)
+
+	service.AssertExpectations(t)
+}
+
+func TestOAuthTokenErrors(t *testing.T) {
+	service := new(MockOAuthService)
+	handler := NewOAuthHandler(service, testConfig)
+
+	app := fiber.New()
+	app.Post("/oauth/token", handler.Token)
+
+	service.On("ClientCredentials", mock.Anything).Return(nil, errors.NewOAuthError(errors.OAuthInvalidClient, "client authentication failed"))
+	service.On("ExchangeCode", mock.Anything).Return(nil, errors.NewOAuthError(errors.OAuthInvalidGrant, "invalid or expired code"))
+
+	testCases := []struct {
+		name           string
+		grantType      string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Unsupported Grant", grantType: "password", expectedStatus: fiber.StatusBadRequest, expectedError: errors.OAuthUnsupportedGrantType},
+		{name: "Invalid Client", grantType: "client_credentials", expectedStatus: fiber.StatusUnauthorized, expectedError: errors.OAuthInvalidClient},
+		{name: "Invalid Grant", grantType: "authorization_code", expectedStatus: fiber.StatusBadRequest, expectedError: errors.OAuthInvalidGrant},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, raw := postTokenForm(app, url.Values{"grant_type": {tc.grantType}, "client_id": {"gfbc_client"}}, "")
+			assert.Equal(t, tc.expectedStatus, status)
+
+			var body models.OAuthErrorResponse
+			require.NoError(t, json.Unmarshal(raw, &body))
+			assert.Equal(t, tc.expectedError, body.Error)
+		})
+	}
+}
+
+func TestOAuthDecide(t *testing.T) {
+	service := new(MockOAuthService)
+	handler := NewOAuthHandler(service, testConfig)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/oauth/authorize", handler.Decide)
+
+	request := models.OAuthAuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "gfbc_client",
+		State:               "xyz",
+		CodeChallenge:       "challenge",
+		CodeChallengeMethod: "S256",
+		Approve:             true,
+	}
+	service.On("Decide", uint(1), request).Return("https://partner.example.com/cb?code=abc&state=xyz", nil)
+
+	body, _ := json.Marshal(request)
+	req := httptest.NewRequest("POST", "/oauth/authorize", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var decoded struct {
+		Data models.OAuthRedirectResponse `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	assert.Equal(t, "https://partner.example.com/cb?code=abc&state=xyz", decoded.Data.RedirectTo)
+
+	service.AssertExpectations(t)
+}
+
+func TestOAuthAuthorizeRejectsDelegatedCredentials(t *testing.T) {
+	service := new(MockOAuthService)
+	handler := NewOAuthHandler(service, testConfig)
+
+	app := fiber.New()
+	app.Get("/oauth/authorize", func(c *fiber.Ctx) error {
+		// A client must not be able to authorize other clients with its own token
+		c.Locals("claims", &auth.Claims{UserID: 1, ClientID: "gfbc_client"})
+		return handler.Authorize(c)
+	})
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/oauth/authorize?client_id=gfbc_other&response_type=code", nil))
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+
+	service.AssertNotCalled(t, "Authorize", mock.Anything, mock.Anything)
+}
+
+func TestBasicAuth(t *testing.T) {
+	encode := func(s string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	clientID, secret, ok := basicAuth(encode("my%3Aclient:p%40ss"))
+	assert.True(t, ok)
+	assert.Equal(t, "my:client", clientID)
+	assert.Equal(t, "p@ss", secret)
+
+	_, _, ok = basicAuth("Bearer token")
+	assert.False(t, ok)
+
+	_, _, ok = basicAuth(encode("no-colon"))
+	assert.False(t, ok)
+}
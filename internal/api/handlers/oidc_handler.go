@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type OIDCHandler struct {
+	service services.OIDCService
+	auth    *AuthHandler
+}
+
+// NewOIDCHandler creates a handler for external logins. Completed logins are
+// handed to auth so that they get the same tokens as password logins.
+func NewOIDCHandler(service services.OIDCService, auth *AuthHandler) *OIDCHandler {
+	return &OIDCHandler{service: service, auth: auth}
+}
+
+// ListProviders lists the external login providers
+// @Summary List login providers
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} apiUtils.Response[models.OIDCProvidersResponse]
+// @Router /auth/oidc/providers [get]
+func (h *OIDCHandler) ListProviders(c *fiber.Ctx) error {
+	response := apiUtils.CreateResponse[models.OIDCProvidersResponse](models.OIDCProvidersResponse{
+		Providers: h.service.Providers(),
+	})
+	return c.JSON(response)
+}
+
+// Login starts a login with an external provider
+// @Summary Log in with an external provider
+// @Description Redirect to the provider's login page. The provider redirects back to the callback endpoint, which must be reached from the same browser.
+// @Tags Authentication
+// @Param provider path string true "Provider name"
+// @Success 302 "Found"
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Failure 502 {object} apiUtils.ErrorResponse
+// @Router /auth/oidc/{provider}/login [get]
+func (h *OIDCHandler) Login(c *fiber.Ctx) error {
+	authURL, state, err := h.service.BeginLogin(c.Context(), c.Params("provider"))
+	if err != nil {
+		if errors.Is(err, errors.ErrUnknownProvider) {
+			return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Unknown provider", fiber.StatusNotFound))
+		}
+		if errors.Is(err, errors.ErrExternalLoginFailed) {
+			return c.Status(fiber.StatusBadGateway).JSON(apiUtils.CreateErrorResponse("Login provider unavailable", fiber.StatusBadGateway))
+		}
+		log.Error().Err(err).Msg("Failed to start external login")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not start login", fiber.StatusInternalServerError))
+	}
+
+	h.auth.cookies.setOIDCState(c, state)
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// Callback completes a login with an external provider
+// @Summary External login callback
+// @Description Exchange the authorization code the provider redirected back with for an access token and a refresh token.
+// @Description The state must match the oidc_state cookie set when the login was started, so a callback URL cannot be completed in another browser.
+// @Description The external account is linked to the user with the same verified email address, and a new user is created if there is none.
+// @Description As with /auth/login, mfa_required is set instead if the user has two-factor authentication enabled.
+// @Tags Authentication
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} apiUtils.Response[models.LoginResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	if c.Query("error") != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Login was cancelled or denied", fiber.StatusUnauthorized))
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Missing code or state", fiber.StatusBadRequest))
+	}
+
+	// Otherwise a victim could be sent the callback URL of the attacker's own
+	// login and end up logged in to the attacker's account
+	if !h.auth.cookies.checkOIDCState(c, state) {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid or expired state", fiber.StatusBadRequest))
+	}
+
+	user, err := h.service.CompleteLogin(c.Context(), c.Params("provider"), state, code)
+	switch {
+	case errors.Is(err, errors.ErrUnknownProvider):
+		return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Unknown provider", fiber.StatusNotFound))
+	case errors.Is(err, errors.ErrInvalidToken):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid or expired state", fiber.StatusBadRequest))
+	case errors.Is(err, errors.ErrUserAlreadyExists):
+		return c.Status(fiber.StatusConflict).JSON(apiUtils.CreateErrorResponse("An account with this email address already exists", fiber.StatusConflict))
+	case errors.Is(err, errors.ErrExternalLoginFailed):
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("External login failed", fiber.StatusUnauthorized))
+	case errors.Is(err, errors.ErrDatabaseOperation):
+		log.Error().Err(err).Msg("Failed to complete external login")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not complete login", fiber.StatusInternalServerError))
+	}
+
+	return h.auth.authenticated(c, user, err)
+}
+
+// ListIdentities lists the external accounts linked to the current user
+// @Summary List linked accounts
+// @Tags Profile
+// @Produce json
+// @Success 200 {object} apiUtils.Response[[]models.UserIdentity]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /me/identities [get]
+// @Security ApiKeyAuth
+func (h *OIDCHandler) ListIdentities(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	identities, err := h.service.ListIdentities(userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list identities")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not list linked accounts", fiber.StatusInternalServerError))
+	}
+
+	response := apiUtils.CreateResponse[[]models.UserIdentity](identities)
+	return c.JSON(response)
+}
+
+// UnlinkIdentity unlinks an external account from the current user
+// @Summary Unlink an account
+// @Tags Profile
+// @Param id path int true "Identity ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /me/identities/{id} [delete]
+// @Security ApiKeyAuth
+func (h *OIDCHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	if err := h.service.UnlinkIdentity(userID, uint(id)); err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Linked account not found", fiber.StatusNotFound))
+		}
+		log.Error().Err(err).Msg("Failed to unlink identity")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not unlink account", fiber.StatusInternalServerError))
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *OIDCHandler) tokenUser(c *fiber.Ctx) (uint, bool) {
+	return tokenUserID(c, "Linked accounts cannot be managed with an API key")
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.OIDCService = (*MockOIDCService)(nil)
+
+type MockOIDCService struct {
+	mock.Mock
+}
+
+func (m *MockOIDCService) Providers() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *MockOIDCService) BeginLogin(ctx context.Context, provider string) (string, string, error) {
+	args := m.Called(provider)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockOIDCService) CompleteLogin(ctx context.Context, provider, state, code string) (*models.User, error) {
+	args := m.Called(provider, state, code)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockOIDCService) ListIdentities(userID uint) ([]models.UserIdentity, error) {
+	args := m.Called(userID)
+	identities, _ := args.Get(0).([]models.UserIdentity)
+	return identities, args.Error(1)
+}
+
+func (m *MockOIDCService) UnlinkIdentity(userID, id uint) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+func newTestOIDCApp(service *MockOIDCService, authService *MockAuthService) *fiber.App {
+	handler := NewOIDCHandler(service, NewAuthHandler(authService, new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig))
+
+	app := fiber.New()
+	app.Get("/auth/oidc/:provider/login", handler.Login)
+	app.Get("/auth/oidc/:provider/callback", handler.Callback)
+	return app
+}
+
+func TestOIDCLogin(t *testing.T) {
+	service := new(MockOIDCService)
+	app := newTestOIDCApp(service, new(MockAuthService))
+
+	service.On("BeginLogin", "google").Return("https://accounts.example.com/authorize?state=abc", "abc", nil)
+	service.On("BeginLogin", "unknown").Return("", "", errors.ErrUnknownProvider)
+	service.On("BeginLogin", "keycloak").Return("", "", errors.ErrExternalLoginFailed)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/auth/oidc/google/login", nil))
+	assert.Equal(t, fiber.StatusFound, resp.StatusCode)
+	assert.Equal(t, "https://accounts.example.com/authorize?state=abc", resp.Header.Get("Location"))
+
+	cookie := responseCookies(resp)[oidcStateCookie]
+	if assert.NotNil(t, cookie) {
+		assert.Equal(t, "abc", cookie.Value)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/auth/oidc/unknown/login", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/auth/oidc/keycloak/login", nil))
+	assert.Equal(t, fiber.StatusBadGateway, resp.StatusCode)
+	assert.Nil(t, responseCookies(resp)[oidcStateCookie])
+
+	service.AssertExpectations(t)
+}
+
+func TestOIDCCallback(t *testing.T) {
+	user := &models.User{ID: 1, Name: "alice"}
+
+	testCases := []struct {
+		name           string
+		query          string
+		mockUser       *models.User
+		mockError      error
+		stateCookie    string
+		expectedStatus int
+		expectMFA      bool
+	}{
+		{name: "Success", query: "code=c&state=s", mockUser: user, expectedStatus: fiber.StatusOK},
+		{name: "MFA Required", query: "code=c&state=s", mockUser: &models.User{ID: 2, Name: "bob"}, mockError: errors.ErrMFARequired, expectedStatus: fiber.StatusOK, expectMFA: true},
+		{name: "Invalid State", query: "code=c&state=s", mockError: errors.ErrInvalidToken, expectedStatus: fiber.StatusBadRequest},
+		{name: "Exchange Failed", query: "code=c&state=s", mockError: errors.ErrExternalLoginFailed, expectedStatus: fiber.StatusUnauthorized},
+		{name: "Email Taken", query: "code=c&state=s", mockError: errors.ErrUserAlreadyExists, expectedStatus: fiber.StatusConflict},
+		{name: "Account Disabled", query: "code=c&state=s", mockError: errors.ErrAccountDisabled, expectedStatus: fiber.StatusForbidden},
+		{name: "Denied By Provider", query: "error=access_denied&state=s", expectedStatus: fiber.StatusUnauthorized},
+		{name: "Missing Code", query: "state=s", expectedStatus: fiber.StatusBadRequest},
+		{name: "Missing State Cookie", query: "code=c&state=s", stateCookie: "-", expectedStatus: fiber.StatusBadRequest},
+		{name: "State Of Other Login", query: "code=c&state=s", stateCookie: "other", expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := new(MockOIDCService)
+			authService := new(MockAuthService)
+			app := newTestOIDCApp(service, authService)
+
+			service.On("CompleteLogin", "google", "s", "c").Return(tc.mockUser, tc.mockError).Maybe()
+			if tc.mockError == nil && tc.mockUser != nil {
+				authService.On("CreateSession", tc.mockUser.ID, mock.Anything, mock.Anything).Return(&models.RefreshToken{FamilyID: "family", Token: "refresh-token"}, nil)
+			}
+
+			req := httptest.NewRequest("GET", "/auth/oidc/google/callback?"+tc.query, nil)
+			switch tc.stateCookie {
+			case "":
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: "s"})
+			case "-":
+			default:
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tc.stateCookie})
+			}
+
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			if tc.stateCookie != "" {
+				service.AssertNotCalled(t, "CompleteLogin", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			if tc.expectedStatus == fiber.StatusOK {
+				var body struct {
+					Data models.LoginResponse `json:"data"`
+				}
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, tc.expectMFA, body.Data.MFARequired)
+				if !tc.expectMFA {
+					assert.NotEmpty(t, body.Data.Token)
+					assert.Equal(t, "refresh-token", body.Data.RefreshToken)
+				}
+			}
+
+			authService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	service := new(MockOIDCService)
+	handler := NewOIDCHandler(service, nil)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Delete("/me/identities/:id", handler.UnlinkIdentity)
+
+	service.On("UnlinkIdentity", uint(1), uint(5)).Return(nil)
+	service.On("UnlinkIdentity", uint(1), uint(6)).Return(errors.ErrResourceNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/me/identities/5", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/me/identities/6", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/me/identities/abc", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	service.AssertExpectations(t)
+}
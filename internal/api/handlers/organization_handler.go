@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type OrganizationHandler struct {
+	service  services.OrganizationService
+	auth     *AuthHandler
+	validate *validator.Validate
+}
+
+// NewOrganizationHandler creates a handler for organizations. Switching the
+// active organization issues a new access token through auth.
+func NewOrganizationHandler(service services.OrganizationService, auth *AuthHandler) *OrganizationHandler {
+	return &OrganizationHandler{service: service, auth: auth, validate: validator.New()}
+}
+
+// CreateOrganization creates an organization owned by the current user
+// @Summary Create an organization
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param organization body models.CreateOrganizationRequest true "Organization name"
+// @Success 201 {object} apiUtils.Response[models.Organization]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) CreateOrganization(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.CreateOrganizationRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	organization, err := h.service.CreateOrganization(userID, request.Name)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Organization](organization)
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// ListOrganizations lists the organizations of the current user
+// @Summary List organizations
+// @Description List the organizations the current user is a member of, with the user's role in each
+// @Tags Organizations
+// @Produce json
+// @Success 200 {object} apiUtils.Response[[]models.Organization]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) ListOrganizations(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	organizations, err := h.service.ListOrganizations(userID)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[[]models.Organization](organizations)
+	return c.JSON(response)
+}
+
+// GetOrganization returns an organization of the current user
+// @Summary Get an organization
+// @Tags Organizations
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} apiUtils.Response[models.Organization]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id} [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) GetOrganization(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+
+	organization, err := h.service.GetOrganization(userID, id)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Organization](organization)
+	return c.JSON(response)
+}
+
+// DeleteOrganization deletes an organization
+// @Summary Delete an organization
+// @Description Delete the organization with its todos. Only owners can delete an organization.
+// @Tags Organizations
+// @Param id path int true "Organization ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id} [delete]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) DeleteOrganization(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+
+	if err := h.service.DeleteOrganization(userID, id); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListMembers lists the members of an organization
+// @Summary List members
+// @Tags Organizations
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} apiUtils.Response[[]models.OrganizationMember]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id}/members [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) ListMembers(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+
+	members, err := h.service.ListMembers(userID, id)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[[]models.OrganizationMember](members)
+	return c.JSON(response)
+}
+
+// SetMemberRole changes the role of a member
+// @Summary Change a member's role
+// @Description Only owners can change roles. The last owner cannot step down.
+// @Tags Organizations
+// @Accept json
+// @Param id path int true "Organization ID"
+// @Param userId path int true "User ID of the member"
+// @Param role body models.SetMemberRoleRequest true "New role"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id}/members/{userId} [put]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) SetMemberRole(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+	memberID, ok := h.id(c, "userId")
+	if !ok {
+		return nil
+	}
+
+	var request models.SetMemberRoleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	if err := h.service.SetMemberRole(userID, id, memberID, request.Role); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RemoveMember removes a member from an organization
+// @Summary Remove a member
+// @Description Remove a member, or leave the organization by removing yourself. Admins can remove members, owners can also remove admins and owners. The last owner cannot leave.
+// @Tags Organizations
+// @Param id path int true "Organization ID"
+// @Param userId path int true "User ID of the member"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id}/members/{userId} [delete]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) RemoveMember(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+	memberID, ok := h.id(c, "userId")
+	if !ok {
+		return nil
+	}
+
+	if err := h.service.RemoveMember(userID, id, memberID); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Invite invites someone to an organization by email
+// @Summary Invite a member
+// @Description Email an invitation link. Admins can invite members and admins, owners can also invite owners.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param invitation body models.InviteMemberRequest true "Email address and role"
+// @Success 201 {object} apiUtils.Response[models.OrganizationInvitation]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id}/invitations [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) Invite(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+
+	var request models.InviteMemberRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	invitation, err := h.service.Invite(userID, id, request.Email, request.Role)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.OrganizationInvitation](invitation)
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// ListInvitations lists the open invitations of an organization
+// @Summary List invitations
+// @Tags Organizations
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} apiUtils.Response[[]models.OrganizationInvitation]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id}/invitations [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) ListInvitations(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+
+	invitations, err := h.service.ListInvitations(userID, id)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[[]models.OrganizationInvitation](invitations)
+	return c.JSON(response)
+}
+
+// RevokeInvitation withdraws an invitation
+// @Summary Revoke an invitation
+// @Tags Organizations
+// @Param id path int true "Organization ID"
+// @Param invitationId path int true "Invitation ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /organizations/{id}/invitations/{invitationId} [delete]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) RevokeInvitation(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+	id, ok := h.id(c, "id")
+	if !ok {
+		return nil
+	}
+	invitationID, ok := h.id(c, "invitationId")
+	if !ok {
+		return nil
+	}
+
+	if err := h.service.RevokeInvitation(userID, id, invitationID); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AcceptInvitation accepts an invitation with the token from the invitation email
+// @Summary Accept an invitation
+// @Description Join the organization. The invitation must have been sent to the current user's verified email address.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param invitation body models.InvitationTokenRequest true "Invitation token"
+// @Success 200 {object} apiUtils.Response[models.Organization]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /invitations/accept [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) AcceptInvitation(c *fiber.Ctx) error {
+	userID, token, ok := h.invitationRequest(c)
+	if !ok {
+		return nil
+	}
+
+	organization, err := h.service.AcceptInvitation(userID, token)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Organization](organization)
+	return c.JSON(response)
+}
+
+// DeclineInvitation declines an invitation with the token from the invitation email
+// @Summary Decline an invitation
+// @Tags Organizations
+// @Accept json
+// @Param invitation body models.InvitationTokenRequest true "Invitation token"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /invitations/decline [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) DeclineInvitation(c *fiber.Ctx) error {
+	userID, token, ok := h.invitationRequest(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.service.DeclineInvitation(userID, token); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SwitchOrganization changes the organization the current session acts in
+// @Summary Switch organization
+// @Description Make an organization of the current user the active one, or the personal space with organization_id 0, and issue a new access token acting in it.
+// @Description Tokens from later refreshes of the session act in it too.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Param organization body models.SwitchOrganizationRequest true "Organization to switch to"
+// @Success 200 {object} apiUtils.Response[models.SwitchOrganizationResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/organization [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) SwitchOrganization(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	claims, _ := auth.ClaimsFromContext(c)
+	if claims.SessionID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Only tokens of a login session can switch organization", fiber.StatusBadRequest))
+	}
+
+	var request models.SwitchOrganizationRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	user, err := h.service.SwitchOrganization(userID, claims.SessionID, request.OrganizationID)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	accessToken, err := h.auth.newAccessToken(user, claims.SessionID, request.OrganizationID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not generate token", fiber.StatusInternalServerError))
+	}
+
+	if claims.FromCookie {
+		h.auth.cookies.setAccessToken(c, accessToken, time.Now().Add(h.auth.accessTokenTTL))
+		response := apiUtils.CreateResponse[models.SwitchOrganizationResponse](models.SwitchOrganizationResponse{
+			ExpiresIn:      int64(h.auth.accessTokenTTL.Seconds()),
+			OrganizationID: request.OrganizationID,
+		})
+		return c.JSON(response)
+	}
+
+	response := apiUtils.CreateResponse[models.SwitchOrganizationResponse](models.SwitchOrganizationResponse{
+		Token:          accessToken,
+		TokenType:      "Bearer",
+		ExpiresIn:      int64(h.auth.accessTokenTTL.Seconds()),
+		OrganizationID: request.OrganizationID,
+	})
+	return c.JSON(response)
+}
+
+func (h *OrganizationHandler) invitationRequest(c *fiber.Ctx) (uint, string, bool) {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return 0, "", false
+	}
+
+	var request models.InvitationTokenRequest
+	if err := c.BodyParser(&request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+		return 0, "", false
+	}
+	if err := h.validate.Struct(request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+		return 0, "", false
+	}
+	return userID, request.Token, true
+}
+
+func (h *OrganizationHandler) tokenUser(c *fiber.Ctx) (uint, bool) {
+	return tokenUserID(c, "Organizations cannot be managed with an API key")
+}
+
+func (h *OrganizationHandler) id(c *fiber.Ctx, param string) (uint, bool) {
+	id, err := strconv.Atoi(c.Params(param))
+	if err != nil || id < 1 {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *OrganizationHandler) error(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errors.ErrResourceNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Not found", fiber.StatusNotFound))
+	case errors.Is(err, errors.ErrInsufficientRole):
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("Your role in the organization does not allow this", fiber.StatusForbidden))
+	case errors.Is(err, errors.ErrLastOwner):
+		return c.Status(fiber.StatusConflict).JSON(apiUtils.CreateErrorResponse("The organization must keep an owner", fiber.StatusConflict))
+	case errors.Is(err, errors.ErrUserAlreadyExists):
+		return c.Status(fiber.StatusConflict).JSON(apiUtils.CreateErrorResponse("Already a member of the organization", fiber.StatusConflict))
+	case errors.Is(err, errors.ErrInvalidToken):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid or expired token", fiber.StatusBadRequest))
+	case errors.Is(err, errors.ErrInvitationMismatch):
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("The invitation was sent to another email address", fiber.StatusForbidden))
+	case errors.Is(err, errors.ErrEmailNotVerified):
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("Verify your email address to accept the invitation", fiber.StatusForbidden))
+	case errors.Is(err, errors.ErrAccountDisabled), errors.Is(err, errors.ErrUserNotFound):
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	case errors.Is(err, errors.ErrInvalidInput):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Unknown role", fiber.StatusBadRequest))
+	}
+	log.Error().Err(err).Msg("Organization request failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not complete organization request", fiber.StatusInternalServerError))
+}
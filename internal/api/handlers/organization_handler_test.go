@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ services.OrganizationService = (*MockOrganizationService)(nil)
+
+type MockOrganizationService struct {
+	mock.Mock
+}
+
+func (m *MockOrganizationService) CreateOrganization(userID uint, name string) (*models.Organization, error) {
+	args := m.Called(userID, name)
+	organization, _ := args.Get(0).(*models.Organization)
+	return organization, args.Error(1)
+}
+
+func (m *MockOrganizationService) ListOrganizations(userID uint) ([]models.Organization, error) {
+	args := m.Called(userID)
+	organizations, _ := args.Get(0).([]models.Organization)
+	return organizations, args.Error(1)
+}
+
+func (m *MockOrganizationService) GetOrganization(userID, id uint) (*models.Organization, error) {
+	args := m.Called(userID, id)
+	organization, _ := args.Get(0).(*models.Organization)
+	return organization, args.Error(1)
+}
+
+func (m *MockOrganizationService) DeleteOrganization(userID, id uint) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) ListMembers(userID, id uint) ([]models.OrganizationMember, error) {
+	args := m.Called(userID, id)
+	members, _ := args.Get(0).([]models.OrganizationMember)
+	return members, args.Error(1)
+}
+
+func (m *MockOrganizationService) SetMemberRole(userID, id, memberID uint, role string) error {
+	args := m.Called(userID, id, memberID, role)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) RemoveMember(userID, id, memberID uint) error {
+	args := m.Called(userID, id, memberID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) Invite(userID, id uint, email, role string) (*models.OrganizationInvitation, error) {
+	args := m.Called(userID, id, email, role)
+	invitation, _ := args.Get(0).(*models.OrganizationInvitation)
+	return invitation, args.Error(1)
+}
+
+func (m *MockOrganizationService) ListInvitations(userID, id uint) ([]models.OrganizationInvitation, error) {
+	args := m.Called(userID, id)
+	invitations, _ := args.Get(0).([]models.OrganizationInvitation)
+	return invitations, args.Error(1)
+}
+
+func (m *MockOrganizationService) RevokeInvitation(userID, id, invitationID uint) error {
+	args := m.Called(userID, id, invitationID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) AcceptInvitation(userID uint, token string) (*models.Organization, error) {
+	args := m.Called(userID, token)
+	organization, _ := args.Get(0).(*models.Organization)
+	return organization, args.Error(1)
+}
+
+func (m *MockOrganizationService) DeclineInvitation(userID uint, token string) error {
+	args := m.Called(userID, token)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) SwitchOrganization(userID uint, sessionID string, organizationID uint) (*models.User, error) {
+	args := m.Called(userID, sessionID, organizationID)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func (m *MockOrganizationService) IsMember(organizationID, userID uint) (bool, error) {
+	args := m.Called(organizationID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestSwitchOrganization(t *testing.T) {
+	service := new(MockOrganizationService)
+	handler := NewOrganizationHandler(service, NewAuthHandler(new(MockAuthService), new(MockMFAService), newOpenThrottle(), new(MockRevocationService), testConfig))
+
+	app := fiber.New()
+	app.Post("/auth/organization", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, SessionID: "session"})
+		return handler.SwitchOrganization(c)
+	})
+
+	service.On("SwitchOrganization", uint(1), "session", uint(3)).Return(&models.User{ID: 1, Name: "alice"}, nil)
+	service.On("SwitchOrganization", uint(1), "session", uint(4)).Return(nil, errors.ErrResourceNotFound)
+
+	body, _ := json.Marshal(models.SwitchOrganizationRequest{OrganizationID: 3})
+	req := httptest.NewRequest("POST", "/auth/organization", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var decoded struct {
+		Data models.SwitchOrganizationResponse `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	assert.Equal(t, uint(3), decoded.Data.OrganizationID)
+
+	claims, err := auth.ParseToken(decoded.Data.Token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(3), claims.OrganizationID)
+	assert.Equal(t, "session", claims.SessionID)
+
+	body, _ = json.Marshal(models.SwitchOrganizationRequest{OrganizationID: 4})
+	req = httptest.NewRequest("POST", "/auth/organization", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	service.AssertExpectations(t)
+}
+
+func TestSwitchOrganizationRequiresSession(t *testing.T) {
+	service := new(MockOrganizationService)
+	handler := NewOrganizationHandler(service, nil)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/auth/organization", handler.SwitchOrganization)
+
+	body, _ := json.Marshal(models.SwitchOrganizationRequest{OrganizationID: 3})
+	req := httptest.NewRequest("POST", "/auth/organization", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	service.AssertNotCalled(t, "SwitchOrganization", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRemoveMember(t *testing.T) {
+	service := new(MockOrganizationService)
+	handler := NewOrganizationHandler(service, nil)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Delete("/organizations/:id/members/:userId", handler.RemoveMember)
+
+	service.On("RemoveMember", uint(1), uint(2), uint(3)).Return(nil)
+	service.On("RemoveMember", uint(1), uint(2), uint(4)).Return(errors.ErrInsufficientRole)
+	service.On("RemoveMember", uint(1), uint(2), uint(1)).Return(errors.ErrLastOwner)
+
+	for path, expectedStatus := range map[string]int{
+		"/organizations/2/members/3":   fiber.StatusNoContent,
+		"/organizations/2/members/4":   fiber.StatusForbidden,
+		"/organizations/2/members/1":   fiber.StatusConflict,
+		"/organizations/2/members/abc": fiber.StatusBadRequest,
+	} {
+		resp, _ := app.Test(httptest.NewRequest("DELETE", path, nil))
+		assert.Equal(t, expectedStatus, resp.StatusCode, path)
+	}
+
+	service.AssertExpectations(t)
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	service := new(MockOrganizationService)
+	handler := NewOrganizationHandler(service, nil)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/invitations/accept", handler.AcceptInvitation)
+
+	service.On("AcceptInvitation", uint(1), "valid").Return(&models.Organization{ID: 2, Name: "Acme", Role: models.OrgRoleMember}, nil)
+	service.On("AcceptInvitation", uint(1), "for-someone-else").Return(nil, errors.ErrInvitationMismatch)
+	service.On("AcceptInvitation", uint(1), "expired").Return(nil, errors.ErrInvalidToken)
+
+	for token, expectedStatus := range map[string]int{
+		"valid":            fiber.StatusOK,
+		"for-someone-else": fiber.StatusForbidden,
+		"expired":          fiber.StatusBadRequest,
+	} {
+		body, _ := json.Marshal(models.InvitationTokenRequest{Token: token})
+		req := httptest.NewRequest("POST", "/invitations/accept", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		assert.Equal(t, expectedStatus, resp.StatusCode, token)
+	}
+
+	service.AssertExpectations(t)
+}
+
+func TestOrganizationsRejectAPIKeys(t *testing.T) {
+	service := new(MockOrganizationService)
+	handler := NewOrganizationHandler(service, nil)
+
+	app := fiber.New()
+	app.Get("/organizations", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, APIKeyID: 3})
+		return handler.ListOrganizations(c)
+	})
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/organizations", nil))
+
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	service.AssertNotCalled(t, "ListOrganizations", mock.Anything)
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type ProfileHandler struct {
+	authService services.AuthService
+	validate    *validator.Validate
+}
+
+func NewProfileHandler(authService services.AuthService) *ProfileHandler {
+	return &ProfileHandler{authService: authService, validate: validator.New()}
+}
+
+// GetMe returns the current user
+// @Summary Get the current user
+// @Tags Profile
+// @Produce json
+// @Success 200 {object} apiUtils.Response[models.User]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /me [get]
+// @Security ApiKeyAuth
+func (h *ProfileHandler) GetMe(c *fiber.Ctx) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	user, err := h.authService.GetProfile(userID)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.User](*user)
+	return c.JSON(response)
+}
+
+// UpdateMe updates the current user
+// @Summary Update the current user
+// @Description Change the fields that are set in the request. Use /me/email to change the email address.
+// @Tags Profile
+// @Accept json
+// @Produce json
+// @Param profile body models.UpdateProfileRequest true "Profile fields"
+// @Success 200 {object} apiUtils.Response[models.User]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /me [patch]
+// @Security ApiKeyAuth
+func (h *ProfileHandler) UpdateMe(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.UpdateProfileRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	user, err := h.authService.UpdateProfile(userID, request)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.User](*user)
+	return c.JSON(response)
+}
+
+// ChangePassword changes the password of the current user
+// @Summary Change password
+// @Description Change the password after checking the current one. Every other session is logged out.
+// @Description A password that does not meet the password policy is rejected with the reasons listed in fields.new_pass.
+// @Tags Profile
+// @Accept json
+// @Produce json
+// @Param password body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} apiUtils.Response[models.MessageResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /me/password [post]
+// @Security ApiKeyAuth
+func (h *ProfileHandler) ChangePassword(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.ChangePasswordRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	var sessionID string
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		sessionID = claims.SessionID
+	}
+
+	if err := h.authService.ChangePassword(userID, request.CurrentPass, request.NewPass, sessionID); err != nil {
+		var policyErr *errors.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return rejectPassword(c, "new_pass", policyErr)
+		}
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.MessageResponse](models.MessageResponse{
+		Message: "Password changed",
+	})
+	return c.JSON(response)
+}
+
+// RequestEmailChange starts changing the email address of the current user
+// @Summary Change email address
+// @Description Send a confirmation link to the new address. The address is changed once the link is followed.
+// @Tags Profile
+// @Accept json
+// @Produce json
+// @Param email body models.ChangeEmailRequest true "New email address and current password"
+// @Success 202 {object} apiUtils.Response[models.MessageResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /me/email [post]
+// @Security ApiKeyAuth
+func (h *ProfileHandler) RequestEmailChange(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	var request models.ChangeEmailRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	if err := h.authService.RequestEmailChange(userID, request.Email, request.Pass); err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.MessageResponse](models.MessageResponse{
+		Message: "A confirmation link has been sent to the new address",
+	})
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// ConfirmEmailChange completes an email address change
+// @Summary Confirm email address change
+// @Description Switch to the new email address with the token from the confirmation email
+// @Tags Profile
+// @Accept json
+// @Produce json
+// @Param confirm body models.VerifyEmailRequest true "Confirmation token"
+// @Success 200 {object} apiUtils.Response[models.MessageResponse]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /me/email/confirm [post]
+func (h *ProfileHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var request models.VerifyEmailRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+	}
+
+	if err := h.authService.ConfirmEmailChange(request.Token); err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.MessageResponse](models.MessageResponse{
+		Message: "Email address changed",
+	})
+	return c.JSON(response)
+}
+
+func (h *ProfileHandler) tokenUser(c *fiber.Ctx) (uint, bool) {
+	return tokenUserID(c, "The profile cannot be changed with an API key")
+}
+
+func (h *ProfileHandler) error(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errors.ErrInvalidCredentials):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Current password is incorrect", fiber.StatusBadRequest))
+	case errors.Is(err, errors.ErrInvalidToken):
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid or expired token", fiber.StatusBadRequest))
+	case errors.Is(err, errors.ErrUserAlreadyExists):
+		return c.Status(fiber.StatusConflict).JSON(apiUtils.CreateErrorResponse("Name or email address already in use", fiber.StatusConflict))
+	case errors.Is(err, errors.ErrUserNotFound):
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+	log.Error().Err(err).Msg("Profile request failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not update profile", fiber.StatusInternalServerError))
+}
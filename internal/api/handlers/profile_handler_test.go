@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetMe(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewProfileHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Get("/me", handler.GetMe)
+
+	mockService.On("GetProfile", uint(1)).Return(&models.User{ID: 1, Name: "testuser", Email: "test@example.com"}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/me", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data models.User `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "testuser", result.Data.Name)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateMe(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewProfileHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Patch("/me", handler.UpdateMe)
+
+	newName := "renamed"
+	takenName := "taken"
+	shortName := "ab"
+
+	testCases := []struct {
+		name           string
+		request        models.UpdateProfileRequest
+		mockUser       *models.User
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Success",
+			request:        models.UpdateProfileRequest{Name: &newName},
+			mockUser:       &models.User{ID: 1, Name: newName},
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "Name Taken",
+			request:        models.UpdateProfileRequest{Name: &takenName},
+			mockError:      errors.ErrUserAlreadyExists,
+			expectedStatus: fiber.StatusConflict,
+		},
+		{
+			name:           "Invalid Name",
+			request:        models.UpdateProfileRequest{Name: &shortName},
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.expectedStatus != fiber.StatusBadRequest {
+				mockService.On("UpdateProfile", uint(1), tc.request).Return(tc.mockUser, tc.mockError)
+			}
+
+			body, _ := json.Marshal(tc.request)
+			req := httptest.NewRequest("PATCH", "/me", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewProfileHandler(mockService)
+
+	app := fiber.New()
+	app.Post("/me/password", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, SessionID: "current"})
+		return handler.ChangePassword(c)
+	})
+
+	mockService.On("ChangePassword", uint(1), "old-password", "new-password", "current").Return(nil)
+	mockService.On("ChangePassword", uint(1), "wrong-password", "new-password", "current").Return(errors.ErrInvalidCredentials)
+
+	for _, tc := range []struct {
+		currentPass    string
+		expectedStatus int
+	}{
+		{currentPass: "old-password", expectedStatus: fiber.StatusOK},
+		{currentPass: "wrong-password", expectedStatus: fiber.StatusBadRequest},
+	} {
+		body, _ := json.Marshal(models.ChangePasswordRequest{CurrentPass: tc.currentPass, NewPass: "new-password"})
+		req := httptest.NewRequest("POST", "/me/password", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestChangePasswordWithAPIKey(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewProfileHandler(mockService)
+
+	app := fiber.New()
+	app.Post("/me/password", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, APIKeyID: 3})
+		return handler.ChangePassword(c)
+	})
+
+	body, _ := json.Marshal(models.ChangePasswordRequest{CurrentPass: "old-password", NewPass: "new-password"})
+	req := httptest.NewRequest("POST", "/me/password", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	mockService.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEmailChange(t *testing.T) {
+	mockService := new(MockAuthService)
+	handler := NewProfileHandler(mockService)
+
+	app := fiber.New()
+	app.Post("/me/email", withUser(1), handler.RequestEmailChange)
+	app.Post("/me/email/confirm", handler.ConfirmEmailChange)
+
+	mockService.On("RequestEmailChange", uint(1), "new@example.com", "password123").Return(nil)
+	mockService.On("RequestEmailChange", uint(1), "taken@example.com", "password123").Return(errors.ErrUserAlreadyExists)
+	mockService.On("ConfirmEmailChange", "valid-token").Return(nil)
+
+	body, _ := json.Marshal(models.ChangeEmailRequest{Email: "new@example.com", Pass: "password123"})
+	req := httptest.NewRequest("POST", "/me/email", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+
+	body, _ = json.Marshal(models.ChangeEmailRequest{Email: "taken@example.com", Pass: "password123"})
+	req = httptest.NewRequest("POST", "/me/email", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+	body, _ = json.Marshal(models.VerifyEmailRequest{Token: "valid-token"})
+	req = httptest.NewRequest("POST", "/me/email/confirm", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
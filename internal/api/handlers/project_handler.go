@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type ProjectHandler struct {
+	service  services.ProjectService
+	validate *validator.Validate
+}
+
+func NewProjectHandler(service services.ProjectService) *ProjectHandler {
+	return &ProjectHandler{service: service, validate: validator.New()}
+}
+
+// CreateProject creates a project
+// @Summary Create a project
+// @Description Create a project in the active organization, or among the user's personal projects
+// @Tags Projects
+// @Accept json
+// @Produce json
+// @Param project body models.ProjectRequest true "Project"
+// @Success 201 {object} apiUtils.Response[models.Project]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /projects [post]
+// @Security ApiKeyAuth
+func (h *ProjectHandler) CreateProject(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	request, ok := h.projectRequest(c)
+	if !ok {
+		return nil
+	}
+
+	project, err := h.service.CreateProject(ws, request)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Project](*project)
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// ListProjects lists projects
+// @Summary List projects
+// @Description List the projects of the active organization, or the user's personal projects, by name with the counts of their todos
+// @Tags Projects
+// @Produce json
+// @Param include_archived query bool false "Include archived projects" default(false)
+// @Success 200 {object} apiUtils.Response[[]models.Project]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /projects [get]
+// @Security ApiKeyAuth
+func (h *ProjectHandler) ListProjects(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	projects, err := h.service.ListProjects(ws, c.QueryBool("include_archived"))
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[[]models.Project](projects)
+	return c.JSON(response)
+}
+
+// GetProject retrieves a project
+// @Summary Get a project
+// @Description Get a project with the counts of its todos
+// @Tags Projects
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} apiUtils.Response[models.Project]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /projects/{id} [get]
+// @Security ApiKeyAuth
+func (h *ProjectHandler) GetProject(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	project, err := h.service.GetProject(ws, uint(id))
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Project](*project)
+	return c.JSON(response)
+}
+
+// UpdateProject updates a project
+// @Summary Update a project
+// @Description Replace the name, description and color of a project and archive or restore it. The todos of archived projects are left out of the todo list by default.
+// @Tags Projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param project body models.ProjectRequest true "Project"
+// @Success 200 {object} apiUtils.Response[models.Project]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /projects/{id} [put]
+// @Security ApiKeyAuth
+func (h *ProjectHandler) UpdateProject(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	request, ok := h.projectRequest(c)
+	if !ok {
+		return nil
+	}
+
+	project, err := h.service.UpdateProject(ws, uint(id), request)
+	if err != nil {
+		return h.error(c, err)
+	}
+
+	response := apiUtils.CreateResponse[models.Project](*project)
+	return c.JSON(response)
+}
+
+// DeleteProject deletes a project
+// @Summary Delete a project
+// @Description Delete a project. Its todos are kept without a project.
+// @Tags Projects
+// @Param id path int true "Project ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /projects/{id} [delete]
+// @Security ApiKeyAuth
+func (h *ProjectHandler) DeleteProject(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest))
+	}
+
+	if err := h.service.DeleteProject(ws, uint(id)); err != nil {
+		return h.error(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// projectRequest parses and validates the request body, writing an error
+// response if it is invalid
+func (h *ProjectHandler) projectRequest(c *fiber.Ctx) (models.ProjectRequest, bool) {
+	var request models.ProjectRequest
+	if err := c.BodyParser(&request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse("Invalid request body", fiber.StatusBadRequest))
+		return request, false
+	}
+
+	if err := h.validate.Struct(request); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(apiUtils.CreateErrorResponse(err.Error(), fiber.StatusBadRequest))
+		return request, false
+	}
+	return request, true
+}
+
+func (h *ProjectHandler) error(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errors.ErrResourceNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Project not found", fiber.StatusNotFound))
+	case errors.Is(err, errors.ErrProjectRestricted):
+		return c.Status(fiber.StatusForbidden).JSON(apiUtils.CreateErrorResponse("API key is restricted to projects", fiber.StatusForbidden))
+	}
+	log.Error().Err(err).Msg("Project request failed")
+	return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not process project", fiber.StatusInternalServerError))
+}
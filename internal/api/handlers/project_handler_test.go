@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var _ services.ProjectService = (*MockProjectService)(nil)
+
+type MockProjectService struct {
+	mock.Mock
+}
+
+func (m *MockProjectService) CreateProject(ws models.Workspace, request models.ProjectRequest) (*models.Project, error) {
+	args := m.Called(ws, request)
+	project, _ := args.Get(0).(*models.Project)
+	return project, args.Error(1)
+}
+
+func (m *MockProjectService) GetProject(ws models.Workspace, id uint) (*models.Project, error) {
+	args := m.Called(ws, id)
+	project, _ := args.Get(0).(*models.Project)
+	return project, args.Error(1)
+}
+
+func (m *MockProjectService) ListProjects(ws models.Workspace, includeArchived bool) ([]models.Project, error) {
+	args := m.Called(ws, includeArchived)
+	projects, _ := args.Get(0).([]models.Project)
+	return projects, args.Error(1)
+}
+
+func (m *MockProjectService) UpdateProject(ws models.Workspace, id uint, request models.ProjectRequest) (*models.Project, error) {
+	args := m.Called(ws, id, request)
+	project, _ := args.Get(0).(*models.Project)
+	return project, args.Error(1)
+}
+
+func (m *MockProjectService) DeleteProject(ws models.Workspace, id uint) error {
+	args := m.Called(ws, id)
+	return args.Error(0)
+}
+
+func TestCreateProject(t *testing.T) {
+	service := new(MockProjectService)
+	handler := NewProjectHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/projects", handler.CreateProject)
+
+	request := models.ProjectRequest{Name: "Website", Color: "#00aa88"}
+	service.On("CreateProject", models.Workspace{UserID: 1}, request).Return(&models.Project{ID: 2, UserID: 1, Name: "Website", Color: "#00aa88"}, nil)
+
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Success", body: `{"name":"Website","color":"#00aa88"}`, expectedStatus: fiber.StatusCreated},
+		{name: "Missing Name", body: `{"color":"#00aa88"}`, expectedStatus: fiber.StatusBadRequest},
+		{name: "Invalid Color", body: `{"name":"Website","color":"green"}`, expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/projects", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestListProjects(t *testing.T) {
+	service := new(MockProjectService)
+	handler := NewProjectHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Get("/projects", handler.ListProjects)
+
+	active := []models.Project{{ID: 1, Name: "Home", Counts: models.ProjectCounts{Total: 3, Open: 2, Done: 1}}}
+	all := append(active, models.Project{ID: 2, Name: "Old", Archived: true})
+	service.On("ListProjects", models.Workspace{UserID: 1}, false).Return(active, nil)
+	service.On("ListProjects", models.Workspace{UserID: 1}, true).Return(all, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/projects", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []models.Project `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, active, body.Data)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/projects?include_archived=true", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body.Data, 2)
+
+	service.AssertExpectations(t)
+}
+
+func TestGetProject(t *testing.T) {
+	service := new(MockProjectService)
+	handler := NewProjectHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Get("/projects/:id", handler.GetProject)
+
+	service.On("GetProject", models.Workspace{UserID: 1}, uint(1)).Return(&models.Project{ID: 1, Name: "Home"}, nil)
+	service.On("GetProject", models.Workspace{UserID: 1}, uint(2)).Return(nil, errors.ErrResourceNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/projects/1", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/projects/2", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/projects/abc", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestArchiveProject(t *testing.T) {
+	service := new(MockProjectService)
+	handler := NewProjectHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Put("/projects/:id", handler.UpdateProject)
+
+	request := models.ProjectRequest{Name: "Home", Archived: true}
+	service.On("UpdateProject", models.Workspace{UserID: 1}, uint(1), request).Return(&models.Project{ID: 1, Name: "Home", Archived: true}, nil)
+
+	req := httptest.NewRequest("PUT", "/projects/1", strings.NewReader(`{"name":"Home","archived":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data models.Project `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.True(t, body.Data.Archived)
+	service.AssertExpectations(t)
+}
+
+func TestDeleteProject(t *testing.T) {
+	service := new(MockProjectService)
+	handler := NewProjectHandler(service)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Delete("/projects/:id", handler.DeleteProject)
+
+	service.On("DeleteProject", models.Workspace{UserID: 1}, uint(1)).Return(nil)
+	service.On("DeleteProject", models.Workspace{UserID: 1}, uint(2)).Return(errors.ErrResourceNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/projects/1", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/projects/2", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	service.AssertExpectations(t)
+}
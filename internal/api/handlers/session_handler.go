@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/rs/zerolog/log"
+)
+
+type SessionHandler struct {
+	service services.SessionService
+}
+
+func NewSessionHandler(service services.SessionService) *SessionHandler {
+	return &SessionHandler{service: service}
+}
+
+// ListSessions lists the active sessions of the current user
+// @Summary List sessions
+// @Description List the devices the current user is logged in on. The session of the request is marked as current.
+// @Tags Sessions
+// @Produce json
+// @Success 200 {object} apiUtils.Response[[]models.Session]
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/sessions [get]
+// @Security ApiKeyAuth
+func (h *SessionHandler) ListSessions(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	sessions, err := h.service.ListSessions(userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list sessions")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not list sessions", fiber.StatusInternalServerError))
+	}
+
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == claims.SessionID
+		}
+	}
+
+	response := apiUtils.CreateResponse[[]models.Session](sessions)
+	return c.JSON(response)
+}
+
+// RevokeSession revokes one of the current user's sessions
+// @Summary Revoke a session
+// @Description Log out the given session. Its access and refresh tokens stop working.
+// @Tags Sessions
+// @Param id path string true "Session ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 403 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /auth/sessions/{id} [delete]
+// @Security ApiKeyAuth
+func (h *SessionHandler) RevokeSession(c *fiber.Ctx) error {
+	userID, ok := h.tokenUser(c)
+	if !ok {
+		return nil
+	}
+
+	if err := h.service.RevokeSession(userID, c.Params("id")); err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(apiUtils.CreateErrorResponse("Session not found", fiber.StatusNotFound))
+		}
+		log.Error().Err(err).Msg("Failed to revoke session")
+		return c.Status(fiber.StatusInternalServerError).JSON(apiUtils.CreateErrorResponse("Could not revoke session", fiber.StatusInternalServerError))
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *SessionHandler) tokenUser(c *fiber.Ctx) (uint, bool) {
+	return tokenUserID(c, "Sessions cannot be managed with an API key")
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.SessionService = (*MockSessionService)(nil)
+
+type MockSessionService struct {
+	mock.Mock
+}
+
+func (m *MockSessionService) ListSessions(userID uint) ([]models.Session, error) {
+	args := m.Called(userID)
+	sessions, _ := args.Get(0).([]models.Session)
+	return sessions, args.Error(1)
+}
+
+func (m *MockSessionService) RevokeSession(userID uint, sessionID string) error {
+	args := m.Called(userID, sessionID)
+	return args.Error(0)
+}
+
+func TestListSessions(t *testing.T) {
+	mockService := new(MockSessionService)
+	handler := NewSessionHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/auth/sessions", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, SessionID: "laptop"})
+		return handler.ListSessions(c)
+	})
+
+	mockService.On("ListSessions", uint(1)).Return([]models.Session{
+		{ID: "laptop", UserAgent: "Firefox", IP: "192.0.2.1"},
+		{ID: "phone", UserAgent: "Safari", IP: "192.0.2.2"},
+	}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/auth/sessions", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data []models.Session `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(t, result.Data, 2)
+	assert.True(t, result.Data[0].Current)
+	assert.False(t, result.Data[1].Current)
+	mockService.AssertExpectations(t)
+}
+
+func TestRevokeSession(t *testing.T) {
+	mockService := new(MockSessionService)
+	handler := NewSessionHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Delete("/auth/sessions/:id", handler.RevokeSession)
+
+	mockService.On("RevokeSession", uint(1), "phone").Return(nil)
+	mockService.On("RevokeSession", uint(1), "someone-elses").Return(errors.ErrResourceNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/auth/sessions/phone", nil))
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/auth/sessions/someone-elses", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestSessionsWithAPIKey(t *testing.T) {
+	mockService := new(MockSessionService)
+	handler := NewSessionHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/auth/sessions", func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, APIKeyID: 3})
+		return handler.ListSessions(c)
+	})
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/auth/sessions", nil))
+
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	mockService.AssertNotCalled(t, "ListSessions", mock.Anything)
+}
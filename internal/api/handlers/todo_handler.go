@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -9,6 +10,7 @@ import (
 	"gorm.io/gorm"
 
 	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
 	"github.com/netf/gofiber-boilerplate/internal/models"
 	"github.com/netf/gofiber-boilerplate/internal/services"
 )
@@ -24,6 +26,10 @@ import (
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 // @host localhost:8080
 // @BasePath /api/v1
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description "Bearer <access token>" or "ApiKey <API key>". API keys may also be sent in the X-API-Key header.
 
 type TodoHandler struct {
 	service  services.TodoService
@@ -45,10 +51,49 @@ func NewTodoHandler(service services.TodoService) *TodoHandler {
 // @Param todo body models.Todo true "Todo item"
 // @Success 201 {object} apiUtils.Response[models.Todo]
 // @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
 // @Failure 500 {object} apiUtils.ErrorResponse
 // @Router /todos [post]
 // @Security ApiKeyAuth
 func (h *TodoHandler) CreateTodo(c *fiber.Ctx) error {
+	return h.createTodo(c, 0)
+}
+
+// CreateProjectTodo creates a new todo item in a project
+// @Summary Create a new todo in a project
+// @Tags Projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param todo body models.Todo true "Todo item"
+// @Success 201 {object} apiUtils.Response[models.Todo]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /projects/{id}/todos [post]
+// @Security ApiKeyAuth
+func (h *TodoHandler) CreateProjectTodo(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		log.Warn().Msg("Invalid ID parameter")
+		errorResponse := apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+	return h.createTodo(c, uint(id))
+}
+
+// createTodo creates a todo from the request body, in the project with the
+// given ID if it is not 0
+func (h *TodoHandler) createTodo(c *fiber.Ctx, projectID uint) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
 	var todo models.Todo
 	if err := c.BodyParser(&todo); err != nil {
 		log.Error().Err(err).Msg("Failed to parse request body")
@@ -62,7 +107,18 @@ func (h *TodoHandler) CreateTodo(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
 	}
 
-	if err := h.service.CreateTodo(&todo); err != nil {
+	if projectID != 0 {
+		todo.ProjectID = &projectID
+	}
+
+	if err := h.service.CreateTodo(ws, &todo); err != nil {
+		if projectID != 0 && errors.Is(err, errors.ErrUnknownProject) {
+			errorResponse := apiUtils.CreateErrorResponse("Project not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
+		if status, message, ok := todoError(err); ok {
+			return c.Status(status).JSON(apiUtils.CreateErrorResponse(message, status))
+		}
 		log.Error().Err(err).Msg("Failed to create todo")
 		errorResponse := apiUtils.CreateErrorResponse("Failed to create todo", fiber.StatusInternalServerError)
 		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
@@ -74,16 +130,24 @@ func (h *TodoHandler) CreateTodo(c *fiber.Ctx) error {
 
 // GetTodoByID retrieves a todo item by ID
 // @Summary Get a todo by ID
+// @Description Get a todo with the progress of its subtasks
 // @Tags Todos
 // @Produce json
 // @Param id path int true "Todo ID"
 // @Success 200 {object} apiUtils.Response[models.Todo]
 // @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
 // @Failure 404 {object} apiUtils.ErrorResponse
 // @Failure 500 {object} apiUtils.ErrorResponse
 // @Router /todos/{id} [get]
 // @Security ApiKeyAuth
 func (h *TodoHandler) GetTodoByID(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		log.Warn().Msg("Invalid ID parameter")
@@ -91,7 +155,7 @@ func (h *TodoHandler) GetTodoByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
 	}
 
-	todo, err := h.service.GetTodoByID(uint(id))
+	todo, err := h.service.GetTodoByID(ws, uint(id))
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
@@ -109,6 +173,9 @@ func (h *TodoHandler) GetTodoByID(c *fiber.Ctx) error {
 
 // UpdateTodo updates an existing todo item
 // @Summary Update a todo
+// @Description Replace a todo. Its status can move from todo or in_progress to any other status, from blocked to todo, in_progress or cancelled, from done to todo or in_progress and from cancelled to todo.
+// @Description Without a status, completed marks the todo done or reopens it. Marking a todo done or cancelled does the same to its open subtasks; a todo with a blocked subtask cannot be marked done.
+// @Description Marking a recurring todo done creates the todo for its next occurrence, which is returned as next and takes the recurrence over.
 // @Tags Todos
 // @Accept json
 // @Produce json
@@ -116,10 +183,19 @@ func (h *TodoHandler) GetTodoByID(c *fiber.Ctx) error {
 // @Param todo body models.Todo true "Todo item"
 // @Success 200 {object} apiUtils.Response[models.Todo]
 // @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
 // @Failure 500 {object} apiUtils.ErrorResponse
 // @Router /todos/{id} [put]
 // @Security ApiKeyAuth
 func (h *TodoHandler) UpdateTodo(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		log.Warn().Msg("Invalid ID parameter")
@@ -141,7 +217,15 @@ func (h *TodoHandler) UpdateTodo(c *fiber.Ctx) error {
 	}
 
 	todo.ID = uint(id)
-	if err := h.service.UpdateTodo(&todo); err != nil {
+	if err := h.service.UpdateTodo(ws, &todo); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
+			errorResponse := apiUtils.CreateErrorResponse("Todo not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
+		if status, message, ok := todoError(err); ok {
+			return c.Status(status).JSON(apiUtils.CreateErrorResponse(message, status))
+		}
 		log.Error().Err(err).Msg("Failed to update todo")
 		errorResponse := apiUtils.CreateErrorResponse("Failed to update todo", fiber.StatusInternalServerError)
 		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
@@ -153,14 +237,23 @@ func (h *TodoHandler) UpdateTodo(c *fiber.Ctx) error {
 
 // DeleteTodo deletes a todo item
 // @Summary Delete a todo
+// @Description Delete a todo together with its subtasks
 // @Tags Todos
 // @Param id path int true "Todo ID"
 // @Success 204 "No Content"
 // @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
 // @Failure 500 {object} apiUtils.ErrorResponse
 // @Router /todos/{id} [delete]
 // @Security ApiKeyAuth
 func (h *TodoHandler) DeleteTodo(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		log.Warn().Msg("Invalid ID parameter")
@@ -168,7 +261,12 @@ func (h *TodoHandler) DeleteTodo(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
 	}
 
-	if err := h.service.DeleteTodo(uint(id)); err != nil {
+	if err := h.service.DeleteTodo(ws, uint(id)); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
+			errorResponse := apiUtils.CreateErrorResponse("Todo not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
 		log.Error().Err(err).Msg("Failed to delete todo")
 		errorResponse := apiUtils.CreateErrorResponse("Failed to delete todo", fiber.StatusInternalServerError)
 		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
@@ -179,17 +277,59 @@ func (h *TodoHandler) DeleteTodo(c *fiber.Ctx) error {
 
 // ListTodos retrieves all todo items with pagination
 // @Summary Get all todos
-// @Description Get a paginated list of todos
+// @Description Get a paginated list of todos, optionally only those with any or all of the given labels. Todos of archived projects are left out unless include_archived is set.
 // @Tags Todos
 // @Produce json
+// @Param label query string false "Comma separated label names"
+// @Param label_match query string false "Whether todos need any or all of the labels" Enums(any, all) default(any)
+// @Param include_archived query bool false "Include the todos of archived projects" default(false)
 // @Param page query int false "Page number" default(1) minimum(1)
 // @Param page_size query int false "Page size" default(10) minimum(1) maximum(100)
 // @Success 200 {object} apiUtils.Response[[]models.Todo]
 // @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
 // @Failure 500 {object} apiUtils.ErrorResponse
 // @Router /todos [get]
 // @Security ApiKeyAuth
 func (h *TodoHandler) ListTodos(c *fiber.Ctx) error {
+	return h.listTodos(c, models.TodoFilter{IncludeArchived: c.QueryBool("include_archived")})
+}
+
+// ListProjectTodos retrieves the todo items of a project with pagination
+// @Summary Get the todos of a project
+// @Description Get a paginated list of the todos of a project, archived or not, optionally only those with any or all of the given labels
+// @Tags Projects
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param label query string false "Comma separated label names"
+// @Param label_match query string false "Whether todos need any or all of the labels" Enums(any, all) default(any)
+// @Param page query int false "Page number" default(1) minimum(1)
+// @Param page_size query int false "Page size" default(10) minimum(1) maximum(100)
+// @Success 200 {object} apiUtils.Response[[]models.Todo]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /projects/{id}/todos [get]
+// @Security ApiKeyAuth
+func (h *TodoHandler) ListProjectTodos(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id < 1 {
+		log.Warn().Msg("Invalid ID parameter")
+		errorResponse := apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+	return h.listTodos(c, models.TodoFilter{ProjectID: uint(id)})
+}
+
+// listTodos lists the todos matching filter and the label query parameters
+func (h *TodoHandler) listTodos(c *fiber.Ctx, filter models.TodoFilter) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
 	page := c.QueryInt("page", 1)
 	pageSize := c.QueryInt("page_size", 10)
 
@@ -203,8 +343,26 @@ func (h *TodoHandler) ListTodos(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
 	}
 
-	todos, total, err := h.service.ListTodos(page, pageSize)
+	for _, name := range strings.Split(c.Query("label"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			filter.Labels = append(filter.Labels, name)
+		}
+	}
+	switch c.Query("label_match", "any") {
+	case "any":
+	case "all":
+		filter.MatchAllLabels = true
+	default:
+		errorResponse := apiUtils.CreateErrorResponse("Invalid label_match", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	todos, total, err := h.service.ListTodos(ws, filter, page, pageSize)
 	if err != nil {
+		if errors.Is(err, errors.ErrUnknownProject) {
+			errorResponse := apiUtils.CreateErrorResponse("Project not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
 		errorResponse := apiUtils.CreateErrorResponse("Failed to fetch todos", fiber.StatusInternalServerError)
 		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
 	}
@@ -212,3 +370,294 @@ func (h *TodoHandler) ListTodos(c *fiber.Ctx) error {
 	response := apiUtils.CreateResponse[[]models.Todo](todos, page, pageSize, int(total))
 	return c.JSON(response)
 }
+
+// MoveTodo moves a todo item between projects
+// @Summary Move a todo to another project
+// @Description Move a todo into a project, or out of its project with a null project_id. Todos cannot be moved into archived projects.
+// @Tags Todos
+// @Accept json
+// @Produce json
+// @Param id path int true "Todo ID"
+// @Param request body models.MoveTodoRequest true "Target project"
+// @Success 200 {object} apiUtils.Response[models.Todo]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /todos/{id}/project [put]
+// @Security ApiKeyAuth
+func (h *TodoHandler) MoveTodo(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn().Msg("Invalid ID parameter")
+		errorResponse := apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request models.MoveTodoRequest
+	if err := c.BodyParser(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		errorResponse := apiUtils.CreateErrorResponse("Cannot parse JSON", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	todo, err := h.service.MoveTodo(ws, uint(id), request.ProjectID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
+			errorResponse := apiUtils.CreateErrorResponse("Todo not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
+		if status, message, ok := todoError(err); ok {
+			return c.Status(status).JSON(apiUtils.CreateErrorResponse(message, status))
+		}
+		log.Error().Err(err).Msg("Failed to move todo")
+		errorResponse := apiUtils.CreateErrorResponse("Failed to move todo", fiber.StatusInternalServerError)
+		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+	}
+
+	response := apiUtils.CreateResponse[models.Todo](todo)
+	return c.JSON(response)
+}
+
+// GetTodoSubtree retrieves a todo item with its subtasks
+// @Summary Get a todo with its subtasks
+// @Description Get a todo with its subtasks nested below it at any depth. Each todo of the tree has the progress of its own subtasks.
+// @Tags Todos
+// @Produce json
+// @Param id path int true "Todo ID"
+// @Success 200 {object} apiUtils.Response[models.Todo]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /todos/{id}/subtree [get]
+// @Security ApiKeyAuth
+func (h *TodoHandler) GetTodoSubtree(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn().Msg("Invalid ID parameter")
+		errorResponse := apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	todo, err := h.service.GetSubtree(ws, uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
+			errorResponse := apiUtils.CreateErrorResponse("Todo not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
+		log.Error().Err(err).Msg("Failed to retrieve todo subtree")
+		errorResponse := apiUtils.CreateErrorResponse("Failed to retrieve todo", fiber.StatusInternalServerError)
+		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+	}
+
+	response := apiUtils.CreateResponse[models.Todo](todo)
+	return c.JSON(response)
+}
+
+// SetTodoParent makes a todo item a subtask of another
+// @Summary Move a todo below another todo
+// @Description Make a todo a subtask of the todo given by parent_id, or a top-level todo with a null parent_id. The todo keeps its own subtasks. A todo cannot become a subtask of itself or of one of its subtasks, and subtasks cannot be nested deeper than the configured limit.
+// @Tags Todos
+// @Accept json
+// @Produce json
+// @Param id path int true "Todo ID"
+// @Param request body models.SetParentRequest true "Parent todo"
+// @Success 200 {object} apiUtils.Response[models.Todo]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /todos/{id}/parent [put]
+// @Security ApiKeyAuth
+func (h *TodoHandler) SetTodoParent(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn().Msg("Invalid ID parameter")
+		errorResponse := apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	var request models.SetParentRequest
+	if err := c.BodyParser(&request); err != nil {
+		log.Error().Err(err).Msg("Failed to parse request body")
+		errorResponse := apiUtils.CreateErrorResponse("Cannot parse JSON", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	todo, err := h.service.SetParent(ws, uint(id), request.ParentID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
+			errorResponse := apiUtils.CreateErrorResponse("Todo not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
+		if status, message, ok := todoError(err); ok {
+			return c.Status(status).JSON(apiUtils.CreateErrorResponse(message, status))
+		}
+		log.Error().Err(err).Msg("Failed to set todo parent")
+		errorResponse := apiUtils.CreateErrorResponse("Failed to update todo", fiber.StatusInternalServerError)
+		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+	}
+
+	response := apiUtils.CreateResponse[models.Todo](todo)
+	return c.JSON(response)
+}
+
+// SkipOccurrence skips the current occurrence of a recurring todo
+// @Summary Skip an occurrence of a recurring todo
+// @Description Move a recurring todo to its next occurrence without completing it. The skipped date is added to recurrence_exceptions.
+// @Tags Todos
+// @Produce json
+// @Param id path int true "Todo ID"
+// @Success 200 {object} apiUtils.Response[models.Todo]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 409 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /todos/{id}/skip [post]
+// @Security ApiKeyAuth
+func (h *TodoHandler) SkipOccurrence(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn().Msg("Invalid ID parameter")
+		errorResponse := apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	todo, err := h.service.SkipOccurrence(ws, uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
+			errorResponse := apiUtils.CreateErrorResponse("Todo not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
+		if status, message, ok := todoError(err); ok {
+			return c.Status(status).JSON(apiUtils.CreateErrorResponse(message, status))
+		}
+		log.Error().Err(err).Msg("Failed to skip occurrence")
+		errorResponse := apiUtils.CreateErrorResponse("Failed to update todo", fiber.StatusInternalServerError)
+		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+	}
+
+	response := apiUtils.CreateResponse[models.Todo](todo)
+	return c.JSON(response)
+}
+
+// ListOccurrences previews the next occurrences of a recurring todo
+// @Summary Preview the occurrences of a recurring todo
+// @Description Get the start and due dates of the next occurrences of a recurring todo after its current one, leaving out skipped dates
+// @Tags Todos
+// @Produce json
+// @Param id path int true "Todo ID"
+// @Param count query int false "Number of occurrences" default(5) minimum(1) maximum(50)
+// @Success 200 {object} apiUtils.Response[[]models.TodoOccurrence]
+// @Failure 400 {object} apiUtils.ErrorResponse
+// @Failure 401 {object} apiUtils.ErrorResponse
+// @Failure 404 {object} apiUtils.ErrorResponse
+// @Failure 500 {object} apiUtils.ErrorResponse
+// @Router /todos/{id}/occurrences [get]
+// @Security ApiKeyAuth
+func (h *TodoHandler) ListOccurrences(c *fiber.Ctx) error {
+	ws, ok := currentWorkspace(c)
+	if !ok {
+		errorResponse := apiUtils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized)
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		log.Warn().Msg("Invalid ID parameter")
+		errorResponse := apiUtils.CreateErrorResponse("Invalid ID", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	count := c.QueryInt("count", 5)
+	if count < 1 || count > 50 {
+		errorResponse := apiUtils.CreateErrorResponse("Invalid count", fiber.StatusBadRequest)
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	occurrences, err := h.service.ListOccurrences(ws, uint(id), count)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Warn().Uint("id", uint(id)).Msg("Todo not found")
+			errorResponse := apiUtils.CreateErrorResponse("Todo not found", fiber.StatusNotFound)
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse)
+		}
+		if status, message, ok := todoError(err); ok {
+			return c.Status(status).JSON(apiUtils.CreateErrorResponse(message, status))
+		}
+		log.Error().Err(err).Msg("Failed to list occurrences")
+		errorResponse := apiUtils.CreateErrorResponse("Failed to list occurrences", fiber.StatusInternalServerError)
+		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+	}
+
+	response := apiUtils.CreateResponse[[]models.TodoOccurrence](occurrences)
+	return c.JSON(response)
+}
+
+// todoError returns the response status and message for the errors the todo
+// service returns for invalid changes, or false for other errors
+func todoError(err error) (int, string, bool) {
+	var statusErr *errors.TodoStatusError
+	var recurrenceErr *errors.RecurrenceError
+	switch {
+	case errors.As(err, &statusErr):
+		return fiber.StatusConflict, "Cannot change status from " + statusErr.From + " to " + statusErr.To, true
+	case errors.As(err, &recurrenceErr):
+		return fiber.StatusBadRequest, "Invalid recurrence: " + recurrenceErr.Reason, true
+	case errors.Is(err, errors.ErrNotRecurring):
+		return fiber.StatusBadRequest, "Todo does not recur", true
+	case errors.Is(err, errors.ErrNoOccurrences):
+		return fiber.StatusConflict, "No further occurrences", true
+	case errors.Is(err, errors.ErrInvalidSchedule):
+		return fiber.StatusBadRequest, "start_at must not be after due_at", true
+	case errors.Is(err, errors.ErrUnknownLabel):
+		return fiber.StatusBadRequest, "Unknown label", true
+	case errors.Is(err, errors.ErrUnknownProject):
+		return fiber.StatusBadRequest, "Unknown project", true
+	case errors.Is(err, errors.ErrProjectArchived):
+		return fiber.StatusConflict, "Project is archived", true
+	case errors.Is(err, errors.ErrProjectRestricted):
+		return fiber.StatusForbidden, "API key is restricted to other projects", true
+	case errors.Is(err, errors.ErrUnknownParent):
+		return fiber.StatusBadRequest, "Unknown parent todo", true
+	case errors.Is(err, errors.ErrTodoTooDeep):
+		return fiber.StatusBadRequest, "Subtasks are nested too deeply", true
+	case errors.Is(err, errors.ErrTodoCycle):
+		return fiber.StatusConflict, "A todo cannot be a subtask of itself or of its subtasks", true
+	case errors.Is(err, errors.ErrBlockedSubtask):
+		return fiber.StatusConflict, "Cannot complete a todo with a blocked subtask", true
+	}
+	return 0, "", false
+}
@@ -3,13 +3,16 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"io"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	apiUtils "github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
 	"github.com/netf/gofiber-boilerplate/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -20,31 +23,69 @@ type MockTodoService struct {
 	mock.Mock
 }
 
-func (m *MockTodoService) CreateTodo(todo *models.Todo) error {
-	args := m.Called(todo)
+func (m *MockTodoService) CreateTodo(ws models.Workspace, todo *models.Todo) error {
+	args := m.Called(ws, todo)
 	return args.Error(0)
 }
 
-func (m *MockTodoService) GetTodoByID(id uint) (*models.Todo, error) {
-	args := m.Called(id)
+func (m *MockTodoService) GetTodoByID(ws models.Workspace, id uint) (*models.Todo, error) {
+	args := m.Called(ws, id)
 	return args.Get(0).(*models.Todo), args.Error(1)
 }
 
-func (m *MockTodoService) UpdateTodo(todo *models.Todo) error {
-	args := m.Called(todo)
+func (m *MockTodoService) UpdateTodo(ws models.Workspace, todo *models.Todo) error {
+	args := m.Called(ws, todo)
 	return args.Error(0)
 }
 
-func (m *MockTodoService) DeleteTodo(id uint) error {
-	args := m.Called(id)
+func (m *MockTodoService) DeleteTodo(ws models.Workspace, id uint) error {
+	args := m.Called(ws, id)
 	return args.Error(0)
 }
 
-func (m *MockTodoService) ListTodos(page, pageSize int) ([]models.Todo, int64, error) {
-	args := m.Called(page, pageSize)
+func (m *MockTodoService) ListTodos(ws models.Workspace, filter models.TodoFilter, page, pageSize int) ([]models.Todo, int64, error) {
+	args := m.Called(ws, filter, page, pageSize)
 	return args.Get(0).([]models.Todo), args.Get(1).(int64), args.Error(2)
 }
 
+func (m *MockTodoService) MoveTodo(ws models.Workspace, id uint, projectID *uint) (*models.Todo, error) {
+	args := m.Called(ws, id, projectID)
+	todo, _ := args.Get(0).(*models.Todo)
+	return todo, args.Error(1)
+}
+
+func (m *MockTodoService) SetParent(ws models.Workspace, id uint, parentID *uint) (*models.Todo, error) {
+	args := m.Called(ws, id, parentID)
+	todo, _ := args.Get(0).(*models.Todo)
+	return todo, args.Error(1)
+}
+
+func (m *MockTodoService) GetSubtree(ws models.Workspace, id uint) (*models.Todo, error) {
+	args := m.Called(ws, id)
+	todo, _ := args.Get(0).(*models.Todo)
+	return todo, args.Error(1)
+}
+
+func (m *MockTodoService) SkipOccurrence(ws models.Workspace, id uint) (*models.Todo, error) {
+	args := m.Called(ws, id)
+	todo, _ := args.Get(0).(*models.Todo)
+	return todo, args.Error(1)
+}
+
+func (m *MockTodoService) ListOccurrences(ws models.Workspace, id uint, n int) ([]models.TodoOccurrence, error) {
+	args := m.Called(ws, id, n)
+	occurrences, _ := args.Get(0).([]models.TodoOccurrence)
+	return occurrences, args.Error(1)
+}
+
+// withUser stores claims for the given user in the request context, as middleware.JWTAuth would
+func withUser(userID uint) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: userID})
+		return c.Next()
+	}
+}
+
 func TestCreateTodo(t *testing.T) {
 	mockService := new(MockTodoService)
 
@@ -58,10 +99,11 @@ func TestCreateTodo(t *testing.T) {
 	}
 
 	app := fiber.New()
+	app.Use(withUser(1))
 	app.Post("/todos", handler.CreateTodo)
 
 	todo := models.Todo{Title: "Test Todo", Completed: false}
-	mockService.On("CreateTodo", mock.AnythingOfType("*models.Todo")).Return(nil)
+	mockService.On("CreateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).Return(nil)
 
 	body, _ := json.Marshal(todo)
 	req := httptest.NewRequest("POST", "/todos", bytes.NewReader(body))
@@ -78,10 +120,11 @@ func TestGetTodoByID(t *testing.T) {
 	handler := NewTodoHandler(mockService)
 
 	app := fiber.New()
+	app.Use(withUser(1))
 	app.Get("/todos/:id", handler.GetTodoByID)
 
 	todo := &models.Todo{ID: 1, Title: "Test Todo", Completed: false}
-	mockService.On("GetTodoByID", uint(1)).Return(todo, nil)
+	mockService.On("GetTodoByID", models.Workspace{UserID: 1}, uint(1)).Return(todo, nil)
 
 	req := httptest.NewRequest("GET", "/todos/1", nil)
 	resp, _ := app.Test(req)
@@ -90,14 +133,34 @@ func TestGetTodoByID(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestTodosUseOrganizationOfToken(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, OrganizationID: 3})
+		return c.Next()
+	})
+	app.Get("/todos/:id", handler.GetTodoByID)
+
+	mockService.On("GetTodoByID", models.Workspace{UserID: 1, OrganizationID: 3}, uint(1)).Return(&models.Todo{ID: 1, Title: "Shared Todo"}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/todos/1", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
 func TestGetTodoByIDNotFound(t *testing.T) {
 	mockService := new(MockTodoService)
 	handler := NewTodoHandler(mockService)
 
 	app := fiber.New()
+	app.Use(withUser(1))
 	app.Get("/todos/:id", handler.GetTodoByID)
 
-	mockService.On("GetTodoByID", uint(1)).Return(&models.Todo{}, gorm.ErrRecordNotFound)
+	mockService.On("GetTodoByID", models.Workspace{UserID: 1}, uint(1)).Return(&models.Todo{}, gorm.ErrRecordNotFound)
 
 	req := httptest.NewRequest("GET", "/todos/1", nil)
 	resp, _ := app.Test(req)
@@ -111,10 +174,11 @@ func TestUpdateTodo(t *testing.T) {
 	handler := NewTodoHandler(mockService)
 
 	app := fiber.New()
+	app.Use(withUser(1))
 	app.Put("/todos/:id", handler.UpdateTodo)
 
 	todo := models.Todo{ID: 1, Title: "Updated Todo", Completed: true}
-	mockService.On("UpdateTodo", mock.AnythingOfType("*models.Todo")).Return(nil)
+	mockService.On("UpdateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).Return(nil)
 
 	body, _ := json.Marshal(todo)
 	req := httptest.NewRequest("PUT", "/todos/1", bytes.NewReader(body))
@@ -131,9 +195,10 @@ func TestDeleteTodo(t *testing.T) {
 	handler := NewTodoHandler(mockService)
 
 	app := fiber.New()
+	app.Use(withUser(1))
 	app.Delete("/todos/:id", handler.DeleteTodo)
 
-	mockService.On("DeleteTodo", uint(1)).Return(nil)
+	mockService.On("DeleteTodo", models.Workspace{UserID: 1}, uint(1)).Return(nil)
 
 	req := httptest.NewRequest("DELETE", "/todos/1", nil)
 	resp, _ := app.Test(req)
@@ -147,6 +212,7 @@ func TestListTodos(t *testing.T) {
 	handler := NewTodoHandler(mockService)
 
 	app := fiber.New()
+	app.Use(withUser(1))
 	app.Get("/api/v1/todos", handler.ListTodos)
 
 	testCases := []struct {
@@ -169,7 +235,7 @@ func TestListTodos(t *testing.T) {
 			mockTotal: 2,
 			mockError: nil,
 			setupMock: func(m *MockTodoService) {
-				m.On("ListTodos", 1, 10).Return([]models.Todo{
+				m.On("ListTodos", models.Workspace{UserID: 1}, models.TodoFilter{}, 1, 10).Return([]models.Todo{
 					{ID: 1, Title: "Todo 1", Completed: false},
 					{ID: 2, Title: "Todo 2", Completed: true},
 				}, int64(2), nil)
@@ -186,7 +252,7 @@ func TestListTodos(t *testing.T) {
 			mockTotal: 7,
 			mockError: nil,
 			setupMock: func(m *MockTodoService) {
-				m.On("ListTodos", 2, 5).Return([]models.Todo{
+				m.On("ListTodos", models.Workspace{UserID: 1}, models.TodoFilter{}, 2, 5).Return([]models.Todo{
 					{ID: 6, Title: "Todo 6", Completed: false},
 					{ID: 7, Title: "Todo 7", Completed: true},
 				}, int64(7), nil)
@@ -204,12 +270,51 @@ func TestListTodos(t *testing.T) {
 			expectedStatus: fiber.StatusBadRequest,
 			setupMock:      func(m *MockTodoService) {}, // No mock setup needed for validation error
 		},
+		{
+			name:           "Success - Any Label",
+			query:          "?label=work,%20urgent",
+			expectedStatus: fiber.StatusOK,
+			mockTodos:      []models.Todo{{ID: 3, Title: "Todo 3"}},
+			mockTotal:      1,
+			setupMock: func(m *MockTodoService) {
+				filter := models.TodoFilter{Labels: []string{"work", "urgent"}}
+				m.On("ListTodos", models.Workspace{UserID: 1}, filter, 1, 10).Return([]models.Todo{{ID: 3, Title: "Todo 3"}}, int64(1), nil)
+			},
+		},
+		{
+			name:           "Success - All Labels",
+			query:          "?label=work,urgent&label_match=all",
+			expectedStatus: fiber.StatusOK,
+			mockTodos:      []models.Todo{{ID: 4, Title: "Todo 4"}},
+			mockTotal:      1,
+			setupMock: func(m *MockTodoService) {
+				filter := models.TodoFilter{Labels: []string{"work", "urgent"}, MatchAllLabels: true}
+				m.On("ListTodos", models.Workspace{UserID: 1}, filter, 1, 10).Return([]models.Todo{{ID: 4, Title: "Todo 4"}}, int64(1), nil)
+			},
+		},
+		{
+			name:           "Success - Include Archived Projects",
+			query:          "?include_archived=true",
+			expectedStatus: fiber.StatusOK,
+			mockTodos:      []models.Todo{{ID: 5, Title: "Todo 5"}},
+			mockTotal:      1,
+			setupMock: func(m *MockTodoService) {
+				filter := models.TodoFilter{IncludeArchived: true}
+				m.On("ListTodos", models.Workspace{UserID: 1}, filter, 1, 10).Return([]models.Todo{{ID: 5, Title: "Todo 5"}}, int64(1), nil)
+			},
+		},
+		{
+			name:           "Error - Invalid Label Match",
+			query:          "?label=work&label_match=some",
+			expectedStatus: fiber.StatusBadRequest,
+			setupMock:      func(m *MockTodoService) {},
+		},
 		{
 			name:           "Error - Service Failure",
 			query:          "",
 			expectedStatus: fiber.StatusInternalServerError,
 			setupMock: func(m *MockTodoService) {
-				m.On("ListTodos", 1, 10).Return([]models.Todo{}, int64(0), errors.New("service error"))
+				m.On("ListTodos", models.Workspace{UserID: 1}, models.TodoFilter{}, 1, 10).Return([]models.Todo{}, int64(0), errors.New("service error"))
 			},
 		},
 	}
@@ -272,10 +377,11 @@ func TestCreateTodoError(t *testing.T) {
 	handler := NewTodoHandler(mockService)
 
 	app := fiber.New()
+	app.Use(withUser(1))
 	app.Post("/todos", handler.CreateTodo)
 
 	todo := models.Todo{Title: "Test Todo", Completed: false}
-	mockService.On("CreateTodo", mock.AnythingOfType("*models.Todo")).Return(errors.New("database error"))
+	mockService.On("CreateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).Return(errors.New("database error"))
 
 	body, _ := json.Marshal(todo)
 	req := httptest.NewRequest("POST", "/todos", bytes.NewReader(body))
@@ -286,3 +392,525 @@ func TestCreateTodoError(t *testing.T) {
 	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
 	mockService.AssertExpectations(t)
 }
+
+func TestTodoRequiresUser(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/todos", handler.ListTodos)
+
+	req := httptest.NewRequest("GET", "/todos", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	mockService.AssertNotCalled(t, "ListTodos", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateTodoNotOwned(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(2))
+	app.Put("/todos/:id", handler.UpdateTodo)
+
+	todo := models.Todo{Title: "Someone else's todo"}
+	mockService.On("UpdateTodo", models.Workspace{UserID: 2}, mock.AnythingOfType("*models.Todo")).Return(gorm.ErrRecordNotFound)
+
+	body, _ := json.Marshal(todo)
+	req := httptest.NewRequest("PUT", "/todos/1", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteTodoNotOwned(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(2))
+	app.Delete("/todos/:id", handler.DeleteTodo)
+
+	mockService.On("DeleteTodo", models.Workspace{UserID: 2}, uint(1)).Return(gorm.ErrRecordNotFound)
+
+	req := httptest.NewRequest("DELETE", "/todos/1", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateTodoValidatesFields(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/todos", handler.CreateTodo)
+
+	mockService.On("CreateTodo", models.Workspace{UserID: 1}, mock.MatchedBy(func(todo *models.Todo) bool {
+		return todo.Title == "Late todo"
+	})).Return(errors.ErrInvalidSchedule)
+	mockService.On("CreateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).Return(nil)
+
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Full Todo", body: `{"title":"Write report","description":"**Q3** numbers","status":"in_progress","priority":"high","start_at":"2024-05-01T09:00:00+02:00","due_at":"2024-05-03T17:00:00+02:00","time_zone":"Europe/Berlin"}`, expectedStatus: fiber.StatusCreated},
+		{name: "Unknown Status", body: `{"title":"Write report","status":"waiting"}`, expectedStatus: fiber.StatusBadRequest},
+		{name: "Unknown Priority", body: `{"title":"Write report","priority":"critical"}`, expectedStatus: fiber.StatusBadRequest},
+		{name: "Unknown Time Zone", body: `{"title":"Write report","time_zone":"Mars/Olympus"}`, expectedStatus: fiber.StatusBadRequest},
+		{name: "Starts After Due", body: `{"title":"Late todo","start_at":"2024-05-04T09:00:00Z","due_at":"2024-05-03T17:00:00Z"}`, expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/todos", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestUpdateTodoInvalidTransition(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Put("/todos/:id", handler.UpdateTodo)
+
+	mockService.On("UpdateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).
+		Return(&errors.TodoStatusError{From: models.TodoStatusCancelled, To: models.TodoStatusDone})
+
+	req := httptest.NewRequest("PUT", "/todos/1", bytes.NewReader([]byte(`{"title":"Write report","status":"done"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+	var body apiUtils.ErrorResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "Cannot change status from cancelled to done", body.Error)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateTodoUnknownLabel(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/todos", handler.CreateTodo)
+
+	mockService.On("CreateTodo", models.Workspace{UserID: 1}, mock.MatchedBy(func(todo *models.Todo) bool {
+		return assert.ObjectsAreEqual([]uint{4, 9}, todo.LabelIDs)
+	})).Return(errors.ErrUnknownLabel)
+
+	req := httptest.NewRequest("POST", "/todos", bytes.NewReader([]byte(`{"title":"Write report","label_ids":[4,9]}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestProjectTodos(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Get("/projects/:id/todos", handler.ListProjectTodos)
+	app.Post("/projects/:id/todos", handler.CreateProjectTodo)
+
+	ws := models.Workspace{UserID: 1}
+	mockService.On("ListTodos", ws, models.TodoFilter{ProjectID: 3}, 1, 10).Return([]models.Todo{{ID: 1, Title: "Todo 1"}}, int64(1), nil)
+	mockService.On("ListTodos", ws, models.TodoFilter{ProjectID: 4}, 1, 10).Return([]models.Todo(nil), int64(0), errors.ErrUnknownProject)
+	mockService.On("CreateTodo", ws, mock.MatchedBy(func(todo *models.Todo) bool {
+		return todo.ProjectID != nil && *todo.ProjectID == 3
+	})).Return(nil)
+	mockService.On("CreateTodo", ws, mock.MatchedBy(func(todo *models.Todo) bool {
+		return todo.ProjectID != nil && *todo.ProjectID == 4
+	})).Return(errors.ErrUnknownProject)
+	mockService.On("CreateTodo", ws, mock.MatchedBy(func(todo *models.Todo) bool {
+		return todo.ProjectID != nil && *todo.ProjectID == 5
+	})).Return(errors.ErrProjectArchived)
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "List", method: "GET", path: "/projects/3/todos", expectedStatus: fiber.StatusOK},
+		{name: "List Unknown Project", method: "GET", path: "/projects/4/todos", expectedStatus: fiber.StatusNotFound},
+		{name: "List Invalid ID", method: "GET", path: "/projects/abc/todos", expectedStatus: fiber.StatusBadRequest},
+		{name: "Create", method: "POST", path: "/projects/3/todos", expectedStatus: fiber.StatusCreated},
+		{name: "Create In Unknown Project", method: "POST", path: "/projects/4/todos", expectedStatus: fiber.StatusNotFound},
+		{name: "Create In Archived Project", method: "POST", path: "/projects/5/todos", expectedStatus: fiber.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(`{"title":"Write report"}`)))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestMoveTodo(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Put("/todos/:id/project", handler.MoveTodo)
+
+	ws := models.Workspace{UserID: 1}
+	projectID := uint(3)
+	mockService.On("MoveTodo", ws, uint(1), &projectID).Return(&models.Todo{ID: 1, Title: "Write report", ProjectID: &projectID}, nil)
+	mockService.On("MoveTodo", ws, uint(1), (*uint)(nil)).Return(&models.Todo{ID: 1, Title: "Write report"}, nil)
+	mockService.On("MoveTodo", ws, uint(2), &projectID).Return(nil, gorm.ErrRecordNotFound)
+	archivedID := uint(4)
+	mockService.On("MoveTodo", ws, uint(1), &archivedID).Return(nil, errors.ErrProjectArchived)
+	unknownID := uint(5)
+	mockService.On("MoveTodo", ws, uint(1), &unknownID).Return(nil, errors.ErrUnknownProject)
+
+	testCases := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Into Project", path: "/todos/1/project", body: `{"project_id":3}`, expectedStatus: fiber.StatusOK},
+		{name: "Out Of Project", path: "/todos/1/project", body: `{"project_id":null}`, expectedStatus: fiber.StatusOK},
+		{name: "Unknown Todo", path: "/todos/2/project", body: `{"project_id":3}`, expectedStatus: fiber.StatusNotFound},
+		{name: "Archived Project", path: "/todos/1/project", body: `{"project_id":4}`, expectedStatus: fiber.StatusConflict},
+		{name: "Unknown Project", path: "/todos/1/project", body: `{"project_id":5}`, expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", tc.path, bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestSetTodoParent(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Put("/todos/:id/parent", handler.SetTodoParent)
+
+	ws := models.Workspace{UserID: 1}
+	parentID := uint(7)
+	mockService.On("SetParent", ws, uint(1), &parentID).Return(&models.Todo{ID: 1, Title: "Write report", ParentID: &parentID}, nil)
+	mockService.On("SetParent", ws, uint(1), (*uint)(nil)).Return(&models.Todo{ID: 1, Title: "Write report"}, nil)
+	mockService.On("SetParent", ws, uint(2), &parentID).Return(nil, errors.ErrTodoCycle)
+	mockService.On("SetParent", ws, uint(3), &parentID).Return(nil, errors.ErrTodoTooDeep)
+	mockService.On("SetParent", ws, uint(4), &parentID).Return(nil, errors.ErrUnknownParent)
+	mockService.On("SetParent", ws, uint(5), &parentID).Return(nil, gorm.ErrRecordNotFound)
+
+	testCases := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Below Parent", path: "/todos/1/parent", body: `{"parent_id":7}`, expectedStatus: fiber.StatusOK},
+		{name: "Top Level", path: "/todos/1/parent", body: `{"parent_id":null}`, expectedStatus: fiber.StatusOK},
+		{name: "Cycle", path: "/todos/2/parent", body: `{"parent_id":7}`, expectedStatus: fiber.StatusConflict, expectedError: "A todo cannot be a subtask of itself or of its subtasks"},
+		{name: "Too Deep", path: "/todos/3/parent", body: `{"parent_id":7}`, expectedStatus: fiber.StatusBadRequest, expectedError: "Subtasks are nested too deeply"},
+		{name: "Unknown Parent", path: "/todos/4/parent", body: `{"parent_id":7}`, expectedStatus: fiber.StatusBadRequest, expectedError: "Unknown parent todo"},
+		{name: "Unknown Todo", path: "/todos/5/parent", body: `{"parent_id":7}`, expectedStatus: fiber.StatusNotFound, expectedError: "Todo not found"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", tc.path, bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			if tc.expectedError != "" {
+				var body apiUtils.ErrorResponse
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, tc.expectedError, body.Error)
+			}
+		})
+	}
+}
+
+func TestGetTodoSubtree(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Get("/todos/:id/subtree", handler.GetTodoSubtree)
+
+	parentID := uint(1)
+	tree := &models.Todo{
+		ID:       1,
+		Title:    "Release",
+		Progress: &models.TodoProgress{Done: 1, Total: 2},
+		Subtasks: []models.Todo{
+			{ID: 2, Title: "Changelog", ParentID: &parentID, Status: models.TodoStatusDone, Progress: &models.TodoProgress{}},
+			{ID: 3, Title: "Tag", ParentID: &parentID, Status: models.TodoStatusTodo, Progress: &models.TodoProgress{}},
+		},
+	}
+	mockService.On("GetSubtree", models.Workspace{UserID: 1}, uint(1)).Return(tree, nil)
+	mockService.On("GetSubtree", models.Workspace{UserID: 1}, uint(2)).Return(nil, gorm.ErrRecordNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/todos/1/subtree", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data models.Todo `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, &models.TodoProgress{Done: 1, Total: 2}, body.Data.Progress)
+	if assert.Len(t, body.Data.Subtasks, 2) {
+		assert.Equal(t, "Changelog", body.Data.Subtasks[0].Title)
+		assert.Equal(t, &parentID, body.Data.Subtasks[1].ParentID)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/todos/2/subtree", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestUpdateTodoWithBlockedSubtask(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Put("/todos/:id", handler.UpdateTodo)
+
+	mockService.On("UpdateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).Return(errors.ErrBlockedSubtask)
+
+	req := httptest.NewRequest("PUT", "/todos/1", bytes.NewReader([]byte(`{"title":"Release","status":"done"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestCompleteRecurringTodo(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Put("/todos/:id", handler.UpdateTodo)
+
+	due := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	nextDue := due.AddDate(0, 0, 7)
+	rule := "FREQ=WEEKLY"
+	mockService.On("UpdateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).
+		Run(func(args mock.Arguments) {
+			todo := args.Get(1).(*models.Todo)
+			todo.Recurrence = nil
+			todo.Next = &models.Todo{ID: 2, Title: todo.Title, Status: models.TodoStatusTodo, DueAt: &nextDue, Recurrence: &rule}
+		}).
+		Return(nil)
+
+	req := httptest.NewRequest("PUT", "/todos/1", bytes.NewReader([]byte(`{"title":"Water plants","status":"done","due_at":"2024-03-05T09:00:00Z","recurrence":"FREQ=WEEKLY"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data models.Todo `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Nil(t, body.Data.Recurrence)
+	if assert.NotNil(t, body.Data.Next) {
+		assert.Equal(t, uint(2), body.Data.Next.ID)
+		assert.Equal(t, &rule, body.Data.Next.Recurrence)
+		assert.True(t, nextDue.Equal(*body.Data.Next.DueAt))
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateTodoParsesRecurrence(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected *string
+	}{
+		{name: "Omitted", body: `{"title":"Water plants"}`},
+		{name: "Empty", body: `{"title":"Water plants","recurrence":""}`, expected: new(string)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockTodoService)
+			handler := NewTodoHandler(mockService)
+
+			app := fiber.New()
+			app.Use(withUser(1))
+			app.Put("/todos/:id", handler.UpdateTodo)
+
+			// The service keeps the rule if it is nil and removes it if empty
+			mockService.On("UpdateTodo", models.Workspace{UserID: 1}, mock.MatchedBy(func(todo *models.Todo) bool {
+				return assert.ObjectsAreEqual(tc.expected, todo.Recurrence)
+			})).Return(nil)
+
+			req := httptest.NewRequest("PUT", "/todos/1", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+			assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateTodoInvalidRecurrence(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/todos", handler.CreateTodo)
+
+	mockService.On("CreateTodo", models.Workspace{UserID: 1}, mock.AnythingOfType("*models.Todo")).
+		Return(&errors.RecurrenceError{Reason: "unknown frequency HOURLY"})
+
+	req := httptest.NewRequest("POST", "/todos", bytes.NewReader([]byte(`{"title":"Stretch","due_at":"2024-03-05T09:00:00Z","recurrence":"FREQ=HOURLY"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var body apiUtils.ErrorResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "Invalid recurrence: unknown frequency HOURLY", body.Error)
+}
+
+func TestSkipOccurrence(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Post("/todos/:id/skip", handler.SkipOccurrence)
+
+	ws := models.Workspace{UserID: 1}
+	due := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	rule := "FREQ=WEEKLY"
+	mockService.On("SkipOccurrence", ws, uint(1)).
+		Return(&models.Todo{ID: 1, Title: "Water plants", DueAt: &due, Recurrence: &rule, RecurrenceExceptions: []string{"2024-03-05"}}, nil)
+	mockService.On("SkipOccurrence", ws, uint(2)).Return(nil, errors.ErrNotRecurring)
+	mockService.On("SkipOccurrence", ws, uint(3)).Return(nil, errors.ErrNoOccurrences)
+	mockService.On("SkipOccurrence", ws, uint(4)).Return(nil, gorm.ErrRecordNotFound)
+
+	testCases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Success", path: "/todos/1/skip", expectedStatus: fiber.StatusOK},
+		{name: "Not Recurring", path: "/todos/2/skip", expectedStatus: fiber.StatusBadRequest, expectedError: "Todo does not recur"},
+		{name: "Last Occurrence", path: "/todos/3/skip", expectedStatus: fiber.StatusConflict, expectedError: "No further occurrences"},
+		{name: "Not Found", path: "/todos/4/skip", expectedStatus: fiber.StatusNotFound, expectedError: "Todo not found"},
+		{name: "Invalid ID", path: "/todos/abc/skip", expectedStatus: fiber.StatusBadRequest, expectedError: "Invalid ID"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := app.Test(httptest.NewRequest("POST", tc.path, nil))
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			if tc.expectedError != "" {
+				var body apiUtils.ErrorResponse
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, tc.expectedError, body.Error)
+				return
+			}
+
+			var body struct {
+				Data models.Todo `json:"data"`
+			}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, []string{"2024-03-05"}, body.Data.RecurrenceExceptions)
+		})
+	}
+}
+
+func TestListOccurrences(t *testing.T) {
+	mockService := new(MockTodoService)
+	handler := NewTodoHandler(mockService)
+
+	app := fiber.New()
+	app.Use(withUser(1))
+	app.Get("/todos/:id/occurrences", handler.ListOccurrences)
+
+	ws := models.Workspace{UserID: 1}
+	first := time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 3, 19, 9, 0, 0, 0, time.UTC)
+	occurrences := []models.TodoOccurrence{{DueAt: &first}, {DueAt: &second}}
+	mockService.On("ListOccurrences", ws, uint(1), 5).Return(occurrences, nil)
+	mockService.On("ListOccurrences", ws, uint(1), 2).Return(occurrences, nil)
+	mockService.On("ListOccurrences", ws, uint(2), 5).Return(nil, errors.ErrNotRecurring)
+	mockService.On("ListOccurrences", ws, uint(3), 5).Return(nil, gorm.ErrRecordNotFound)
+
+	testCases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{name: "Default Count", path: "/todos/1/occurrences", expectedStatus: fiber.StatusOK},
+		{name: "Count", path: "/todos/1/occurrences?count=2", expectedStatus: fiber.StatusOK},
+		{name: "Count Too Large", path: "/todos/1/occurrences?count=51", expectedStatus: fiber.StatusBadRequest},
+		{name: "Count Too Small", path: "/todos/1/occurrences?count=0", expectedStatus: fiber.StatusBadRequest},
+		{name: "Not Recurring", path: "/todos/2/occurrences", expectedStatus: fiber.StatusBadRequest},
+		{name: "Not Found", path: "/todos/3/occurrences", expectedStatus: fiber.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := app.Test(httptest.NewRequest("GET", tc.path, nil))
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			if tc.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data []models.TodoOccurrence `json:"data"`
+			}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			if assert.Len(t, body.Data, 2) {
+				assert.True(t, second.Equal(*body.Data[1].DueAt))
+				assert.Nil(t, body.Data[1].StartAt)
+			}
+		})
+	}
+	mockService.AssertExpectations(t)
+}
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -14,6 +16,9 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/netf/gofiber-boilerplate/internal/api/auth"
 	"github.com/netf/gofiber-boilerplate/internal/api/utils"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/services"
 	"github.com/rs/zerolog/log"
 )
 
@@ -83,9 +88,10 @@ func SecureHeaders() fiber.Handler {
 
 func CORSMiddleware() fiber.Handler {
 	return cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,HEAD,PUT,DELETE,PATCH",
-		AllowHeaders: "Origin, Content-Type, Accept",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,HEAD,PUT,DELETE,PATCH",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Auth-Mode, X-CSRF-Token",
+		ExposeHeaders: "X-Impersonated-By",
 	})
 }
 
@@ -120,31 +126,238 @@ func RequestID() fiber.Handler {
 	return requestid.New()
 }
 
-func JWTAuth() fiber.Handler {
+// AuthConfig holds the services JWTAuth consults to authenticate a request
+type AuthConfig struct {
+	Revocations services.RevocationService
+	APIKeys     services.APIKeyService
+	Memberships MembershipChecker
+	Audit       ImpersonationAuditor
+	// Cookies accepts the access token cookie of browser clients when the
+	// request has no Authorization header. Routes must then be guarded by CSRF.
+	Cookies bool
+}
+
+// MembershipChecker verifies that the user of a token is still a member of
+// the organization the token acts in
+type MembershipChecker interface {
+	IsMember(organizationID, userID uint) (bool, error)
+}
+
+// ImpersonationAuditor checks that the admin behind an impersonation token
+// may still impersonate and records the requests made with the token
+type ImpersonationAuditor interface {
+	CheckActor(actorID uint) error
+	Record(entry *models.ImpersonationLog) error
+}
+
+// JWTAuth verifies the bearer token and stores its claims in c.Locals("claims").
+// Tokens revoked through logout are rejected, as are tokens acting in an
+// organization the user has since left. Requests may instead present an
+// API key (a personal access token) as "Authorization: ApiKey <key>", as a
+// bearer token or in the X-API-Key header, which resolves to claims for the
+// key's owner limited to the key's scopes and projects. Browser clients may send the
+// token in the access token cookie instead if cfg.Cookies is set.
+// Impersonation tokens are only accepted with an auditor configured; see
+// impersonated.
+func JWTAuth(cfg AuthConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Missing authorization header", fiber.StatusUnauthorized))
+		if key, ok := apiKeyFromRequest(c); ok {
+			return authenticateAPIKey(c, cfg.APIKeys, key)
 		}
 
-		tokenString := authHeader[7:] // Remove "Bearer " prefix
-
-		token, err := jwt.ParseWithClaims(tokenString, &auth.Claims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		var tokenString string
+		fromCookie := false
+		if authHeader := c.Get("Authorization"); authHeader != "" {
+			scheme, token, found := strings.Cut(authHeader, " ")
+			tokenString = strings.TrimSpace(token)
+			if !found || !strings.EqualFold(scheme, "Bearer") || tokenString == "" {
+				return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Invalid authorization header", fiber.StatusUnauthorized))
 			}
-			return &auth.PrivateKey.PublicKey, nil
-		})
+		} else if cfg.Cookies && c.Cookies(auth.AccessTokenCookie) != "" {
+			tokenString = c.Cookies(auth.AccessTokenCookie)
+			fromCookie = true
+		} else {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Missing authorization header", fiber.StatusUnauthorized))
+		}
 
+		claims, err := auth.ParseToken(tokenString)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Invalid token", fiber.StatusUnauthorized))
 		}
+		claims.FromCookie = fromCookie
+
+		if claims.UserID == 0 || claims.MFAPending {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Invalid claims", fiber.StatusUnauthorized))
+		}
+
+		revoked, err := cfg.Revocations.IsRevoked(claims.Id, claims.SessionID, claims.UserID, time.Unix(claims.IssuedAt, 0))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to check token revocation")
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.CreateErrorResponse("Could not verify token", fiber.StatusInternalServerError))
+		}
+		if revoked {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Token has been revoked", fiber.StatusUnauthorized))
+		}
+
+		if claims.OrganizationID != 0 && cfg.Memberships != nil {
+			member, err := cfg.Memberships.IsMember(claims.OrganizationID, claims.UserID)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to check organization membership")
+				return c.Status(fiber.StatusInternalServerError).JSON(utils.CreateErrorResponse("Could not verify token", fiber.StatusInternalServerError))
+			}
+			if !member {
+				return c.Status(fiber.StatusForbidden).JSON(utils.CreateErrorResponse("No longer a member of the token's organization", fiber.StatusForbidden))
+			}
+		}
+
+		c.Locals("claims", claims)
+		if claims.Actor != nil {
+			return impersonated(c, cfg.Audit, claims)
+		}
+		return c.Next()
+	}
+}
+
+// impersonated handles a request made with a token an admin was issued to act
+// as the user. The token stops working once the admin is disabled or no
+// longer an admin. The X-Impersonated-By response header names the admin so
+// that clients can show a banner, deleting is refused and every request is
+// recorded in the audit trail with its final status. A request that cannot be
+// recorded fails with 500 Internal Server Error.
+func impersonated(c *fiber.Ctx, audit ImpersonationAuditor, claims *auth.Claims) error {
+	if audit == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Impersonation tokens are not accepted", fiber.StatusUnauthorized))
+	}
+
+	c.Set("X-Impersonated-By", claims.Actor.Subject)
+
+	var err error
+	if actorErr := audit.CheckActor(claims.Actor.UserID); actorErr != nil {
+		if !errors.Is(actorErr, errors.ErrActorNotAdmin) {
+			log.Error().Err(actorErr).Str("token_id", claims.Id).Msg("Failed to check impersonating admin")
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.CreateErrorResponse("Could not verify token", fiber.StatusInternalServerError))
+		}
+		err = c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Impersonation is no longer allowed", fiber.StatusUnauthorized))
+	} else if c.Method() == fiber.MethodDelete {
+		err = c.Status(fiber.StatusForbidden).JSON(utils.CreateErrorResponse("Not allowed while impersonating", fiber.StatusForbidden))
+	} else {
+		err = c.Next()
+	}
+
+	status := c.Response().StatusCode()
+	if e, ok := err.(*fiber.Error); ok {
+		status = e.Code
+	}
+	// Fiber's strings point into buffers reused by later requests
+	entry := &models.ImpersonationLog{
+		ActorID: claims.Actor.UserID,
+		UserID:  claims.UserID,
+		TokenID: claims.Id,
+		Method:  strings.Clone(c.Method()),
+		Path:    strings.Clone(c.Path()),
+		Status:  status,
+		IP:      strings.Clone(c.IP()),
+	}
+	if auditErr := audit.Record(entry); auditErr != nil {
+		log.Error().Err(auditErr).Str("token_id", claims.Id).Str("path", entry.Path).Msg("Failed to record impersonated request")
+		c.Response().ResetBody()
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.CreateErrorResponse("Could not record impersonated request", fiber.StatusInternalServerError))
+	}
 
-		if claims, ok := token.Claims.(*auth.Claims); ok && token.Valid {
-			c.Locals("claims", claims)
+	return err
+}
+
+// CSRF protects browser clients that authenticate with the token cookies
+// using the double-submit pattern: a state-changing request that carries the
+// cookies must repeat the value of the CSRF cookie in the X-CSRF-Token
+// header, which a cross-site form or script cannot read. Requests with an
+// Authorization header or API key do not authenticate by cookie and pass.
+func CSRF() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+			return c.Next()
+		}
+		if c.Get("Authorization") != "" || c.Get("X-API-Key") != "" {
 			return c.Next()
 		}
+		if c.Cookies(auth.AccessTokenCookie) == "" && c.Cookies(auth.RefreshTokenCookie) == "" {
+			return c.Next()
+		}
+
+		expected := c.Cookies(auth.CSRFCookie)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(c.Get(auth.CSRFHeader))) != 1 {
+			return c.Status(fiber.StatusForbidden).JSON(utils.CreateErrorResponse("Invalid CSRF token", fiber.StatusForbidden))
+		}
+		return c.Next()
+	}
+}
+
+// apiKeyFromRequest extracts an API key from the X-API-Key header or an
+// Authorization header using the ApiKey scheme. Bearer tokens are API keys
+// when they carry the key prefix, which a JWT never starts with.
+func apiKeyFromRequest(c *fiber.Ctx) (string, bool) {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key, true
+	}
 
-		return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Invalid claims", fiber.StatusUnauthorized))
+	scheme, key, found := strings.Cut(c.Get("Authorization"), " ")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return "", false
+	}
+	if strings.EqualFold(scheme, "ApiKey") {
+		return key, true
+	}
+	if strings.EqualFold(scheme, "Bearer") && strings.HasPrefix(key, services.APIKeyPrefix) {
+		return key, true
+	}
+	return "", false
+}
+
+func authenticateAPIKey(c *fiber.Ctx, apiKeys services.APIKeyService, key string) error {
+	if apiKeys == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("API keys are not accepted", fiber.StatusUnauthorized))
+	}
+
+	user, apiKey, err := apiKeys.AuthenticateAPIKey(key)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Invalid API key", fiber.StatusUnauthorized))
+		}
+		if errors.Is(err, errors.ErrAccountDisabled) {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Account disabled", fiber.StatusUnauthorized))
+		}
+		log.Error().Err(err).Msg("Failed to authenticate API key")
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.CreateErrorResponse("Could not verify API key", fiber.StatusInternalServerError))
+	}
+
+	c.Locals("claims", &auth.Claims{
+		StandardClaims: jwt.StandardClaims{Subject: user.Name},
+		UserID:         user.ID,
+		Roles:          user.RoleNames(),
+		Permissions:    apiKey.Permissions(user.PermissionNames()),
+		APIKeyID:       apiKey.ID,
+		ProjectIDs:     apiKey.ProjectIDs,
+	})
+	return c.Next()
+}
+
+// RequirePermission rejects requests whose token lacks any of the permissions.
+// It must run after JWTAuth.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := auth.ClaimsFromContext(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.CreateErrorResponse("Unauthorized", fiber.StatusUnauthorized))
+		}
+
+		for _, permission := range permissions {
+			if !claims.HasPermission(permission) {
+				return c.Status(fiber.StatusForbidden).JSON(utils.CreateErrorResponse("Missing permission "+permission, fiber.StatusForbidden))
+			}
+		}
+
+		return c.Next()
 	}
 }
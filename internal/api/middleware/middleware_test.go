@@ -0,0 +1,539 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/internal/api/auth"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRevocationService struct {
+	mock.Mock
+}
+
+func (m *MockRevocationService) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	args := m.Called(jti, userID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevocationService) RevokeAllForUser(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockRevocationService) RevokeSession(userID uint, sessionID string) error {
+	args := m.Called(userID, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockRevocationService) IsRevoked(jti string, sessionID string, userID uint, issuedAt time.Time) (bool, error) {
+	args := m.Called(jti, sessionID, userID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(userID uint, name string, scopes []string, projectIDs []uint, expiresAt *time.Time) (*models.APIKey, error) {
+	args := m.Called(userID, name, scopes, projectIDs, expiresAt)
+	key, _ := args.Get(0).(*models.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(userID, id uint) error {
+	args := m.Called(userID, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) AuthenticateAPIKey(key string) (*models.User, *models.APIKey, error) {
+	args := m.Called(key)
+	user, _ := args.Get(0).(*models.User)
+	apiKey, _ := args.Get(1).(*models.APIKey)
+	return user, apiKey, args.Error(2)
+}
+
+func newTestToken(t *testing.T, jti string, userID uint) string {
+	now := time.Now()
+	token, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Minute).Unix(),
+		},
+		UserID: userID,
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func newSessionTestToken(t *testing.T, jti, sessionID string, userID uint) string {
+	now := time.Now()
+	token, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(time.Minute).Unix(),
+		},
+		UserID:    userID,
+		SessionID: sessionID,
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func newMFAPendingToken(t *testing.T, userID uint) string {
+	token, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()},
+		UserID:         userID,
+		MFAPending:     true,
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWTAuth(t *testing.T) {
+	revocations := new(MockRevocationService)
+	revocations.On("IsRevoked", "active", "", uint(1), mock.Anything).Return(false, nil)
+	revocations.On("IsRevoked", "revoked", "", uint(1), mock.Anything).Return(true, nil)
+	revocations.On("IsRevoked", "in-revoked-session", "revoked-session", uint(1), mock.Anything).Return(true, nil)
+
+	app := fiber.New()
+	app.Get("/protected", JWTAuth(AuthConfig{Revocations: revocations}), func(c *fiber.Ctx) error {
+		claims, ok := auth.ClaimsFromContext(c)
+		assert.True(t, ok)
+		assert.Equal(t, uint(1), claims.UserID)
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	testCases := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid Token",
+			authorization:  "Bearer " + newTestToken(t, "active", 1),
+			expectedStatus: fiber.StatusOK,
+		},
+		{
+			name:           "Revoked Token",
+			authorization:  "Bearer " + newTestToken(t, "revoked", 1),
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Revoked Session",
+			authorization:  "Bearer " + newSessionTestToken(t, "in-revoked-session", "revoked-session", 1),
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "MFA Pending Token",
+			authorization:  "Bearer " + newMFAPendingToken(t, 1),
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Missing Header",
+			authorization:  "",
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Malformed Token",
+			authorization:  "Bearer not-a-jwt",
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Short Header",
+			authorization:  "Bear",
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Other Scheme",
+			authorization:  "Token " + newTestToken(t, "active", 1),
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/protected", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestJWTAuthWithCookie(t *testing.T) {
+	revocations := new(MockRevocationService)
+	revocations.On("IsRevoked", "active", "", uint(1), mock.Anything).Return(false, nil)
+
+	handler := func(c *fiber.Ctx) error {
+		claims, _ := auth.ClaimsFromContext(c)
+		if claims.FromCookie {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	app := fiber.New()
+	app.Get("/cookies", JWTAuth(AuthConfig{Revocations: revocations, Cookies: true}), handler)
+	app.Get("/headers", JWTAuth(AuthConfig{Revocations: revocations}), handler)
+
+	token := newTestToken(t, "active", 1)
+	send := func(path string, withHeader bool) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(&http.Cookie{Name: auth.AccessTokenCookie, Value: token})
+		if withHeader {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusNoContent, send("/cookies", false))
+	// The Authorization header takes precedence over the cookie
+	assert.Equal(t, fiber.StatusOK, send("/cookies", true))
+	assert.Equal(t, fiber.StatusUnauthorized, send("/headers", false))
+}
+
+func TestCSRF(t *testing.T) {
+	app := fiber.New()
+	app.Use(CSRF())
+	app.All("/todos", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	testCases := []struct {
+		name           string
+		method         string
+		cookies        map[string]string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{name: "Safe Method", method: "GET", cookies: map[string]string{auth.AccessTokenCookie: "t"}, expectedStatus: fiber.StatusOK},
+		{name: "No Cookies", method: "POST", expectedStatus: fiber.StatusOK},
+		{name: "Bearer Header", method: "POST", cookies: map[string]string{auth.AccessTokenCookie: "t"}, headers: map[string]string{"Authorization": "Bearer t"}, expectedStatus: fiber.StatusOK},
+		{name: "Matching Token", method: "POST", cookies: map[string]string{auth.AccessTokenCookie: "t", auth.CSRFCookie: "csrf"}, headers: map[string]string{auth.CSRFHeader: "csrf"}, expectedStatus: fiber.StatusOK},
+		{name: "Missing Header", method: "DELETE", cookies: map[string]string{auth.AccessTokenCookie: "t", auth.CSRFCookie: "csrf"}, expectedStatus: fiber.StatusForbidden},
+		{name: "Wrong Token", method: "PUT", cookies: map[string]string{auth.AccessTokenCookie: "t", auth.CSRFCookie: "csrf"}, headers: map[string]string{auth.CSRFHeader: "other"}, expectedStatus: fiber.StatusForbidden},
+		{name: "Refresh Cookie Only", method: "POST", cookies: map[string]string{auth.RefreshTokenCookie: "r"}, expectedStatus: fiber.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/todos", nil)
+			for name, value := range tc.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+type MockMembershipChecker struct {
+	mock.Mock
+}
+
+func (m *MockMembershipChecker) IsMember(organizationID, userID uint) (bool, error) {
+	args := m.Called(organizationID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestJWTAuthChecksOrganizationMembership(t *testing.T) {
+	revocations := new(MockRevocationService)
+	revocations.On("IsRevoked", mock.Anything, "", uint(1), mock.Anything).Return(false, nil)
+
+	memberships := new(MockMembershipChecker)
+	memberships.On("IsMember", uint(5), uint(1)).Return(true, nil)
+	memberships.On("IsMember", uint(6), uint(1)).Return(false, nil)
+
+	app := fiber.New()
+	app.Get("/protected", JWTAuth(AuthConfig{Revocations: revocations, Memberships: memberships}), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for organizationID, expectedStatus := range map[uint]int{0: fiber.StatusOK, 5: fiber.StatusOK, 6: fiber.StatusForbidden} {
+		now := time.Now()
+		token, err := auth.NewToken(auth.Claims{
+			StandardClaims: jwt.StandardClaims{Id: "active", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()},
+			UserID:         1,
+			OrganizationID: organizationID,
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStatus, resp.StatusCode, "organization %d", organizationID)
+	}
+
+	memberships.AssertNumberOfCalls(t, "IsMember", 2)
+}
+
+type MockImpersonationAuditor struct {
+	mock.Mock
+}
+
+func (m *MockImpersonationAuditor) CheckActor(actorID uint) error {
+	args := m.Called(actorID)
+	return args.Error(0)
+}
+
+func (m *MockImpersonationAuditor) Record(entry *models.ImpersonationLog) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func TestJWTAuthWithImpersonationToken(t *testing.T) {
+	revocations := new(MockRevocationService)
+	revocations.On("IsRevoked", "imp", "", uint(2), mock.Anything).Return(false, nil)
+
+	audit := new(MockImpersonationAuditor)
+	audit.On("CheckActor", uint(1)).Return(nil)
+	audit.On("Record", mock.Anything).Return(nil)
+
+	app := fiber.New()
+	handler := func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	app.Post("/todos", JWTAuth(AuthConfig{Revocations: revocations, Audit: audit}), handler)
+	app.Delete("/todos/1", JWTAuth(AuthConfig{Revocations: revocations, Audit: audit}), handler)
+	app.Get("/unaudited", JWTAuth(AuthConfig{Revocations: revocations}), handler)
+
+	now := time.Now()
+	token, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{Id: "imp", Subject: "bob", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()},
+		UserID:         2,
+		Actor:          &auth.Actor{Subject: "admin", UserID: 1},
+	})
+	require.NoError(t, err)
+
+	send := func(method, path string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := send("POST", "/todos")
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "admin", resp.Header.Get("X-Impersonated-By"))
+
+	resp = send("DELETE", "/todos/1")
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "admin", resp.Header.Get("X-Impersonated-By"))
+
+	// Without an audit trail impersonation tokens are not accepted at all
+	resp = send("GET", "/unaudited")
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	audit.AssertNumberOfCalls(t, "Record", 2)
+	entries := recordedEntries(audit)
+	for i, expected := range []struct {
+		method, path string
+		status       int
+	}{{"POST", "/todos", fiber.StatusNoContent}, {"DELETE", "/todos/1", fiber.StatusForbidden}} {
+		entry := entries[i]
+		assert.Equal(t, uint(1), entry.ActorID)
+		assert.Equal(t, uint(2), entry.UserID)
+		assert.Equal(t, "imp", entry.TokenID)
+		assert.Equal(t, expected.method, entry.Method)
+		assert.Equal(t, expected.path, entry.Path)
+		assert.Equal(t, expected.status, entry.Status)
+	}
+}
+
+func recordedEntries(audit *MockImpersonationAuditor) []*models.ImpersonationLog {
+	var entries []*models.ImpersonationLog
+	for _, call := range audit.Calls {
+		if call.Method == "Record" {
+			entries = append(entries, call.Arguments.Get(0).(*models.ImpersonationLog))
+		}
+	}
+	return entries
+}
+
+func TestJWTAuthImpersonationFailures(t *testing.T) {
+	now := time.Now()
+	token, err := auth.NewToken(auth.Claims{
+		StandardClaims: jwt.StandardClaims{Id: "imp", Subject: "bob", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()},
+		UserID:         2,
+		Actor:          &auth.Actor{Subject: "admin", UserID: 1},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		actorError     error
+		recordError    error
+		expectedStatus int
+		expectedCalled bool
+		expectedRecord bool
+	}{
+		{name: "Admin No Longer Allowed", actorError: errors.ErrActorNotAdmin, expectedStatus: fiber.StatusUnauthorized, expectedRecord: true},
+		{name: "Admin Lookup Fails", actorError: errors.ErrDatabaseOperation, expectedStatus: fiber.StatusInternalServerError},
+		{name: "Audit Trail Fails", recordError: errors.ErrDatabaseOperation, expectedStatus: fiber.StatusInternalServerError, expectedCalled: true, expectedRecord: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			revocations := new(MockRevocationService)
+			revocations.On("IsRevoked", "imp", "", uint(2), mock.Anything).Return(false, nil)
+
+			audit := new(MockImpersonationAuditor)
+			audit.On("CheckActor", uint(1)).Return(tc.actorError)
+			audit.On("Record", mock.Anything).Return(tc.recordError)
+
+			called := false
+			app := fiber.New()
+			app.Post("/todos", JWTAuth(AuthConfig{Revocations: revocations, Audit: audit}), func(c *fiber.Ctx) error {
+				called = true
+				return c.Status(fiber.StatusCreated).SendString("created")
+			})
+
+			req := httptest.NewRequest("POST", "/todos", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tc.expectedCalled, called)
+			entries := recordedEntries(audit)
+			if tc.expectedRecord {
+				require.Len(t, entries, 1)
+			} else {
+				assert.Empty(t, entries)
+			}
+		})
+	}
+}
+
+func TestJWTAuthWithAPIKey(t *testing.T) {
+	user := &models.User{
+		ID:   1,
+		Name: "robot",
+		Roles: []models.Role{{
+			Name:        models.RoleUser,
+			Permissions: []models.Permission{{Name: models.PermTodosRead}, {Name: models.PermTodosWrite}},
+		}},
+	}
+
+	apiKeys := new(MockAPIKeyService)
+	apiKeys.On("AuthenticateAPIKey", "gfb_valid").Return(user, &models.APIKey{ID: 7, Scopes: []string{models.PermTodosRead}, ProjectIDs: []uint{3}}, nil)
+	apiKeys.On("AuthenticateAPIKey", "gfb_revoked").Return(nil, nil, errors.ErrInvalidToken)
+
+	app := fiber.New()
+	app.Get("/protected", JWTAuth(AuthConfig{Revocations: new(MockRevocationService), APIKeys: apiKeys}), func(c *fiber.Ctx) error {
+		claims, ok := auth.ClaimsFromContext(c)
+		assert.True(t, ok)
+		assert.Equal(t, uint(1), claims.UserID)
+		assert.Equal(t, uint(7), claims.APIKeyID)
+		assert.Equal(t, []string{models.PermTodosRead}, claims.Permissions)
+		assert.Equal(t, []uint{3}, claims.ProjectIDs)
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	testCases := []struct {
+		name           string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{name: "Authorization Header", header: "Authorization", value: "ApiKey gfb_valid", expectedStatus: fiber.StatusOK},
+		{name: "X-API-Key Header", header: "X-API-Key", value: "gfb_valid", expectedStatus: fiber.StatusOK},
+		{name: "Bearer Token", header: "Authorization", value: "Bearer gfb_valid", expectedStatus: fiber.StatusOK},
+		{name: "Revoked Key", header: "X-API-Key", value: "gfb_revoked", expectedStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/protected", nil)
+			req.Header.Set(tc.header, tc.value)
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("claims", &auth.Claims{UserID: 1, Permissions: []string{"todos:read"}})
+		return c.Next()
+	})
+	app.Get("/read", RequirePermission("todos:read"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/write", RequirePermission("todos:read", "todos:write"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/read", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/write", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
+
+func TestRequirePermissionWithoutClaims(t *testing.T) {
+	app := fiber.New()
+	app.Get("/read", RequirePermission("todos:read"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/read", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestMain(m *testing.M) {
+	// Keep generated signing keys out of the source tree
+	keysDir, err := os.MkdirTemp("", "auth_keys")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create keys directory")
+	}
+	os.Setenv("AUTH_KEYS_DIR", keysDir)
+
+	// Initialize auth package
+	if err := auth.InitAuth(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize auth")
+	}
+
+	// Run the tests
+	code := m.Run()
+	os.RemoveAll(keysDir)
+	os.Exit(code)
+}
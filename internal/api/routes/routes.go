@@ -1,39 +1,245 @@
 package routes
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/netf/gofiber-boilerplate/config"
 	"github.com/netf/gofiber-boilerplate/internal/api/handlers"
 	"github.com/netf/gofiber-boilerplate/internal/api/middleware"
+	"github.com/netf/gofiber-boilerplate/internal/mailer"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/oidc"
+	"github.com/netf/gofiber-boilerplate/internal/password"
 	"github.com/netf/gofiber-boilerplate/internal/repositories"
 	"github.com/netf/gofiber-boilerplate/internal/services"
 	"gorm.io/gorm"
 )
 
-func RegisterRoutes(router fiber.Router, db *gorm.DB, cfg *config.Config) {
-	authMiddleware := middleware.JWTAuth()
+func RegisterRoutes(router fiber.Router, db *gorm.DB, cfg *config.Config) error {
+	authRepo := repositories.NewAuthRepository(db)
+
+	revocationRepo := repositories.NewRevocationRepository(db)
+	sessionRepo := repositories.NewSessionRepository(db)
+	revocationService := services.NewRevocationService(revocationRepo, sessionRepo, cfg.RevocationSyncInterval)
+
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	projectRepo := repositories.NewProjectRepository(db)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, *authRepo, projectRepo)
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	organizationRepo := repositories.NewOrganizationRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+	organizationService := services.NewOrganizationService(organizationRepo, invitationRepo, sessionRepo, *authRepo, mail, cfg.AppURL, cfg.OrganizationInviteTTL)
+
+	impersonationLogRepo := repositories.NewImpersonationLogRepository(db)
+	impersonationService := services.NewImpersonationService(*authRepo, impersonationLogRepo)
+
+	authMiddleware := middleware.JWTAuth(middleware.AuthConfig{
+		Revocations: revocationService,
+		APIKeys:     apiKeyService,
+		Memberships: organizationService,
+		Audit:       impersonationService,
+		Cookies:     cfg.AuthCookies,
+	})
+
+	if cfg.AuthCookies {
+		router.Use(middleware.CSRF())
+	}
 
 	// Todo routes
 	todoRepo := repositories.NewTodoRepository(db)
-	todoService := services.NewTodoService(todoRepo)
+	labelRepo := repositories.NewLabelRepository(db)
+	todoService := services.NewTodoService(todoRepo, labelRepo, projectRepo, cfg.TodoMaxDepth)
 	todoHandler := handlers.NewTodoHandler(todoService)
 
+	canReadTodos := middleware.RequirePermission(models.PermTodosRead)
+	canWriteTodos := middleware.RequirePermission(models.PermTodosWrite)
+
 	todoRoutes := router.Group("/todos", authMiddleware)
-	todoRoutes.Post("/", todoHandler.CreateTodo)
-	todoRoutes.Get("/", todoHandler.ListTodos)
-	todoRoutes.Get("/:id", todoHandler.GetTodoByID)
-	todoRoutes.Put("/:id", todoHandler.UpdateTodo)
-	todoRoutes.Delete("/:id", todoHandler.DeleteTodo)
+	todoRoutes.Post("/", canWriteTodos, todoHandler.CreateTodo)
+	todoRoutes.Get("/", canReadTodos, todoHandler.ListTodos)
+	todoRoutes.Get("/:id", canReadTodos, todoHandler.GetTodoByID)
+	todoRoutes.Put("/:id", canWriteTodos, todoHandler.UpdateTodo)
+	todoRoutes.Delete("/:id", canWriteTodos, todoHandler.DeleteTodo)
+	todoRoutes.Put("/:id/project", canWriteTodos, todoHandler.MoveTodo)
+	todoRoutes.Get("/:id/subtree", canReadTodos, todoHandler.GetTodoSubtree)
+	todoRoutes.Put("/:id/parent", canWriteTodos, todoHandler.SetTodoParent)
+	todoRoutes.Get("/:id/occurrences", canReadTodos, todoHandler.ListOccurrences)
+	todoRoutes.Post("/:id/skip", canWriteTodos, todoHandler.SkipOccurrence)
+
+	// Project routes
+	projectHandler := handlers.NewProjectHandler(services.NewProjectService(projectRepo))
+
+	projectRoutes := router.Group("/projects", authMiddleware)
+	projectRoutes.Post("/", canWriteTodos, projectHandler.CreateProject)
+	projectRoutes.Get("/", canReadTodos, projectHandler.ListProjects)
+	projectRoutes.Get("/:id", canReadTodos, projectHandler.GetProject)
+	projectRoutes.Put("/:id", canWriteTodos, projectHandler.UpdateProject)
+	projectRoutes.Delete("/:id", canWriteTodos, projectHandler.DeleteProject)
+	projectRoutes.Post("/:id/todos", canWriteTodos, todoHandler.CreateProjectTodo)
+	projectRoutes.Get("/:id/todos", canReadTodos, todoHandler.ListProjectTodos)
+
+	// Label routes
+	labelHandler := handlers.NewLabelHandler(services.NewLabelService(labelRepo))
+
+	labelRoutes := router.Group("/labels", authMiddleware)
+	labelRoutes.Post("/", canWriteTodos, labelHandler.CreateLabel)
+	labelRoutes.Get("/", canReadTodos, labelHandler.ListLabels)
+	labelRoutes.Put("/:id", canWriteTodos, labelHandler.UpdateLabel)
+	labelRoutes.Delete("/:id", canWriteTodos, labelHandler.DeleteLabel)
 
 	// Auth routes
-	authRepo := repositories.NewAuthRepository(db)
-	authService := services.NewAuthService(*authRepo)
-	authHandler := handlers.NewAuthHandler(authService)
+	hasher, err := password.NewHasher(cfg.PasswordHashAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	policy := password.Policy{
+		MinLength:        cfg.PasswordMinLength,
+		RequireUpper:     cfg.PasswordRequireUpper,
+		RequireLower:     cfg.PasswordRequireLower,
+		RequireDigit:     cfg.PasswordRequireDigit,
+		RequireSymbol:    cfg.PasswordRequireSymbol,
+		DisallowUserInfo: cfg.PasswordRejectUserInfo,
+	}
+	if cfg.BreachedPasswordsFile != "" {
+		breached, err := password.LoadBreachedFile(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return err
+		}
+		policy.Breached = breached
+	}
+
+	passwordHistoryRepo := repositories.NewPasswordHistoryRepository(db)
+	passwordPolicyService := services.NewPasswordPolicyService(policy, passwordHistoryRepo, hasher, cfg.PasswordHistorySize)
+
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	roleRepo := repositories.NewRoleRepository(db)
+	userTokenRepo := repositories.NewUserTokenRepository(db)
+	authService := services.NewAuthService(*authRepo, refreshTokenRepo, sessionRepo, revocationService, roleRepo, userTokenRepo, hasher, passwordPolicyService, mail, cfg)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(db)
+	mfaService := services.NewMFAService(*authRepo, recoveryCodeRepo, cfg.MFAIssuer)
+	loginThrottleRepo := repositories.NewLoginThrottleRepository(db)
+	loginThrottleService := services.NewLoginThrottleService(loginThrottleRepo, *authRepo, cfg)
+	authHandler := handlers.NewAuthHandler(authService, mfaService, loginThrottleService, revocationService, cfg)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	sessionService := services.NewSessionService(sessionRepo, refreshTokenRepo, revocationService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
 
 	authRoutes := router.Group("/auth")
 	authRoutes.Post("/register", authHandler.Register)
 	authRoutes.Post("/login", authHandler.Login)
 	authRoutes.Post("/logout", authMiddleware, authHandler.Logout)
+	authRoutes.Post("/logout/all", authMiddleware, authHandler.LogoutAll)
 	authRoutes.Post("/refresh", authHandler.RefreshToken)
+	authRoutes.Post("/verify", authHandler.VerifyEmail)
+	authRoutes.Post("/verify/resend", authHandler.ResendVerification)
+	authRoutes.Post("/forgot", authHandler.ForgotPassword)
+	authRoutes.Post("/reset", authHandler.ResetPassword)
+	authRoutes.Get("/sessions", authMiddleware, sessionHandler.ListSessions)
+	authRoutes.Delete("/sessions/:id", authMiddleware, sessionHandler.RevokeSession)
+	authRoutes.Post("/mfa/verify", authHandler.VerifyMFA)
+	authRoutes.Post("/mfa/enroll", authMiddleware, mfaHandler.Enroll)
+	authRoutes.Post("/mfa/confirm", authMiddleware, mfaHandler.Confirm)
+	authRoutes.Post("/mfa/disable", authMiddleware, mfaHandler.Disable)
+	authRoutes.Post("/mfa/recovery-codes", authMiddleware, mfaHandler.RegenerateRecoveryCodes)
+
+	// External login routes
+	providers, err := oidc.FromConfig(cfg, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	oauthStateRepo := repositories.NewOAuthStateRepository(db)
+	identityRepo := repositories.NewIdentityRepository(db)
+	oidcService := services.NewOIDCService(providers, oauthStateRepo, identityRepo, *authRepo, roleRepo, hasher, cfg.OIDCStateTTL)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, authHandler)
+
+	authRoutes.Get("/oidc/providers", oidcHandler.ListProviders)
+	authRoutes.Get("/oidc/:provider/login", oidcHandler.Login)
+	authRoutes.Get("/oidc/:provider/callback", oidcHandler.Callback)
+
+	// Profile routes. The middleware is applied per route because confirming an
+	// email change works without being logged in.
+	profileHandler := handlers.NewProfileHandler(authService)
+
+	router.Get("/me", authMiddleware, profileHandler.GetMe)
+	router.Patch("/me", authMiddleware, profileHandler.UpdateMe)
+	router.Post("/me/password", authMiddleware, profileHandler.ChangePassword)
+	router.Post("/me/email", authMiddleware, profileHandler.RequestEmailChange)
+	router.Post("/me/email/confirm", profileHandler.ConfirmEmailChange)
+	router.Get("/me/identities", authMiddleware, oidcHandler.ListIdentities)
+	router.Delete("/me/identities/:id", authMiddleware, oidcHandler.UnlinkIdentity)
+
+	// Organization routes
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, authHandler)
+
+	authRoutes.Post("/organization", authMiddleware, organizationHandler.SwitchOrganization)
+
+	organizationRoutes := router.Group("/organizations", authMiddleware)
+	organizationRoutes.Post("/", organizationHandler.CreateOrganization)
+	organizationRoutes.Get("/", organizationHandler.ListOrganizations)
+	organizationRoutes.Get("/:id", organizationHandler.GetOrganization)
+	organizationRoutes.Delete("/:id", organizationHandler.DeleteOrganization)
+	organizationRoutes.Get("/:id/members", organizationHandler.ListMembers)
+	organizationRoutes.Put("/:id/members/:userId", organizationHandler.SetMemberRole)
+	organizationRoutes.Delete("/:id/members/:userId", organizationHandler.RemoveMember)
+	organizationRoutes.Post("/:id/invitations", organizationHandler.Invite)
+	organizationRoutes.Get("/:id/invitations", organizationHandler.ListInvitations)
+	organizationRoutes.Delete("/:id/invitations/:invitationId", organizationHandler.RevokeInvitation)
+
+	router.Post("/invitations/accept", authMiddleware, organizationHandler.AcceptInvitation)
+	router.Post("/invitations/decline", authMiddleware, organizationHandler.DeclineInvitation)
+
+	// API key routes
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+
+	apiKeyRoutes := router.Group("/api-keys", authMiddleware)
+	apiKeyRoutes.Post("/", apiKeyHandler.CreateAPIKey)
+	apiKeyRoutes.Get("/", apiKeyHandler.ListAPIKeys)
+	apiKeyRoutes.Delete("/:id", apiKeyHandler.RevokeAPIKey)
+
+	// OAuth authorization server routes
+	oauthClientRepo := repositories.NewOAuthClientRepository(db)
+	oauthCodeRepo := repositories.NewOAuthCodeRepository(db)
+	oauthService := services.NewOAuthService(oauthClientRepo, oauthCodeRepo, *authRepo, cfg.OAuthCodeTTL)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, cfg)
+
+	oauthRoutes := router.Group("/oauth")
+	oauthRoutes.Get("/authorize", authMiddleware, oauthHandler.Authorize)
+	oauthRoutes.Post("/authorize", authMiddleware, oauthHandler.Decide)
+	oauthRoutes.Post("/token", oauthHandler.Token)
+
+	// Admin routes
+	userAdminService := services.NewUserAdminService(*authRepo, roleRepo, authService)
+	adminHandler := handlers.NewAdminHandler(userAdminService, loginThrottleService)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService, cfg)
+
+	adminRoutes := router.Group("/admin", authMiddleware, middleware.RequirePermission(models.PermUsersAdmin))
+	adminRoutes.Get("/users", adminHandler.ListUsers)
+	adminRoutes.Get("/users/:id", adminHandler.GetUser)
+	adminRoutes.Post("/users/:id/disable", adminHandler.DisableUser)
+	adminRoutes.Post("/users/:id/enable", adminHandler.EnableUser)
+	adminRoutes.Post("/users/:id/password-reset", adminHandler.ForcePasswordReset)
+	adminRoutes.Put("/users/:id/roles", adminHandler.SetRoles)
+	adminRoutes.Delete("/users/:id", adminHandler.DeleteUser)
+	adminRoutes.Post("/users/:id/unlock", adminHandler.UnlockUser)
+	adminRoutes.Post("/users/:id/impersonate", impersonationHandler.Impersonate)
+	adminRoutes.Get("/impersonations", impersonationHandler.ListLogs)
+	adminRoutes.Post("/oauth/clients", oauthHandler.CreateClient)
+	adminRoutes.Get("/oauth/clients", oauthHandler.ListClients)
+	adminRoutes.Delete("/oauth/clients/:id", oauthHandler.RevokeClient)
+
+	return nil
+}
 
+// RegisterWellKnownRoutes registers the unversioned discovery endpoints at the application root
+func RegisterWellKnownRoutes(router fiber.Router) {
+	router.Get("/.well-known/jwks.json", handlers.JWKS)
 }
@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netf/gofiber-boilerplate/config"
+	"github.com/netf/gofiber-boilerplate/internal/db/dbtest"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/mailer"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/netf/gofiber-boilerplate/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		AppURL:                 "http://localhost:8080",
+		MailerDriver:           mailer.DriverMemory,
+		MailFrom:               "noreply@localhost",
+		PasswordMinLength:      8,
+		AccessTokenTTL:         15 * time.Minute,
+		RefreshTokenTTL:        time.Hour,
+		RevocationSyncInterval: 30 * time.Second,
+		MFATokenTTL:            5 * time.Minute,
+		LoginAccountMaxFails:   5,
+		LoginIPMaxFails:        20,
+		LoginFailureWindow:     15 * time.Minute,
+		LoginLockoutBase:       time.Minute,
+		LoginLockoutMax:        time.Hour,
+		OIDCStateTTL:           10 * time.Minute,
+		OAuthCodeTTL:           time.Minute,
+		OrganizationInviteTTL:  time.Hour,
+		ImpersonationTTL:       15 * time.Minute,
+		TodoMaxDepth:           5,
+	}
+}
+
+// fixture is a user with the default role, two projects with a todo each
+// and a todo without a project
+type fixture struct {
+	db       *gorm.DB
+	app      *fiber.App
+	user     models.User
+	projects [2]models.Project
+	todos    [3]models.Todo
+}
+
+func newFixture(t *testing.T) *fixture {
+	database := dbtest.Open(t)
+	f := &fixture{db: database, app: fiber.New()}
+	require.NoError(t, RegisterRoutes(f.app, database, testConfig()))
+
+	var role models.Role
+	require.NoError(t, database.Where("name = ?", models.RoleUser).First(&role).Error)
+	f.user = models.User{Name: "robot", Email: "robot@example.com", Pass: []byte("x"), Roles: []models.Role{role}}
+	require.NoError(t, database.Omit("Roles.*").Create(&f.user).Error)
+
+	for i, name := range []string{"Allowed", "Other"} {
+		f.projects[i] = models.Project{UserID: f.user.ID, Name: name}
+		require.NoError(t, database.Create(&f.projects[i]).Error)
+	}
+	for i := range f.todos {
+		f.todos[i] = models.Todo{UserID: f.user.ID, Title: fmt.Sprintf("Todo %d", i), Status: models.TodoStatusTodo}
+		if i < len(f.projects) {
+			f.todos[i].ProjectID = &f.projects[i].ID
+		}
+		require.NoError(t, database.Create(&f.todos[i]).Error)
+	}
+	return f
+}
+
+func (f *fixture) apiKey(t *testing.T, scopes []string, projectIDs []uint) string {
+	service := services.NewAPIKeyService(repositories.NewAPIKeyRepository(f.db), *repositories.NewAuthRepository(f.db), repositories.NewProjectRepository(f.db))
+	apiKey, err := service.CreateAPIKey(f.user.ID, "test", scopes, projectIDs, nil)
+	require.NoError(t, err)
+	return apiKey.Key
+}
+
+func (f *fixture) request(t *testing.T, key, method, path string, body interface{}) (int, []byte) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := f.app.Test(req, -1)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+func TestAPIKeyScopesEnforced(t *testing.T) {
+	f := newFixture(t)
+	key := f.apiKey(t, []string{models.PermTodosRead}, nil)
+	todo := fmt.Sprintf("/todos/%d", f.todos[0].ID)
+
+	testCases := []struct {
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+	}{
+		{method: "GET", path: "/todos", expectedStatus: fiber.StatusOK},
+		{method: "GET", path: todo, expectedStatus: fiber.StatusOK},
+		{method: "POST", path: "/todos", body: map[string]string{"title": "New todo"}, expectedStatus: fiber.StatusForbidden},
+		{method: "PUT", path: todo + "/project", body: map[string]interface{}{"project_id": nil}, expectedStatus: fiber.StatusForbidden},
+		{method: "DELETE", path: todo, expectedStatus: fiber.StatusForbidden},
+		{method: "POST", path: "/projects", body: map[string]string{"name": "New project"}, expectedStatus: fiber.StatusForbidden},
+		{method: "GET", path: "/admin/users", expectedStatus: fiber.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		status, body := f.request(t, key, tc.method, tc.path, tc.body)
+		assert.Equal(t, tc.expectedStatus, status, "%s %s: %s", tc.method, tc.path, body)
+	}
+}
+
+func TestAPIKeyProjectsEnforced(t *testing.T) {
+	f := newFixture(t)
+	key := f.apiKey(t, nil, []uint{f.projects[0].ID})
+	allowed, other := f.projects[0].ID, f.projects[1].ID
+	allowedTodo, otherTodo, looseTodo := f.todos[0].ID, f.todos[1].ID, f.todos[2].ID
+
+	status, body := f.request(t, key, "GET", "/todos", nil)
+	require.Equal(t, fiber.StatusOK, status, string(body))
+	var list struct {
+		Data []models.Todo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, allowedTodo, list.Data[0].ID)
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+	}{
+		{name: "Get Allowed Todo", method: "GET", path: fmt.Sprintf("/todos/%d", allowedTodo), expectedStatus: fiber.StatusOK},
+		{name: "Get Todo Of Other Project", method: "GET", path: fmt.Sprintf("/todos/%d", otherTodo), expectedStatus: fiber.StatusNotFound},
+		{name: "Get Todo Without Project", method: "GET", path: fmt.Sprintf("/todos/%d", looseTodo), expectedStatus: fiber.StatusNotFound},
+		{name: "Delete Todo Of Other Project", method: "DELETE", path: fmt.Sprintf("/todos/%d", otherTodo), expectedStatus: fiber.StatusNotFound},
+		{name: "Create Todo Without Project", method: "POST", path: "/todos", body: map[string]string{"title": "New todo"}, expectedStatus: fiber.StatusForbidden},
+		{name: "Create Todo In Other Project", method: "POST", path: "/todos", body: map[string]interface{}{"title": "New todo", "project_id": other}, expectedStatus: fiber.StatusBadRequest},
+		{name: "List Allowed Project", method: "GET", path: fmt.Sprintf("/projects/%d/todos", allowed), expectedStatus: fiber.StatusOK},
+		{name: "List Other Project", method: "GET", path: fmt.Sprintf("/projects/%d/todos", other), expectedStatus: fiber.StatusNotFound},
+		{name: "Create In Allowed Project", method: "POST", path: fmt.Sprintf("/projects/%d/todos", allowed), body: map[string]string{"title": "New todo"}, expectedStatus: fiber.StatusCreated},
+		{name: "Create In Other Project", method: "POST", path: fmt.Sprintf("/projects/%d/todos", other), body: map[string]string{"title": "New todo"}, expectedStatus: fiber.StatusNotFound},
+		{name: "Move Into Other Project", method: "PUT", path: fmt.Sprintf("/todos/%d/project", allowedTodo), body: map[string]interface{}{"project_id": other}, expectedStatus: fiber.StatusBadRequest},
+		{name: "Move Out Of Project", method: "PUT", path: fmt.Sprintf("/todos/%d/project", allowedTodo), body: map[string]interface{}{"project_id": nil}, expectedStatus: fiber.StatusForbidden},
+		{name: "Move Todo Of Other Project", method: "PUT", path: fmt.Sprintf("/todos/%d/project", otherTodo), body: map[string]interface{}{"project_id": allowed}, expectedStatus: fiber.StatusNotFound},
+		{name: "Get Other Project", method: "GET", path: fmt.Sprintf("/projects/%d", other), expectedStatus: fiber.StatusNotFound},
+		{name: "Create Project", method: "POST", path: "/projects", body: map[string]string{"name": "New project"}, expectedStatus: fiber.StatusForbidden},
+		{name: "Create Label", method: "POST", path: "/labels", body: map[string]string{"name": "urgent"}, expectedStatus: fiber.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := f.request(t, key, tc.method, tc.path, tc.body)
+			assert.Equal(t, tc.expectedStatus, status, string(body))
+		})
+	}
+
+	var moved models.Todo
+	require.NoError(t, f.db.First(&moved, allowedTodo).Error)
+	assert.Equal(t, &allowed, moved.ProjectID)
+	require.NoError(t, f.db.First(&moved, otherTodo).Error)
+	assert.Equal(t, &other, moved.ProjectID)
+}
+
+func TestCreateAPIKeyRejectsUnknownProject(t *testing.T) {
+	f := newFixture(t)
+	service := services.NewAPIKeyService(repositories.NewAPIKeyRepository(f.db), *repositories.NewAuthRepository(f.db), repositories.NewProjectRepository(f.db))
+
+	_, err := service.CreateAPIKey(f.user.ID, "test", nil, []uint{f.projects[1].ID + 1000}, nil)
+	assert.True(t, errors.Is(err, errors.ErrInvalidInput), "%v", err)
+}
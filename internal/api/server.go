@@ -33,6 +33,14 @@ func Start(cfg *config.Config, database *gorm.DB) {
 		log.Fatal().Err(err).Msg("Could not run auto-migrations")
 	}
 
+	if err := db.Seed(database); err != nil {
+		log.Fatal().Err(err).Msg("Could not seed roles and permissions")
+	}
+
+	if err := db.MigrateData(database); err != nil {
+		log.Fatal().Err(err).Msg("Could not run data migrations")
+	}
+
 	app := fiber.New(fiber.Config{
 		ErrorHandler: middleware.ErrorHandler,
 	})
@@ -43,10 +51,14 @@ func Start(cfg *config.Config, database *gorm.DB) {
 		log.Fatal().Err(err).Msg("Failed to initialize auth configuration")
 	}
 
+	routes.RegisterWellKnownRoutes(app)
+
 	api := app.Group("/api")
 	v1 := api.Group("/v1")
 
-	routes.RegisterRoutes(v1, database, cfg)
+	if err := routes.RegisterRoutes(v1, database, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Failed to register routes")
+	}
 
 	app.Get("/swagger/*", swagger.New(swagger.Config{
 		URL:         "/swagger/doc.json",
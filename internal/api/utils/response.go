@@ -43,10 +43,12 @@ func CreateResponse[T any](data interface{}, opts ...int) Response[T] {
 	return response
 }
 
-// ErrorResponse represents a standardized error response structure
+// ErrorResponse represents a standardized error response structure. Fields
+// optionally lists the problems found with individual request fields.
 type ErrorResponse struct {
-	Error string `json:"error"`
-	Code  int    `json:"code"`
+	Error  string              `json:"error"`
+	Code   int                 `json:"code"`
+	Fields map[string][]string `json:"fields,omitempty"`
 }
 
 // CreateErrorResponse generates a standardized error response
@@ -56,3 +58,13 @@ func CreateErrorResponse(message string, statusCode int) ErrorResponse {
 		Code:  statusCode,
 	}
 }
+
+// CreateFieldErrorResponse generates an error response listing the problems
+// found with individual request fields
+func CreateFieldErrorResponse(message string, statusCode int, fields map[string][]string) ErrorResponse {
+	return ErrorResponse{
+		Error:  message,
+		Code:   statusCode,
+		Fields: fields,
+	}
+}
@@ -1,14 +1,18 @@
 package db
 
 import (
+	"time"
+
 	"github.com/netf/gofiber-boilerplate/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func NewDatabase(databaseURL string) (*gorm.DB, error) {
-	// Initialize GORM
-	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{})
+	// Initialize GORM. Translated errors let repositories recognise
+	// violations of unique indexes as gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -18,3 +22,133 @@ func NewDatabase(databaseURL string) (*gorm.DB, error) {
 func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(models.ModelsToMigrate...)
 }
+
+// Seed creates the default permissions and roles if they are missing
+func Seed(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range models.DefaultPermissions {
+			permission := p
+			if err := tx.Where(models.Permission{Name: permission.Name}).FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+		}
+
+		for name, permissionNames := range models.DefaultRoles {
+			role := models.Role{Name: name}
+			if err := tx.Where(models.Role{Name: name}).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+
+			var permissions []models.Permission
+			if err := tx.Where("name IN ?", permissionNames).Find(&permissions).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&role).Association("Permissions").Append(permissions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dataMigration is a one-off change to existing rows
+type dataMigration struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+// dataMigrations are applied in order by MigrateData. Append new ones; never
+// rename or remove an entry that may have been applied.
+var dataMigrations = []dataMigration{
+	{name: "grant_default_role", run: grantDefaultRole},
+	{name: "todo_status_from_completed", run: todoStatusFromCompleted},
+	{name: "unique_label_names", run: uniqueLabelNames},
+	{name: "unique_active_users", run: uniqueActiveUsers},
+}
+
+// MigrateData applies the data migrations that have not been applied yet,
+// each in its own transaction. It runs after Seed, so the default roles
+// exist.
+func MigrateData(db *gorm.DB) error {
+	for _, migration := range dataMigrations {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Recording the migration first makes a concurrent startup wait
+			// for this one and then skip it
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.DataMigration{Name: migration.name, AppliedAt: time.Now()})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return migration.run(tx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grantDefaultRole gives the default user role to the users created before
+// there were roles
+func grantDefaultRole(tx *gorm.DB) error {
+	return tx.Exec(`INSERT INTO user_roles (user_id, role_id)
+		SELECT users.id, roles.id FROM users, roles
+		WHERE roles.name = ? AND NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id)`,
+		models.RoleUser).Error
+}
+
+// todoStatusFromCompleted marks the todos completed before todos had a status
+// as done
+func todoStatusFromCompleted(tx *gorm.DB) error {
+	return tx.Model(&models.Todo{}).
+		Where("completed AND status = ?", models.TodoStatusTodo).
+		UpdateColumn("status", models.TodoStatusDone).Error
+}
+
+// labelDuplicates selects the labels that have the name of an older label in
+// the same workspace, compared case-insensitively, together with the ID of
+// the oldest one
+const labelDuplicates = `SELECT id, keep FROM (
+	SELECT id, MIN(id) OVER (PARTITION BY organization_id, CASE WHEN organization_id IS NULL THEN user_id END, LOWER(name)) AS keep
+	FROM labels
+) AS labels WHERE id <> keep`
+
+// uniqueLabelNames merges labels whose names differ only in case into the
+// oldest of them and adds unique indexes that keep names unique per
+// workspace from then on
+func uniqueLabelNames(tx *gorm.DB) error {
+	statements := []string{
+		`INSERT INTO todo_labels (todo_id, label_id)
+			SELECT todo_labels.todo_id, duplicates.keep FROM todo_labels
+			JOIN (` + labelDuplicates + `) AS duplicates ON duplicates.id = todo_labels.label_id
+			ON CONFLICT DO NOTHING`,
+		`DELETE FROM todo_labels WHERE label_id IN (SELECT id FROM (` + labelDuplicates + `) AS duplicates)`,
+		`DELETE FROM labels WHERE id IN (SELECT id FROM (` + labelDuplicates + `) AS duplicates)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_personal_name ON labels (user_id, LOWER(name)) WHERE organization_id IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_organization_name ON labels (organization_id, LOWER(name)) WHERE organization_id IS NOT NULL`,
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueActiveUsers replaces the unique indexes on the names and email
+// addresses of users with ones that leave deleted users out, so that their
+// names and addresses can be registered again
+func uniqueActiveUsers(tx *gorm.DB) error {
+	statements := []string{
+		`DROP INDEX IF EXISTS idx_users_name`,
+		`DROP INDEX IF EXISTS idx_users_email`,
+		`CREATE UNIQUE INDEX idx_users_name ON users (name) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX idx_users_email ON users (email) WHERE deleted_at IS NULL`,
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
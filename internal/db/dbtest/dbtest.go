@@ -0,0 +1,62 @@
+// Package dbtest connects tests to the PostgreSQL database named by
+// TEST_DATABASE_URL. Tests using it are skipped when the variable is unset.
+package dbtest
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/netf/gofiber-boilerplate/internal/db"
+	"gorm.io/gorm"
+)
+
+// migrationLock serializes migrating the schema across test binaries
+const migrationLock = 7461626
+
+var (
+	once     sync.Once
+	database *gorm.DB
+	setupErr error
+)
+
+// Open returns a transaction on the migrated and seeded test database that
+// is rolled back when the test ends, so tests see neither each other's rows
+// nor leave any behind
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	once.Do(func() {
+		database, setupErr = db.NewDatabase(url)
+		if setupErr != nil {
+			return
+		}
+		setupErr = database.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error; err != nil {
+				return err
+			}
+			if err := db.AutoMigrate(tx); err != nil {
+				return err
+			}
+			if err := db.Seed(tx); err != nil {
+				return err
+			}
+			return db.MigrateData(tx)
+		})
+	})
+	if setupErr != nil {
+		t.Fatalf("setting up test database: %v", setupErr)
+	}
+
+	tx := database.Begin()
+	if tx.Error != nil {
+		t.Fatalf("beginning transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
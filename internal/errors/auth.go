@@ -1,12 +1,50 @@
 package errors
 
+import (
+	"time"
+)
+
 // Custom error types
 var (
-	ErrUserAlreadyExists  = New("user already exists")
-	ErrInvalidCredentials = New("invalid credentials")
-	ErrResourceNotFound   = New("resource not found")
-	ErrInvalidInput       = New("invalid input")
-	ErrUnauthorized       = New("unauthorized")
-	ErrInternalServer     = New("internal server error")
-	ErrUserNotFound       = New("user not found")
+	ErrUserAlreadyExists     = New("user already exists")
+	ErrInvalidCredentials    = New("invalid credentials")
+	ErrResourceNotFound      = New("resource not found")
+	ErrInvalidInput          = New("invalid input")
+	ErrUnauthorized          = New("unauthorized")
+	ErrInternalServer        = New("internal server error")
+	ErrUserNotFound          = New("user not found")
+	ErrInvalidToken          = New("invalid or expired token")
+	ErrTokenReused           = New("refresh token reuse detected")
+	ErrMFARequired           = New("second factor required")
+	ErrInvalidMFACode        = New("invalid two-factor code")
+	ErrMFANotEnabled         = New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled     = New("two-factor authentication is already enabled")
+	ErrEmailNotVerified      = New("email address not verified")
+	ErrAccountLocked         = New("account temporarily locked")
+	ErrTooManyAttempts       = New("too many failed login attempts")
+	ErrAccountDisabled       = New("account disabled")
+	ErrPasswordResetRequired = New("password reset required")
+	ErrUnknownProvider       = New("unknown login provider")
+	ErrExternalLoginFailed   = New("external login failed")
+	ErrInsufficientRole      = New("insufficient organization role")
+	ErrLastOwner             = New("organization must keep an owner")
+	ErrInvitationMismatch    = New("invitation was sent to another email address")
+	ErrCannotImpersonate     = New("user cannot be impersonated")
+	ErrActorNotAdmin         = New("impersonating user is disabled or no longer an admin")
 )
+
+// LockoutError is returned while logins are blocked after repeated failures.
+// It wraps ErrAccountLocked when the account is locked and ErrTooManyAttempts
+// when the client IP is.
+type LockoutError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LockoutError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,30 @@
+package errors
+
+// OAuth error codes from RFC 6749 sections 4.1.2.1 and 5.2
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+)
+
+// OAuthError is returned by the OAuth authorization server. Code is one of
+// the error codes above and is reported to the client together with
+// Description.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+// NewOAuthError returns an OAuthError with the given code and description
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
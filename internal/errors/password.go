@@ -0,0 +1,15 @@
+package errors
+
+import (
+	"strings"
+)
+
+// PasswordPolicyError is returned when a new password does not comply with
+// the password policy. Reasons describes every rule the password breaks.
+type PasswordPolicyError struct {
+	Reasons []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password rejected: " + strings.Join(e.Reasons, "; ")
+}
@@ -0,0 +1,57 @@
+package errors
+
+var (
+	// ErrInvalidSchedule is returned for a todo that would start after it is due
+	ErrInvalidSchedule = New("todo starts after it is due")
+	// ErrUnknownLabel is returned when a todo refers to a label that does not
+	// exist in its workspace
+	ErrUnknownLabel = New("unknown label")
+	// ErrLabelExists is returned when a label would get the name of another
+	// label in its workspace, compared case-insensitively
+	ErrLabelExists = New("label already exists")
+	// ErrUnknownProject is returned when a todo refers to a project that does
+	// not exist in its workspace
+	ErrUnknownProject = New("unknown project")
+	// ErrProjectArchived is returned when a todo is added to an archived project
+	ErrProjectArchived = New("project is archived")
+	// ErrProjectRestricted is returned when a workspace restricted to some of
+	// its projects would change data outside of them
+	ErrProjectRestricted = New("restricted to other projects")
+	// ErrUnknownParent is returned when a todo refers to a parent todo that
+	// does not exist in its workspace
+	ErrUnknownParent = New("unknown parent todo")
+	// ErrTodoCycle is returned when a todo would become its own ancestor
+	ErrTodoCycle = New("todo cannot be a subtask of itself")
+	// ErrTodoTooDeep is returned when subtasks would be nested deeper than
+	// the configured limit
+	ErrTodoTooDeep = New("subtasks are nested too deeply")
+	// ErrBlockedSubtask is returned when a todo with a blocked subtask is
+	// marked done
+	ErrBlockedSubtask = New("todo has a blocked subtask")
+	// ErrNotRecurring is returned for occurrence operations on a todo without
+	// a recurrence
+	ErrNotRecurring = New("todo does not recur")
+	// ErrNoOccurrences is returned when the series of a recurring todo has no
+	// occurrences left
+	ErrNoOccurrences = New("no further occurrences")
+)
+
+// TodoStatusError is returned when a todo cannot move from its current
+// status to the requested one
+type TodoStatusError struct {
+	From string
+	To   string
+}
+
+func (e *TodoStatusError) Error() string {
+	return "cannot change todo status from " + e.From + " to " + e.To
+}
+
+// RecurrenceError is returned for a todo whose recurrence rule is invalid
+type RecurrenceError struct {
+	Reason string
+}
+
+func (e *RecurrenceError) Error() string {
+	return "invalid recurrence: " + e.Reason
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFileMailer(filepath.Join(dir, "mail"), "noreply@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(Message{To: "jane@example.com", Subject: "Hello", Body: "line one\nline two"}))
+
+	files, err := os.ReadDir(filepath.Join(dir, "mail"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(filepath.Join(dir, "mail", files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "From: noreply@example.com\r\n")
+	assert.Contains(t, string(data), "To: jane@example.com\r\n")
+	assert.Contains(t, string(data), "Subject: Hello\r\n")
+	assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nline one\r\nline two"))
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+
+	require.NoError(t, m.Send(Message{To: "jane@example.com", Subject: "Hello", Body: "Hi"}))
+
+	messages := m.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "jane@example.com", messages[0].To)
+}
+
+func TestHeaderInjection(t *testing.T) {
+	m := NewMemoryMailer()
+
+	err := m.Send(Message{To: "jane@example.com\r\nBcc: everyone@example.com", Subject: "Hello"})
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+	assert.Empty(t, m.Messages())
+
+	err = m.Send(Message{To: "jane@example.com", Subject: "Hello\nBcc: everyone@example.com"})
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
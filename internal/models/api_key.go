@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey is a long-lived credential for machine-to-machine clients. Only the
+// SHA-256 hash of the key is stored; Prefix is kept to help users tell keys apart.
+type APIKey struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	UserID uint   `gorm:"index;not null" json:"user_id"`
+	Name   string `gorm:"not null" json:"name"`
+	Prefix string `json:"prefix"`
+	// Scopes limits the key to a subset of the owner's permissions; empty means all of them
+	Scopes []string `gorm:"serializer:json" json:"scopes"`
+	// ProjectIDs limits the key to the todos of these personal projects;
+	// empty means all of the owner's todos
+	ProjectIDs []uint     `gorm:"serializer:json" json:"project_ids"`
+	KeyHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// Key holds the plaintext value right after the key is created and is never persisted
+	Key string `gorm:"-" json:"-"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name" validate:"required,min=1,max=100"`
+	Scopes     []string   `json:"scopes"`
+	ProjectIDs []uint     `json:"project_ids"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey
+	// Key is shown only once and cannot be retrieved later
+	Key string `json:"key"`
+}
+
+// Permissions narrows the permissions granted to the key's owner down to the key's scopes
+func (k *APIKey) Permissions(granted []string) []string {
+	if len(k.Scopes) == 0 {
+		return granted
+	}
+
+	scoped := make(map[string]bool, len(k.Scopes))
+	for _, scope := range k.Scopes {
+		scoped[scope] = true
+	}
+
+	permissions := []string{}
+	for _, permission := range granted {
+		if scoped[permission] {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
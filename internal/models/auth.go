@@ -6,12 +6,24 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	// Token is the short-lived access token
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+	// MFARequired is set instead of the tokens above when the user has two-factor
+	// authentication enabled; MFAToken must then be exchanged at /auth/mfa/verify
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+	// CSRFToken is set instead of the tokens when they were set as cookies
+	// and must be sent in the X-CSRF-Token header of state-changing requests
+	CSRFToken string `json:"csrf_token,omitempty"`
 }
 
 type RegisterRequest struct {
 	Name  string `json:"name" validate:"required,min=3,max=50"`
-	Pass  string `json:"pass" validate:"required,min=8"`
+	Pass  string `json:"pass" validate:"required"`
 	Email string `json:"email" validate:"required,email"`
 }
 
@@ -25,6 +37,50 @@ type LogoutResponse struct {
 	Message string `json:"message"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
 type RefreshTokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	ExpiresIn    int64  `json:"expires_in"`
+	// CSRFToken is set instead of the tokens when they were set as cookies
+	CSRFToken string `json:"csrf_token,omitempty"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token string `json:"token" validate:"required"`
+	Pass  string `json:"pass" validate:"required"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type UpdateProfileRequest struct {
+	Name *string `json:"name" validate:"omitempty,min=3,max=50"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPass string `json:"current_pass" validate:"required"`
+	NewPass     string `json:"new_pass" validate:"required"`
+}
+
+type ChangeEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Pass  string `json:"pass" validate:"required"`
+}
+
+type SetRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,required"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import (
+	"time"
+)
+
+// DataMigration records a one-off change to existing rows that has been
+// applied, so that it is not applied again
+type DataMigration struct {
+	Name      string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to their account at an external login provider.
+// Subject is the stable ID the provider knows the user by.
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"-"`
+	Provider    string     `gorm:"uniqueIndex:idx_user_identities_provider_subject;not null" json:"provider"`
+	Subject     string     `gorm:"uniqueIndex:idx_user_identities_provider_subject;not null" json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OAuthState is an external login in progress. It is looked up by the hash of
+// the state parameter when the provider redirects back and holds the nonce
+// and PKCE code verifier of the login.
+type OAuthState struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"uniqueIndex;not null"`
+	Provider     string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+}
+
+// TableName keeps GORM from naming the table o_auth_states
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
+
+// OIDCProvidersResponse lists the external login providers that are configured
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// ImpersonationLog is the audit trail of admins acting as other users. An
+// entry with a Reason is written when an impersonation token is issued, and
+// one entry per request made with the token.
+type ImpersonationLog struct {
+	ID      uint `gorm:"primaryKey" json:"id"`
+	ActorID uint `gorm:"index;not null" json:"actor_id"`
+	UserID  uint `gorm:"index;not null" json:"user_id"`
+	// TokenID is the jti of the impersonation token and links the requests
+	// made with a token to the entry that issued it
+	TokenID   string    `gorm:"index;not null" json:"token_id"`
+	Reason    string    `json:"reason,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required,min=3,max=500"`
+}
+
+type ImpersonateResponse struct {
+	Token     string `json:"token"`
+	TokenType string `json:"token_type"`
+	// ExpiresIn is the lifetime of the token in seconds. Impersonation tokens
+	// cannot be refreshed.
+	ExpiresIn int64 `json:"expires_in"`
+	User      User  `json:"user"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Label is a user-defined tag for todos. Labels belong to a workspace like
+// todos do and are attached to todos by ID, so renaming a label renames it
+// on every todo.
+type Label struct {
+	// example: 1
+	ID uint `gorm:"primaryKey" json:"id"`
+	// The ID of the user who created the label.
+	// example: 1
+	UserID uint `gorm:"index;not null" json:"user_id"`
+	// The ID of the organization the label belongs to, unset for personal labels.
+	// example: 2
+	OrganizationID *uint `gorm:"index" json:"organization_id,omitempty"`
+	// example: urgent
+	Name string `gorm:"not null" json:"name"`
+	// example: #ff0000
+	Color     string    `json:"color,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type LabelRequest struct {
+	Name  string `json:"name" validate:"required,min=1,max=50"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginThrottle counts consecutive failed logins for one subject, either an
+// account name ("account:<name>") or a client IP ("ip:<address>"). Names
+// are tracked whether or not an account exists so that lockouts do not
+// reveal which names are registered.
+type LoginThrottle struct {
+	Subject       string     `gorm:"primaryKey" json:"subject"`
+	Failures      int        `gorm:"not null" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// AccountThrottleSubject returns the LoginThrottle subject for an account name
+func AccountThrottleSubject(name string) string {
+	return "account:" + name
+}
+
+// IPThrottleSubject returns the LoginThrottle subject for a client IP
+func IPThrottleSubject(ip string) string {
+	return "ip:" + ip
+}
@@ -1,6 +1,28 @@
 package models
 
 var ModelsToMigrate = []interface{}{
+	&Permission{},
+	&Role{},
 	&User{},
+	&Label{},
+	&Project{},
 	&Todo{},
+	&RefreshToken{},
+	&RevokedToken{},
+	&UserTokenRevocation{},
+	&APIKey{},
+	&RecoveryCode{},
+	&UserToken{},
+	&LoginThrottle{},
+	&Session{},
+	&UserIdentity{},
+	&OAuthState{},
+	&OAuthClient{},
+	&OAuthAuthorizationCode{},
+	&PasswordHistory{},
+	&Organization{},
+	&OrganizationMember{},
+	&OrganizationInvitation{},
+	&ImpersonationLog{},
+	&DataMigration{},
 }
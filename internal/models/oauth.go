@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+)
+
+// OAuthClient is a third-party application that may obtain access tokens
+// through the OAuth authorization server. Confidential clients authenticate
+// with a secret, of which only the SHA-256 hash is stored; public clients
+// such as mobile apps have none and must use PKCE.
+type OAuthClient struct {
+	ID           uint     `gorm:"primaryKey" json:"id"`
+	ClientID     string   `gorm:"uniqueIndex;not null" json:"client_id"`
+	SecretHash   string   `json:"-"`
+	Name         string   `gorm:"not null" json:"name"`
+	RedirectURIs []string `gorm:"serializer:json" json:"redirect_uris"`
+	// Scopes are the permissions the client may request
+	Scopes       []string `gorm:"serializer:json" json:"scopes"`
+	Confidential bool     `json:"confidential"`
+	// UserID is the user that tokens from the client credentials grant act
+	// as. The grant is not available to clients without one.
+	UserID    *uint      `json:"user_id,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	// Secret holds the plaintext secret right after the client is created and is never persisted
+	Secret string `gorm:"-" json:"-"`
+}
+
+// TableName keeps GORM from naming the table o_auth_clients
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// HasRedirectURI reports whether uri is registered for the client. URIs must
+// match exactly.
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthAuthorizationCode is issued when a user approves a client and is
+// exchanged by the client for an access token. Only its hash is stored.
+type OAuthAuthorizationCode struct {
+	ID            uint      `gorm:"primaryKey"`
+	CodeHash      string    `gorm:"uniqueIndex;not null"`
+	ClientID      string    `gorm:"index;not null"`
+	UserID        uint      `gorm:"not null"`
+	RedirectURI   string    `gorm:"not null"`
+	Scopes        []string  `gorm:"serializer:json"`
+	CodeChallenge string    `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"index"`
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// TableName keeps GORM from naming the table o_auth_authorization_codes
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,min=1,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,required"`
+	Confidential bool     `json:"confidential"`
+	UserID       *uint    `json:"user_id"`
+}
+
+type CreateOAuthClientResponse struct {
+	OAuthClient
+	// ClientSecret is shown only once and cannot be retrieved later. It is
+	// empty for public clients.
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthAuthorizeRequest holds the parameters of an authorization request,
+// which the consent screen passes on unchanged when the user decides
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `query:"response_type" json:"response_type"`
+	ClientID            string `query:"client_id" json:"client_id"`
+	RedirectURI         string `query:"redirect_uri" json:"redirect_uri"`
+	Scope               string `query:"scope" json:"scope"`
+	State               string `query:"state" json:"state"`
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method"`
+	// Approve is the user's decision on the consent screen
+	Approve bool `query:"-" json:"approve"`
+}
+
+// OAuthConsentResponse describes what the user is asked to approve
+type OAuthConsentResponse struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+}
+
+// OAuthRedirectResponse is where the user must be sent after deciding
+type OAuthRedirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// OAuthTokenRequest is the form posted to the token endpoint
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type"`
+	Code         string `form:"code" json:"code"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier"`
+	Scope        string `form:"scope" json:"scope"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+}
+
+// OAuthTokenResponse is the RFC 6749 access token response. Unlike other
+// responses it is not wrapped in data so that OAuth client libraries can read it.
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// OAuthErrorResponse is the RFC 6749 error response of the token endpoint
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
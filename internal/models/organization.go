@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+)
+
+// Roles of an organization member. Owners manage members and roles and can
+// delete the organization, admins can invite and remove members.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// OrgRoleRank orders the organization roles from least to most privileged
+var OrgRoleRank = map[string]int{
+	OrgRoleMember: 1,
+	OrgRoleAdmin:  2,
+	OrgRoleOwner:  3,
+}
+
+// Workspace is where a request acts: the user's personal space when
+// OrganizationID is 0, otherwise the organization, whose data every member
+// shares
+type Workspace struct {
+	UserID         uint
+	OrganizationID uint
+	// ProjectIDs limits the workspace to the todos of these projects, as for
+	// an API key restricted to projects; empty means the whole workspace
+	ProjectIDs []uint
+}
+
+// Restricted reports whether the workspace is limited to some of its projects
+func (ws Workspace) Restricted() bool {
+	return len(ws.ProjectIDs) > 0
+}
+
+// Organization groups users that share todos
+type Organization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Role is the role of the requesting user. It is read from the
+	// membership and is not a column of the table.
+	Role string `gorm:"->;-:migration" json:"role,omitempty"`
+}
+
+// OrganizationMember is the membership of a user in an organization
+type OrganizationMember struct {
+	OrganizationID uint      `gorm:"primaryKey" json:"organization_id"`
+	UserID         uint      `gorm:"primaryKey;index" json:"user_id"`
+	Role           string    `gorm:"not null" json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+	User           User      `json:"user"`
+}
+
+// OrganizationInvitation invites the owner of an email address to join an
+// organization. Only the SHA-256 hash of the token sent by email is stored.
+type OrganizationInvitation struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID uint       `gorm:"index;not null" json:"organization_id"`
+	Email          string     `gorm:"index;not null" json:"email"`
+	Role           string     `gorm:"not null" json:"role"`
+	InvitedByID    uint       `json:"invited_by_id"`
+	TokenHash      string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	DeclinedAt     *time.Time `json:"declined_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Organization Organization `json:"organization"`
+	// Token holds the plaintext value right after the invitation is created and is never persisted
+	Token string `gorm:"-" json:"-"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,min=3,max=100"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type SetMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type InvitationTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type SwitchOrganizationRequest struct {
+	// OrganizationID is the organization to act in, 0 for the personal space
+	OrganizationID uint `json:"organization_id"`
+}
+
+type SwitchOrganizationResponse struct {
+	// Token is omitted when the request was authenticated by cookie, whose
+	// access token is replaced instead
+	Token          string `json:"token,omitempty"`
+	TokenType      string `json:"token_type,omitempty"`
+	ExpiresIn      int64  `json:"expires_in"`
+	OrganizationID uint   `json:"organization_id"`
+}
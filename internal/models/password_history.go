@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory keeps the hashes of a user's recent passwords so that the
+// password policy can reject reusing them
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Hash      []byte    `gorm:"not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
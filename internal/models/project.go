@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// Project groups the todos of a workspace. Archiving a project hides its
+// todos from the default todo list without deleting them.
+type Project struct {
+	// example: 1
+	ID uint `gorm:"primaryKey" json:"id"`
+	// The ID of the user who created the project.
+	// example: 1
+	UserID uint `gorm:"index;not null" json:"user_id"`
+	// The ID of the organization the project belongs to, unset for personal projects.
+	// example: 2
+	OrganizationID *uint `gorm:"index" json:"organization_id,omitempty"`
+	// example: Website relaunch
+	Name string `gorm:"not null" json:"name"`
+	// example: Everything for the new website
+	Description string `gorm:"type:text" json:"description,omitempty"`
+	// example: #00aa88
+	Color string `json:"color,omitempty"`
+	// Whether the project is archived.
+	// example: false
+	Archived bool `gorm:"index;not null;default:false" json:"archived"`
+	// The number of todos in the project.
+	Counts    ProjectCounts `gorm:"-" json:"counts"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// ProjectCounts counts the todos of a project. Open todos are those that are
+// neither done nor cancelled.
+type ProjectCounts struct {
+	// example: 12
+	Total int64 `json:"total"`
+	// example: 5
+	Open int64 `json:"open"`
+	// example: 6
+	Done int64 `json:"done"`
+}
+
+type ProjectRequest struct {
+	Name        string `json:"name" validate:"required,min=1,max=100"`
+	Description string `json:"description" validate:"max=2000"`
+	Color       string `json:"color" validate:"omitempty,hexcolor"`
+	Archived    bool   `json:"archived"`
+}
+
+// MoveTodoRequest moves a todo into a project, or out of its project if
+// ProjectID is null
+type MoveTodoRequest struct {
+	// example: 3
+	ProjectID *uint `json:"project_id"`
+}
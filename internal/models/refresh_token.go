@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is an opaque, single-use credential that can be exchanged for
+// a new access token. Only the SHA-256 hash of the token is stored. Tokens
+// issued from the same login share a FamilyID so that the whole chain can be
+// revoked when a rotated token is presented again.
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID     string     `gorm:"index;not null" json:"family_id"`
+	TokenHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Token holds the plaintext value right after the token is issued and is never persisted
+	Token string `gorm:"-" json:"-"`
+	// OrganizationID is the active organization of the token's session after
+	// a rotation and is never persisted
+	OrganizationID uint `gorm:"-" json:"-"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken records an access token that was revoked before its expiry.
+// Rows can be removed once ExpiresAt has passed.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// UserTokenRevocation invalidates every access token of a user issued before RevokedBefore
+type UserTokenRevocation struct {
+	UserID        uint `gorm:"primaryKey"`
+	RevokedBefore time.Time
+	UpdatedAt     time.Time `gorm:"index"`
+}
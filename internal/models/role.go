@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// Built-in permissions
+const (
+	PermTodosRead  = "todos:read"
+	PermTodosWrite = "todos:write"
+	PermUsersAdmin = "users:admin"
+)
+
+// Built-in roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permission is a named capability, e.g. todos:read, granted through roles
+type Permission struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Role groups permissions that are assigned to users together
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// DefaultPermissions are created on startup if missing
+var DefaultPermissions = []Permission{
+	{Name: PermTodosRead, Description: "Read own todos"},
+	{Name: PermTodosWrite, Description: "Create, update and delete own todos"},
+	{Name: PermUsersAdmin, Description: "Manage all user accounts"},
+}
+
+// DefaultRoles are created on startup if missing, granting the listed permissions
+var DefaultRoles = map[string][]string{
+	RoleUser:  {PermTodosRead, PermTodosWrite},
+	RoleAdmin: {PermTodosRead, PermTodosWrite, PermUsersAdmin},
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a login on one device. Its ID is the FamilyID of the refresh
+// tokens issued for the login and the sid claim of its access tokens, so
+// revoking a session invalidates both.
+type Session struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	// OrganizationID is the organization the session acts in, nil for the
+	// user's personal space. Refreshed access tokens carry it.
+	OrganizationID *uint `json:"organization_id,omitempty"`
+
+	// Current marks the session the request was made from
+	Current bool `gorm:"-" json:"current"`
+}
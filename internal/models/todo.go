@@ -6,18 +6,140 @@ import (
 	"gorm.io/gorm"
 )
 
+// Statuses of a todo. A todo starts as TodoStatusTodo; which status can
+// follow which is decided by the todo service.
+const (
+	TodoStatusTodo       = "todo"
+	TodoStatusInProgress = "in_progress"
+	TodoStatusBlocked    = "blocked"
+	TodoStatusDone       = "done"
+	TodoStatusCancelled  = "cancelled"
+)
+
+// Priorities of a todo, from lowest to highest
+const (
+	TodoPriorityNone   = "none"
+	TodoPriorityLow    = "low"
+	TodoPriorityMedium = "medium"
+	TodoPriorityHigh   = "high"
+	TodoPriorityUrgent = "urgent"
+)
+
 // Todo represents a task to be done.
 type Todo struct {
 	// The ID of the todo item.
 	// example: 1
 	ID uint `gorm:"primaryKey" json:"id"`
+	// The ID of the user who owns the todo item.
+	// example: 1
+	UserID uint `gorm:"index" json:"user_id"`
+	// The ID of the organization the todo belongs to, unset for personal todos.
+	// example: 2
+	OrganizationID *uint `gorm:"index" json:"organization_id,omitempty"`
 	// The title of the todo item.
 	// example: Buy groceries
 	Title string `json:"title" validate:"required,min=3,max=255"`
-	// The status of the todo item.
+	// A longer description of the todo item in Markdown.
+	// example: Milk, eggs and **fresh** bread
+	Description string `gorm:"type:text" json:"description,omitempty" validate:"max=10000"`
+	// The status of the todo item. Defaults to todo, or to done when only
+	// completed is set.
+	// example: in_progress
+	Status string `gorm:"index;not null;default:todo" json:"status" validate:"omitempty,oneof=todo in_progress blocked done cancelled"`
+	// Whether the todo item is done. It is derived from status and kept for
+	// clients that predate it; setting it without a status marks the todo
+	// done or reopens it.
 	// example: false
-	Completed bool           `json:"completed"`
+	Completed bool `json:"completed"`
+	// When the todo item was last marked done.
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// The priority of the todo item.
+	// example: high
+	Priority string `gorm:"not null;default:none" json:"priority" validate:"omitempty,oneof=none low medium high urgent"`
+	// When work on the todo item should start.
+	// example: 2024-05-01T09:00:00+02:00
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// When the todo item is due.
+	// example: 2024-05-03T17:00:00+02:00
+	DueAt *time.Time `gorm:"index" json:"due_at,omitempty"`
+	// The IANA time zone start_at and due_at are shown in.
+	// example: Europe/Berlin
+	TimeZone string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
+	// An RFC 5545 RRULE that makes the todo item recur, evaluated in
+	// time_zone from due_at, or start_at if it has no due date. Completing
+	// the todo creates the todo for the next occurrence, which takes the
+	// rule over. COUNT and UNTIL end the series. The rule is kept on update
+	// if omitted, together with the dates if those are omitted too, and
+	// removed if empty.
+	// example: FREQ=WEEKLY;BYDAY=MO
+	Recurrence *string `json:"recurrence,omitempty" validate:"omitempty,max=500"`
+	// The first occurrence of the series, from which COUNT is counted. It is
+	// set when the recurrence is.
+	RecurrenceStart *time.Time `json:"recurrence_start,omitempty"`
+	// The dates, in time_zone, of occurrences that are skipped. They are
+	// kept on update if omitted.
+	// example: ["2024-05-20"]
+	RecurrenceExceptions []string `gorm:"serializer:json;type:text" json:"recurrence_exceptions,omitempty" validate:"omitempty,dive,datetime=2006-01-02"`
+	// The todo created for the next occurrence. Only returned by the update
+	// that completes a recurring todo.
+	Next *Todo `gorm:"-" json:"next,omitempty" validate:"-"`
+	// The ID of the project the todo item belongs to. Todos are moved
+	// between projects with PUT /todos/{id}/project; updates keep it.
+	// example: 3
+	ProjectID *uint `gorm:"index" json:"project_id,omitempty"`
+	// The ID of the todo item this is a subtask of. Subtasks are moved
+	// with PUT /todos/{id}/parent; updates keep it.
+	// example: 7
+	ParentID *uint `gorm:"index" json:"parent_id,omitempty"`
+	// How many subtasks, at any depth, are done. Only returned for single
+	// todos.
+	Progress *TodoProgress `gorm:"-" json:"progress,omitempty" validate:"-"`
+	// The subtasks of the todo item. Only returned for subtrees.
+	Subtasks []Todo `gorm:"-" json:"subtasks,omitempty" validate:"-"`
+	// The labels of the todo item.
+	Labels []Label `gorm:"many2many:todo_labels" json:"labels" validate:"-"`
+	// The IDs of the labels to attach on create or update, replacing the
+	// current labels. The labels are kept on update if omitted.
+	// example: [1,2]
+	LabelIDs  []uint         `gorm:"-" json:"label_ids,omitempty"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// TodoProgress counts the subtasks of a todo at any depth. Cancelled subtasks
+// are not counted.
+type TodoProgress struct {
+	// example: 3
+	Done int `json:"done"`
+	// example: 5
+	Total int `json:"total"`
+}
+
+// SetParentRequest makes a todo a subtask of another todo, or a top-level
+// todo if ParentID is null
+type SetParentRequest struct {
+	// example: 7
+	ParentID *uint `json:"parent_id"`
+}
+
+// TodoOccurrence is an upcoming occurrence of a recurring todo
+type TodoOccurrence struct {
+	// example: 2024-05-13T09:00:00+02:00
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// example: 2024-05-13T17:00:00+02:00
+	DueAt *time.Time `json:"due_at,omitempty"`
+}
+
+// TodoFilter narrows the todos listed in a workspace
+type TodoFilter struct {
+	// Labels are label names, matched case-insensitively. A todo matches if
+	// it has any of them, or all of them if MatchAllLabels is set.
+	Labels         []string
+	MatchAllLabels bool
+	// ProjectID limits the todos to those of one project, archived or not.
+	ProjectID uint
+	// IncludeArchived includes the todos of archived projects, which are
+	// left out by default.
+	IncludeArchived bool
+}
@@ -7,12 +7,59 @@ import (
 )
 
 // User represents a user in the system.
+//
+// EmailVerifiedAt is set once the user has followed a verification link.
+// PendingEmail holds a requested new address until it is confirmed.
+// Disabled users cannot log in, and PasswordResetRequired blocks logins until
+// the password has been reset by email.
+//
+// When TOTPEnabled is set, logging in requires a code for TOTPSecret.
+// TOTPPendingSecret holds a new secret during enrollment until a first code
+// is confirmed, and TOTPLastStep is the time step of the last accepted code
+// so that a code cannot be replayed.
+//
+// Name and Email are unique among the users that are not deleted, so that a
+// deleted user's name and email address can be registered again. The
+// indexes are created by the unique_active_users data migration.
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"uniqueIndex" json:"name" validate:"required,min=3,max=50"`
-	Pass      []byte         `json:"-" validate:"required"`
-	Email     string         `gorm:"uniqueIndex" json:"email" validate:"required,email"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                    uint           `gorm:"primaryKey" json:"id"`
+	Name                  string         `json:"name" validate:"required,min=3,max=50"`
+	Pass                  []byte         `json:"-" validate:"required"`
+	Email                 string         `json:"email" validate:"required,email"`
+	EmailVerifiedAt       *time.Time     `json:"email_verified_at,omitempty"`
+	PendingEmail          string         `json:"pending_email,omitempty"`
+	DisabledAt            *time.Time     `json:"disabled_at,omitempty"`
+	PasswordResetRequired bool           `json:"password_reset_required"`
+	Roles                 []Role         `gorm:"many2many:user_roles" json:"roles,omitempty"`
+	TOTPEnabled           bool           `json:"totp_enabled"`
+	TOTPSecret            string         `json:"-"`
+	TOTPPendingSecret     string         `json:"-"`
+	TOTPLastStep          int64          `json:"-"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// RoleNames returns the names of the roles assigned to the user
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// PermissionNames returns the distinct permissions granted by the user's roles
+func (u *User) PermissionNames() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, role := range u.Roles {
+		for _, permission := range role.Permissions {
+			if !seen[permission.Name] {
+				seen[permission.Name] = true
+				names = append(names, permission.Name)
+			}
+		}
+	}
+	return names
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Purposes of a UserToken
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeChangeEmail   = "change_email"
+)
+
+// UserToken is a single-use token sent to the user by email to verify their
+// address or reset their password. Only the SHA-256 hash of the token is
+// stored, and a token is only accepted for the purpose it was issued for.
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Purpose   string     `gorm:"not null" json:"purpose"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Token holds the plaintext value right after the token is issued and is never persisted
+	Token string `gorm:"-" json:"-"`
+}
@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/netf/gofiber-boilerplate/config"
+)
+
+// CallbackPath returns the path of the callback endpoint for a provider,
+// which must be registered as the redirect URI with the provider
+func CallbackPath(name string) string {
+	return "/api/v1/auth/oidc/" + name + "/callback"
+}
+
+// FromConfig builds the providers listed in the configuration. OpenID Connect
+// providers are discovered from their issuer when they are first used.
+func FromConfig(cfg *config.Config, client *http.Client) ([]*Provider, error) {
+	providers := make([]*Provider, 0, len(cfg.OIDCProviders))
+	for _, pc := range cfg.OIDCProviders {
+		if pc.ClientID == "" {
+			return nil, fmt.Errorf("login provider %s has no client ID", pc.Name)
+		}
+
+		clientConfig := Config{
+			Name:         pc.Name,
+			ClientID:     pc.ClientID,
+			ClientSecret: pc.ClientSecret,
+			RedirectURL:  strings.TrimSuffix(cfg.AppURL, "/") + CallbackPath(pc.Name),
+			Scopes:       pc.Scopes,
+		}
+
+		if pc.Name == KindGitHub && pc.Issuer == "" {
+			providers = append(providers, GitHub(clientConfig, client))
+			continue
+		}
+
+		issuer := pc.Issuer
+		if issuer == "" && pc.Name == "google" {
+			issuer = GoogleIssuer
+		}
+		if issuer == "" {
+			return nil, fmt.Errorf("login provider %s has no issuer", pc.Name)
+		}
+
+		providers = append(providers, NewLazyProvider(issuer, clientConfig, client))
+	}
+	return providers, nil
+}
@@ -0,0 +1,364 @@
+// Package oidc implements the client side of the OAuth 2.0 authorization code
+// flow with PKCE for logging in with external identity providers. OpenID
+// Connect providers are configured through discovery and identify users with
+// a verified ID token; GitHub, which does not implement OpenID Connect, is
+// supported through its REST API.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Provider kinds
+const (
+	KindOIDC   = "oidc"
+	KindGitHub = "github"
+)
+
+// GoogleIssuer is the issuer used for a provider named google without an
+// explicit issuer
+const GoogleIssuer = "https://accounts.google.com"
+
+// maxResponseSize bounds the provider responses that are read
+const maxResponseSize = 1 << 20
+
+// Config is the client registration of this application with a provider
+type Config struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Endpoints are the URLs of a provider. JWKSURL and Issuer are only used by
+// OpenID Connect providers, UserInfoURL and EmailsURL only by GitHub.
+type Endpoints struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+	EmailsURL   string `json:"-"`
+}
+
+// Identity is the user an external login resolved to
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+// Provider performs logins against one external identity provider
+type Provider struct {
+	kind   string
+	config Config
+	client *http.Client
+	// issuer is set for providers whose endpoints are discovered on first use
+	issuer string
+
+	// mu guards endpoints and keys until they have been discovered
+	mu        sync.Mutex
+	endpoints Endpoints
+	keys      *keySet
+}
+
+// NewProvider returns an OpenID Connect provider with known endpoints
+func NewProvider(cfg Config, endpoints Endpoints, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		kind:      KindOIDC,
+		config:    cfg,
+		endpoints: endpoints,
+		client:    client,
+		keys:      newKeySet(endpoints.JWKSURL, client),
+	}
+}
+
+// Discover fetches the OpenID Connect discovery document of issuer and
+// returns a provider using the endpoints it lists
+func Discover(ctx context.Context, issuer string, cfg Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	endpoints, err := discover(ctx, strings.TrimSuffix(issuer, "/"), client)
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(cfg, *endpoints, client), nil
+}
+
+// NewLazyProvider returns an OpenID Connect provider that is discovered from
+// issuer when it is first used rather than when it is created, so that an
+// unreachable provider only fails its own logins
+func NewLazyProvider(issuer string, cfg Config, client *http.Client) *Provider {
+	p := NewProvider(cfg, Endpoints{}, client)
+	p.issuer = strings.TrimSuffix(issuer, "/")
+	return p
+}
+
+// resolve discovers the endpoints of a provider created with NewLazyProvider
+// unless that has already been done. A failed discovery is retried by the
+// next call.
+func (p *Provider) resolve(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.issuer == "" || p.endpoints.AuthURL != "" {
+		return nil
+	}
+
+	endpoints, err := discover(ctx, p.issuer, p.client)
+	if err != nil {
+		return err
+	}
+	p.endpoints = *endpoints
+	p.keys = newKeySet(endpoints.JWKSURL, p.client)
+	return nil
+}
+
+func discover(ctx context.Context, issuer string, client *http.Client) (*Endpoints, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints Endpoints
+	if err := doJSON(client, req, &endpoints); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", issuer, err)
+	}
+	if endpoints.Issuer != issuer {
+		return nil, fmt.Errorf("discovery document of %s names issuer %q", issuer, endpoints.Issuer)
+	}
+	if endpoints.AuthURL == "" || endpoints.TokenURL == "" || endpoints.JWKSURL == "" {
+		return nil, fmt.Errorf("discovery document of %s is missing endpoints", issuer)
+	}
+	return &endpoints, nil
+}
+
+// GitHub returns a provider that logs users in with their GitHub account
+func GitHub(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+	return &Provider{
+		kind:   KindGitHub,
+		config: cfg,
+		endpoints: Endpoints{
+			AuthURL:     "https://github.com/login/oauth/authorize",
+			TokenURL:    "https://github.com/login/oauth/access_token",
+			UserInfoURL: "https://api.github.com/user",
+			EmailsURL:   "https://api.github.com/user/emails",
+		},
+		client: client,
+	}
+}
+
+// Name returns the configured name of the provider
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the URL to send the user to for logging in. The nonce
+// is only sent to OpenID Connect providers.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	if err := p.resolve(ctx); err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if p.kind == KindOIDC {
+		params.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(p.endpoints.AuthURL, "?") {
+		separator = "&"
+	}
+	return p.endpoints.AuthURL + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the identity of the
+// user who logged in. For OpenID Connect providers the ID token must carry
+// nonce.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	if err := p.resolve(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := p.exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.kind == KindGitHub {
+		return p.gitHubIdentity(ctx, token.AccessToken)
+	}
+
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+	return p.verifyIDToken(ctx, token.IDToken, nonce)
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoints.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token tokenResponse
+	if err := doJSON(p.client, req, &token); err != nil {
+		if token.Error != "" {
+			return nil, fmt.Errorf("exchanging code: %s", token.Error)
+		}
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+	// GitHub reports errors with a 200 status
+	if token.Error != "" {
+		return nil, fmt.Errorf("exchanging code: %s", token.Error)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	return &token, nil
+}
+
+func (p *Provider) gitHubIdentity(ctx context.Context, accessToken string) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.endpoints.UserInfoURL, accessToken, &user); err != nil {
+		return nil, fmt.Errorf("fetching GitHub user: %w", err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("GitHub user has no id")
+	}
+
+	// The profile only has the public email, which need not be verified
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.endpoints.EmailsURL, accessToken, &emails); err != nil {
+		return nil, fmt.Errorf("fetching GitHub emails: %w", err)
+	}
+
+	identity := &Identity{
+		Subject:  fmt.Sprint(user.ID),
+		Name:     user.Name,
+		Username: user.Login,
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+	return identity, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return doJSON(p.client, req, v)
+}
+
+// doJSON sends req and decodes the JSON response into v. Error responses are
+// decoded as well before an error is returned.
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return decodeErr
+}
+
+// NewVerifier returns a random PKCE code verifier
+func NewVerifier() (string, error) {
+	return randomString()
+}
+
+// NewNonce returns a random value for the state or nonce of a login
+func NewNonce() (string, error) {
+	return randomString()
+}
+
+// Challenge returns the S256 PKCE code challenge of verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
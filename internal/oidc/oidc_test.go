@@ -0,0 +1,249 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer is a stand-in OpenID Connect provider. It hands out a single
+// authorization code and answers the token request for it with an ID token
+// built from the fields below.
+type testServer struct {
+	*httptest.Server
+	t         *testing.T
+	key       *rsa.PrivateKey
+	code      string
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+	// down makes discovery fail; discoveries counts the discovery requests
+	down        bool
+	discoveries int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	s := &testServer{t: t, key: key, code: "test-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) discovery(w http.ResponseWriter, r *http.Request) {
+	s.discoveries++
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *testServer) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize simulates the user logging in at the provider
+func (s *testServer) authorize(authURL string) {
+	u, err := url.Parse(authURL)
+	require.NoError(s.t, err)
+	s.challenge = u.Query().Get("code_challenge")
+	s.nonce = u.Query().Get("nonce")
+}
+
+func (s *testServer) token(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("code") != s.code || Challenge(r.FormValue("code_verifier")) != s.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            "user-123",
+		"aud":            r.FormValue("client_id"),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          s.nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+	}
+	for k, v := range s.claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	idToken, err := token.SignedString(s.key)
+	require.NoError(s.t, err)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func testConfig() Config {
+	return Config{Name: "test", ClientID: "client", ClientSecret: "secret", RedirectURL: "http://app/callback"}
+}
+
+func TestDiscoverAndExchange(t *testing.T) {
+	server := newTestServer(t)
+
+	provider, err := Discover(context.Background(), server.URL, testConfig(), server.Client())
+	require.NoError(t, err)
+
+	verifier, err := NewVerifier()
+	require.NoError(t, err)
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	require.NoError(t, err)
+	assert.Contains(t, authURL, server.URL+"/authorize?")
+	assert.Contains(t, authURL, "code_challenge_method=S256")
+	server.authorize(authURL)
+
+	identity, err := provider.Exchange(context.Background(), server.code, verifier, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", identity.Subject)
+	assert.Equal(t, "alice@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+}
+
+func TestExchangeRejectsInvalidLogins(t *testing.T) {
+	testCases := []struct {
+		name     string
+		claims   jwt.MapClaims
+		verifier string
+		nonce    string
+	}{
+		{name: "Wrong Verifier", verifier: "other-verifier", nonce: "nonce"},
+		{name: "Nonce Mismatch", nonce: "other-nonce"},
+		{name: "Wrong Audience", claims: jwt.MapClaims{"aud": "other-client"}, nonce: "nonce"},
+		{name: "Wrong Issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com"}, nonce: "nonce"},
+		{name: "Expired", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, nonce: "nonce"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t)
+			server.claims = tc.claims
+
+			provider, err := Discover(context.Background(), server.URL, testConfig(), server.Client())
+			require.NoError(t, err)
+
+			verifier, err := NewVerifier()
+			require.NoError(t, err)
+			authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+			require.NoError(t, err)
+			server.authorize(authURL)
+
+			if tc.verifier != "" {
+				verifier = tc.verifier
+			}
+			_, err = provider.Exchange(context.Background(), server.code, verifier, tc.nonce)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	server := newTestServer(t)
+
+	_, err := Discover(context.Background(), server.URL+"/other", testConfig(), server.Client())
+	assert.Error(t, err)
+}
+
+func TestLazyProviderDiscoversOnFirstUse(t *testing.T) {
+	server := newTestServer(t)
+	server.down = true
+
+	provider := NewLazyProvider(server.URL+"/", testConfig(), server.Client())
+	assert.Equal(t, 0, server.discoveries)
+
+	// A failed discovery fails the login and is retried by the next one
+	verifier, err := NewVerifier()
+	require.NoError(t, err)
+	_, err = provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	assert.Error(t, err)
+
+	server.down = false
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	require.NoError(t, err)
+	assert.Contains(t, authURL, server.URL+"/authorize?")
+	server.authorize(authURL)
+
+	identity, err := provider.Exchange(context.Background(), server.code, verifier, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", identity.Subject)
+	assert.Equal(t, 2, server.discoveries)
+}
+
+func TestGitHubExchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		// GitHub answers failed exchanges with a 200 status
+		if r.FormValue("code") != "good" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "gh-token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "login": "octocat", "name": "The Octocat"})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := GitHub(testConfig(), server.Client())
+	provider.endpoints.TokenURL = server.URL + "/token"
+	provider.endpoints.UserInfoURL = server.URL + "/user"
+	provider.endpoints.EmailsURL = server.URL + "/user/emails"
+
+	identity, err := provider.Exchange(context.Background(), "good", "verifier", "")
+	require.NoError(t, err)
+	assert.Equal(t, "42", identity.Subject)
+	assert.Equal(t, "octocat", identity.Username)
+	assert.Equal(t, "octocat@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+
+	_, err = provider.Exchange(context.Background(), "bad", "verifier", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefixLength is the length of the SHA-1 hash prefix used to look up ranges
+const prefixLength = 5
+
+// BreachedList holds the SHA-1 hashes of passwords known from data breaches.
+// Lookups follow the k-anonymity model of the Pwned Passwords range API:
+// Range receives the first five hex characters of a hash and returns the
+// remaining 35 characters of every listed hash with that prefix, so that an
+// implementation backed by a remote service never sees the full hash.
+type BreachedList interface {
+	Range(prefix string) ([]string, error)
+}
+
+// IsBreached reports whether the password is in the list
+func IsBreached(list BreachedList, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := list.Range(hash[:prefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[prefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BreachedFile is a BreachedList read into memory from a local file
+type BreachedFile struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedFile reads a breached-password list in the format of the
+// Pwned Passwords downloads: one hex SHA-1 hash per line, optionally
+// followed by ":<count>". Empty lines and lines starting with # are skipped.
+func LoadBreachedFile(path string) (*BreachedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedFile{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, line)
+		}
+
+		prefix := hash[:prefixLength]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Range returns the suffixes of the listed hashes starting with prefix
+func (f *BreachedFile) Range(prefix string) ([]string, error) {
+	return f.ranges[strings.ToUpper(prefix)], nil
+}
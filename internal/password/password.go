@@ -0,0 +1,182 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/netf/gofiber-boilerplate/config"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Supported hashing algorithms
+const (
+	Argon2id = "argon2id"
+	Scrypt   = "scrypt"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported password hash algorithm")
+	ErrMalformedHash        = errors.New("malformed password hash")
+)
+
+// Argon2Params holds the tunable parameters of argon2id
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// ScryptParams holds the tunable parameters of scrypt. N is 2^LogN.
+type ScryptParams struct {
+	LogN uint8
+	R    int
+	P    int
+}
+
+// DefaultArgon2Params follows the OWASP recommendation for argon2id
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 2}
+
+// DefaultScryptParams matches the cost used by the legacy hashes
+var DefaultScryptParams = ScryptParams{LogN: 15, R: 8, P: 1}
+
+// Hasher produces and verifies self-describing password hashes. Every hash
+// carries its own random salt and an algorithm/parameters prefix, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+//	$scrypt$ln=15,r=8,p=1$<salt>$<key>
+//
+// Salt and key are unpadded standard base64.
+type Hasher struct {
+	Algorithm string
+	Argon2    Argon2Params
+	Scrypt    ScryptParams
+}
+
+// NewHasher creates a Hasher that produces hashes with the given algorithm
+// and the default parameters
+func NewHasher(algorithm string) (*Hasher, error) {
+	switch algorithm {
+	case "", Argon2id:
+		algorithm = Argon2id
+	case Scrypt:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	return &Hasher{
+		Algorithm: algorithm,
+		Argon2:    DefaultArgon2Params,
+		Scrypt:    DefaultScryptParams,
+	}, nil
+}
+
+// Hash derives an encoded hash of the password using a fresh random salt
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	switch h.Algorithm {
+	case Argon2id:
+		p := h.Argon2
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, keyLength)
+		return []byte(fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			Argon2id, argon2.Version, p.Memory, p.Iterations, p.Parallelism, encode(salt), encode(key))), nil
+	case Scrypt:
+		p := h.Scrypt
+		key, err := scrypt.Key([]byte(password), salt, 1<<p.LogN, p.R, p.P, keyLength)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s",
+			Scrypt, p.LogN, p.R, p.P, encode(salt), encode(key))), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, h.Algorithm)
+	}
+}
+
+// Verify reports whether password matches the encoded hash. needsRehash is
+// true when the hash is valid but was produced by a legacy scheme, another
+// algorithm or different parameters than the Hasher is configured with.
+func (h *Hasher) Verify(password string, encoded []byte) (ok bool, needsRehash bool, err error) {
+	if len(encoded) == 0 {
+		return false, false, ErrMalformedHash
+	}
+
+	// Hashes without a prefix were produced by the former global-salt scrypt scheme
+	if encoded[0] != '$' {
+		key, err := scrypt.Key([]byte(password), config.PwSalt, 32768, 8, 1, 32)
+		if err != nil {
+			return false, false, err
+		}
+		return subtle.ConstantTimeCompare(key, encoded) == 1, true, nil
+	}
+
+	parts := strings.Split(string(encoded), "$")
+	switch parts[1] {
+	case Argon2id:
+		if len(parts) != 6 {
+			return false, false, ErrMalformedHash
+		}
+		var version int
+		var p Argon2Params
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return false, false, ErrMalformedHash
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+			return false, false, ErrMalformedHash
+		}
+		salt, want, err := decodeSaltAndKey(parts[4], parts[5])
+		if err != nil {
+			return false, false, err
+		}
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(want)))
+		return subtle.ConstantTimeCompare(key, want) == 1, h.Algorithm != Argon2id || p != h.Argon2, nil
+	case Scrypt:
+		if len(parts) != 5 {
+			return false, false, ErrMalformedHash
+		}
+		var p ScryptParams
+		if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &p.LogN, &p.R, &p.P); err != nil {
+			return false, false, ErrMalformedHash
+		}
+		salt, want, err := decodeSaltAndKey(parts[3], parts[4])
+		if err != nil {
+			return false, false, err
+		}
+		key, err := scrypt.Key([]byte(password), salt, 1<<p.LogN, p.R, p.P, len(want))
+		if err != nil {
+			return false, false, ErrMalformedHash
+		}
+		return subtle.ConstantTimeCompare(key, want) == 1, h.Algorithm != Scrypt || p != h.Scrypt, nil
+	default:
+		return false, false, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, parts[1])
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func decodeSaltAndKey(salt, key string) ([]byte, []byte, error) {
+	s, err := base64.RawStdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, nil, ErrMalformedHash
+	}
+	k, err := base64.RawStdEncoding.DecodeString(key)
+	if err != nil || len(k) == 0 {
+		return nil, nil, ErrMalformedHash
+	}
+	return s, k, nil
+}
@@ -0,0 +1,101 @@
+package password
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/scrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	for _, algorithm := range []string{Argon2id, Scrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher, err := NewHasher(algorithm)
+			require.NoError(t, err)
+
+			hash, err := hasher.Hash("correct horse")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(hash), "$"+algorithm+"$"))
+
+			ok, needsRehash, err := hasher.Verify("correct horse", hash)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.False(t, needsRehash)
+
+			ok, _, err = hasher.Verify("wrong horse", hash)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestHashUsesPerHashSalt(t *testing.T) {
+	hasher, err := NewHasher(Argon2id)
+	require.NoError(t, err)
+
+	first, err := hasher.Hash("same password")
+	require.NoError(t, err)
+	second, err := hasher.Hash("same password")
+	require.NoError(t, err)
+
+	assert.False(t, bytes.Equal(first, second))
+}
+
+func TestVerifyNeedsRehash(t *testing.T) {
+	scryptHasher, err := NewHasher(Scrypt)
+	require.NoError(t, err)
+	argonHasher, err := NewHasher(Argon2id)
+	require.NoError(t, err)
+
+	hash, err := scryptHasher.Hash("password123")
+	require.NoError(t, err)
+
+	ok, needsRehash, err := argonHasher.Verify("password123", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash, "hashes from another algorithm should be upgraded")
+
+	weaker := *argonHasher
+	weaker.Argon2.Iterations = 1
+	hash, err = weaker.Hash("password123")
+	require.NoError(t, err)
+
+	ok, needsRehash, err = argonHasher.Verify("password123", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash, "hashes with outdated parameters should be upgraded")
+}
+
+func TestVerifyLegacyHash(t *testing.T) {
+	hasher, err := NewHasher(Argon2id)
+	require.NoError(t, err)
+
+	legacy, err := scrypt.Key([]byte("password123"), nil, 32768, 8, 1, 32)
+	require.NoError(t, err)
+
+	ok, needsRehash, err := hasher.Verify("password123", legacy)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash)
+
+	ok, _, err = hasher.Verify("password124", legacy)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestVerifyMalformedHash(t *testing.T) {
+	hasher, err := NewHasher(Argon2id)
+	require.NoError(t, err)
+
+	_, _, err = hasher.Verify("password", []byte("$argon2id$v=19$garbage"))
+	assert.ErrorIs(t, err, ErrMalformedHash)
+
+	_, _, err = hasher.Verify("password", []byte("$bcrypt$x$y$z"))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+
+	_, err = NewHasher("md5")
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
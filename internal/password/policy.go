@@ -0,0 +1,103 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reasons reported by Policy.Check
+const (
+	ReasonUpper    = "must contain an uppercase letter"
+	ReasonLower    = "must contain a lowercase letter"
+	ReasonDigit    = "must contain a digit"
+	ReasonSymbol   = "must contain a symbol"
+	ReasonUserInfo = "must not contain your name or email address"
+	ReasonBreached = "has appeared in a data breach and must not be used"
+)
+
+// minUserInfoLength keeps very short names from ruling out most passwords
+const minUserInfoLength = 3
+
+// Policy holds the rules new passwords must follow. Breached is optional.
+type Policy struct {
+	MinLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowUserInfo bool
+	Breached         BreachedList
+}
+
+// Check returns a reason for every rule the password breaks, none if it
+// complies. userInfo holds the account's name and email address, which the
+// password may not contain when DisallowUserInfo is set. The local part of
+// an email address is checked on its own too.
+func (p Policy) Check(password string, userInfo ...string) ([]string, error) {
+	var reasons []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		reasons = append(reasons, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		reasons = append(reasons, ReasonUpper)
+	}
+	if p.RequireLower && !lower {
+		reasons = append(reasons, ReasonLower)
+	}
+	if p.RequireDigit && !digit {
+		reasons = append(reasons, ReasonDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		reasons = append(reasons, ReasonSymbol)
+	}
+
+	if p.DisallowUserInfo && containsUserInfo(password, userInfo) {
+		reasons = append(reasons, ReasonUserInfo)
+	}
+
+	if p.Breached != nil {
+		breached, err := IsBreached(p.Breached, password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			reasons = append(reasons, ReasonBreached)
+		}
+	}
+
+	return reasons, nil
+}
+
+func containsUserInfo(password string, userInfo []string) bool {
+	password = strings.ToLower(password)
+	for _, info := range userInfo {
+		info = strings.ToLower(info)
+		parts := []string{info}
+		if local, _, found := strings.Cut(info, "@"); found {
+			parts = append(parts, local)
+		}
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= minUserInfoLength && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
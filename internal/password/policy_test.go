@@ -0,0 +1,80 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := Policy{
+		MinLength:        10,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowUserInfo: true,
+	}
+
+	testCases := []struct {
+		name     string
+		password string
+		expected []string
+	}{
+		{name: "Compliant", password: "Tr0ub4dor&3x"},
+		{name: "Too Short", password: "Ab1!", expected: []string{"must be at least 10 characters long"}},
+		{name: "Missing Classes", password: "lowercase only", expected: []string{ReasonUpper, ReasonDigit, ReasonSymbol}},
+		{name: "Contains Name", password: "Alice-1234567", expected: []string{ReasonUserInfo}},
+		{name: "Contains Email Local Part", password: "Wonder.Land99!", expected: []string{ReasonUserInfo}},
+		{name: "Counts Characters Not Bytes", password: "Ünïcödé1!x", expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reasons, err := policy.Check(tc.password, "alice", "wonder.land@example.com")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, reasons)
+		})
+	}
+}
+
+func TestPolicyIgnoresShortUserInfo(t *testing.T) {
+	policy := Policy{MinLength: 8, DisallowUserInfo: true}
+
+	reasons, err := policy.Check("a long passphrase", "al", "")
+	require.NoError(t, err)
+	assert.Empty(t, reasons)
+}
+
+func TestBreachedFile(t *testing.T) {
+	// SHA-1 of "password" and "123456"
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# breached passwords\n" +
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n" +
+		"7c4a8d09ca3762af61e59520943dc26494f8941b\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	list, err := LoadBreachedFile(path)
+	require.NoError(t, err)
+
+	for password, expected := range map[string]bool{"password": true, "123456": true, "correct horse battery staple": false} {
+		breached, err := IsBreached(list, password)
+		require.NoError(t, err)
+		assert.Equal(t, expected, breached, password)
+	}
+
+	reasons, err := Policy{MinLength: 8, Breached: list}.Check("password")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ReasonBreached}, reasons)
+}
+
+func TestLoadBreachedFileRejectsMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte("not-a-hash:12\n"), 0o600))
+
+	_, err := LoadBreachedFile(path)
+	assert.ErrorContains(t, err, ":1: not a SHA-1 hash")
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	ListByUser(userID uint) ([]models.APIKey, error)
+	FindByHash(keyHash string) (*models.APIKey, error)
+	Revoke(userID, id uint) error
+	TouchLastUsed(id uint, at time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new APIKeyRepository instance
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db}
+}
+
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	if err := r.db.Create(key).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) ListByUser(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) FindByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) Revoke(userID, id uint) error {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrResourceNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(id uint, at time.Time) error {
+	if err := r.db.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"strings"
+	"time"
+
 	"github.com/netf/gofiber-boilerplate/internal/errors"
 	"github.com/netf/gofiber-boilerplate/internal/models"
 	"gorm.io/gorm"
@@ -16,12 +19,10 @@ func NewAuthRepository(db *gorm.DB) *AuthRepository {
 	return &AuthRepository{db: db}
 }
 
-// FindUserByCredentials retrieves a user by their name and hashed password
-func (r *AuthRepository) FindUserByCredentials(name string, hashedPassword []byte) (*models.User, error) {
+// FindUserByName retrieves a user by their username
+func (r *AuthRepository) FindUserByName(name string) (*models.User, error) {
 	var user models.User
-	err := r.db.Select("id, name, email").
-		Where("name = ? AND pass = ?", name, hashedPassword).
-		First(&user).Error
+	err := r.db.Preload("Roles.Permissions").Where("name = ?", name).First(&user).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -33,10 +34,10 @@ func (r *AuthRepository) FindUserByCredentials(name string, hashedPassword []byt
 	return &user, nil
 }
 
-// FindUserByName retrieves a user by their username
-func (r *AuthRepository) FindUserByName(name string) (*models.User, error) {
+// FindUserByEmail retrieves a user by their email address
+func (r *AuthRepository) FindUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("name = ?", name).First(&user).Error
+	err := r.db.Preload("Roles.Permissions").Where("email = ?", email).First(&user).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -48,11 +49,202 @@ func (r *AuthRepository) FindUserByName(name string) (*models.User, error) {
 	return &user, nil
 }
 
-// CreateUser creates a new user in the database
+// CreateUser creates a new user in the database. A name or email address
+// another user has yields errors.ErrUserAlreadyExists.
 func (r *AuthRepository) CreateUser(user *models.User) error {
 	if err := r.db.Create(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errors.ErrUserAlreadyExists
+		}
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the stored password hash of a user, which also
+// satisfies a required password reset
+func (r *AuthRepository) UpdatePassword(userID uint, hashedPassword []byte) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{"pass": hashedPassword, "password_reset_required": false}).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// UpdateName changes the name of a user
+func (r *AuthRepository) UpdateName(userID uint, name string) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("name", name).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// SetPendingEmail stores an email address the user wants to change to
+func (r *AuthRepository) SetPendingEmail(userID uint, email string) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("pending_email", email).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// ConfirmPendingEmail replaces the email address of the user with the pending
+// one, which counts as verified
+func (r *AuthRepository) ConfirmPendingEmail(userID uint, verifiedAt time.Time) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ? AND pending_email <> ''", userID).
+		Updates(map[string]interface{}{
+			"email":             gorm.Expr("pending_email"),
+			"pending_email":     "",
+			"email_verified_at": verifiedAt,
+		}).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// ListUsers returns a page of users whose name or email contains query,
+// together with the total number of matching users
+func (r *AuthRepository) ListUsers(query string, page, pageSize int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	db := r.db.Model(&models.User{})
+	if query != "" {
+		pattern := "%" + escapeLike(query) + "%"
+		db = db.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	err := db.Preload("Roles").
+		Order("id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	return users, total, nil
+}
+
+// SetDisabled disables the user at disabledAt, or enables them if it is nil
+func (r *AuthRepository) SetDisabled(userID uint, disabledAt *time.Time) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("disabled_at", disabledAt).Error
+	if err != nil {
 		return errors.ErrDatabaseOperation
 	}
 
 	return nil
 }
+
+// SetPasswordResetRequired sets whether the user must reset their password before logging in
+func (r *AuthRepository) SetPasswordResetRequired(userID uint, required bool) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("password_reset_required", required).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// ReplaceRoles replaces the roles assigned to the user
+func (r *AuthRepository) ReplaceRoles(user *models.User, roles []models.Role) error {
+	if err := r.db.Model(user).Association("Roles").Replace(roles); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// DeleteUser soft-deletes a user
+func (r *AuthRepository) DeleteUser(userID uint) error {
+	result := r.db.Delete(&models.User{}, userID)
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// MarkEmailVerified records that the user has verified their email address.
+// An earlier verification time is kept.
+func (r *AuthRepository) MarkEmailVerified(userID uint, verifiedAt time.Time) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", verifiedAt).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// FindUserByID retrieves a user by their ID
+func (r *AuthRepository) FindUserByID(id uint) (*models.User, error) {
+	var user models.User
+	err := r.db.Preload("Roles.Permissions").First(&user, id).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &user, nil
+}
+
+// UpdateTOTP stores the two-factor authentication settings of a user
+func (r *AuthRepository) UpdateTOTP(user *models.User) error {
+	err := r.db.Model(user).
+		Select("totp_enabled", "totp_secret", "totp_pending_secret", "totp_last_step").
+		Updates(user).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// AdvanceTOTPStep records step as the last accepted TOTP time step. It
+// returns false if the same or a later step was already accepted, which
+// means the code is being replayed.
+func (r *AuthRepository) AdvanceTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, errors.ErrDatabaseOperation
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/netf/gofiber-boilerplate/internal/db/dbtest"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRegisterAfterDelete(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := NewAuthRepository(db)
+
+	create := func(name, email string) (*models.User, error) {
+		user := &models.User{Name: name, Email: email, Pass: []byte("x")}
+		// A savepoint keeps the test's transaction usable after a violation
+		return user, db.Transaction(func(tx *gorm.DB) error {
+			return NewAuthRepository(tx).CreateUser(user)
+		})
+	}
+
+	user, err := create("jane", "jane@example.com")
+	require.NoError(t, err)
+	_, err = create("jane", "other@example.com")
+	assert.ErrorIs(t, err, errors.ErrUserAlreadyExists)
+	_, err = create("other", "jane@example.com")
+	assert.ErrorIs(t, err, errors.ErrUserAlreadyExists)
+
+	// A deleted user's name and email address are free again
+	require.NoError(t, repo.DeleteUser(user.ID))
+	registered, err := create("jane", "jane@example.com")
+	require.NoError(t, err)
+	found, err := repo.FindUserByEmail("jane@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, registered.ID, found.ID)
+}
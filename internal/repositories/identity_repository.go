@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// IdentityRepository handles database operations for the external login
+// identities linked to users
+type IdentityRepository interface {
+	Find(provider, subject string) (*models.UserIdentity, error)
+	Create(identity *models.UserIdentity) error
+	CreateWithUser(user *models.User, identity *models.UserIdentity) error
+	ListForUser(userID uint) ([]models.UserIdentity, error)
+	Delete(userID, id uint) error
+	TouchLastLogin(id uint, at time.Time) error
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository creates a new IdentityRepository instance
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db}
+}
+
+// Find returns the identity with the given subject at provider, or
+// errors.ErrResourceNotFound
+func (r *identityRepository) Find(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) Create(identity *models.UserIdentity) error {
+	if err := r.db.Create(identity).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// CreateWithUser creates a new user together with its first identity
+func (r *identityRepository) CreateWithUser(user *models.User, identity *models.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		identity.UserID = user.ID
+		if err := tx.Create(identity).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		return nil
+	})
+}
+
+func (r *identityRepository) ListForUser(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&identities).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return identities, nil
+}
+
+// Delete unlinks one of the user's identities. Unknown identities and
+// identities of other users yield errors.ErrResourceNotFound.
+func (r *identityRepository) Delete(userID, id uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.UserIdentity{})
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrResourceNotFound
+	}
+	return nil
+}
+
+func (r *identityRepository) TouchLastLogin(id uint, at time.Time) error {
+	err := r.db.Model(&models.UserIdentity{}).Where("id = ?", id).Update("last_login_at", at).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// ImpersonationLogRepository handles database operations for the audit trail
+// of impersonation
+type ImpersonationLogRepository interface {
+	Create(entry *models.ImpersonationLog) error
+	List(userID uint, page, pageSize int) ([]models.ImpersonationLog, int64, error)
+}
+
+type impersonationLogRepository struct {
+	db *gorm.DB
+}
+
+// NewImpersonationLogRepository creates a new ImpersonationLogRepository instance
+func NewImpersonationLogRepository(db *gorm.DB) ImpersonationLogRepository {
+	return &impersonationLogRepository{db}
+}
+
+func (r *impersonationLogRepository) Create(entry *models.ImpersonationLog) error {
+	if err := r.db.Create(entry).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// List returns a page of entries, newest first, together with the total
+// number of entries. A non-zero userID limits it to the impersonations of
+// that user.
+func (r *impersonationLogRepository) List(userID uint, page, pageSize int) ([]models.ImpersonationLog, int64, error) {
+	var entries []models.ImpersonationLog
+	var total int64
+
+	db := r.db.Model(&models.ImpersonationLog{})
+	if userID != 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	err := db.Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&entries).Error
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	return entries, total, nil
+}
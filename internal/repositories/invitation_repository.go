@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// InvitationRepository handles database operations for organization invitations
+type InvitationRepository interface {
+	Create(invitation *models.OrganizationInvitation) error
+	FindPending(tokenHash string) (*models.OrganizationInvitation, error)
+	ListPending(organizationID uint) ([]models.OrganizationInvitation, error)
+	Accept(invitation *models.OrganizationInvitation, member *models.OrganizationMember) error
+	Decline(id uint) error
+	Delete(organizationID, id uint) error
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+// NewInvitationRepository creates a new InvitationRepository instance
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db}
+}
+
+func (r *invitationRepository) Create(invitation *models.OrganizationInvitation) error {
+	if err := r.db.Omit("Organization").Create(invitation).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// FindPending returns the invitation with the token hash if it has been
+// neither accepted nor declined and has not expired. Other invitations yield
+// errors.ErrInvalidToken.
+func (r *invitationRepository) FindPending(tokenHash string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := r.db.Preload("Organization").
+		Where("token_hash = ? AND accepted_at IS NULL AND declined_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&invitation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &invitation, nil
+}
+
+// ListPending returns the organization's open invitations, newest first
+func (r *invitationRepository) ListPending(organizationID uint) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := r.db.Where("organization_id = ? AND accepted_at IS NULL AND declined_at IS NULL AND expires_at > ?", organizationID, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return invitations, nil
+}
+
+// Accept marks the invitation as accepted and adds the member in one
+// transaction. Of two concurrent calls only one succeeds; the other yields
+// errors.ErrInvalidToken. An existing member yields errors.ErrUserAlreadyExists.
+func (r *invitationRepository) Accept(invitation *models.OrganizationInvitation, member *models.OrganizationMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.OrganizationInvitation{}).
+			Where("id = ? AND accepted_at IS NULL AND declined_at IS NULL", invitation.ID).
+			Update("accepted_at", now)
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrInvalidToken
+		}
+
+		var count int64
+		err := tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", member.OrganizationID, member.UserID).
+			Count(&count).Error
+		if err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if count > 0 {
+			return errors.ErrUserAlreadyExists
+		}
+
+		if err := tx.Omit("User").Create(member).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		invitation.AcceptedAt = &now
+		return nil
+	})
+}
+
+func (r *invitationRepository) Decline(id uint) error {
+	result := r.db.Model(&models.OrganizationInvitation{}).
+		Where("id = ? AND accepted_at IS NULL AND declined_at IS NULL", id).
+		Update("declined_at", time.Now())
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrInvalidToken
+	}
+	return nil
+}
+
+// Delete withdraws an invitation of the organization
+func (r *invitationRepository) Delete(organizationID, id uint) error {
+	result := r.db.Where("organization_id = ?", organizationID).Delete(&models.OrganizationInvitation{}, id)
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrResourceNotFound
+	}
+	return nil
+}
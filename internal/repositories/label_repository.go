@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// LabelRepository handles database operations for todo labels. Like
+// TodoRepository every method is scoped to a workspace.
+type LabelRepository interface {
+	Create(label *models.Label) error
+	FindByID(ws models.Workspace, id uint) (*models.Label, error)
+	FindByIDs(ws models.Workspace, ids []uint) ([]models.Label, error)
+	FindByName(ws models.Workspace, name string) (*models.Label, error)
+	List(ws models.Workspace) ([]models.Label, error)
+	Update(label *models.Label) error
+	Delete(ws models.Workspace, id uint) error
+}
+
+type labelRepository struct {
+	db *gorm.DB
+}
+
+// NewLabelRepository creates a new LabelRepository instance
+func NewLabelRepository(db *gorm.DB) LabelRepository {
+	return &labelRepository{db}
+}
+
+// Create stores the label. A label with the same name in the workspace,
+// compared case-insensitively, yields errors.ErrLabelExists.
+func (r *labelRepository) Create(label *models.Label) error {
+	if err := r.db.Create(label).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errors.ErrLabelExists
+		}
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *labelRepository) FindByID(ws models.Workspace, id uint) (*models.Label, error) {
+	var label models.Label
+	err := inWorkspace(r.db, ws).First(&label, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &label, nil
+}
+
+// FindByIDs returns the labels of the workspace with the given IDs. IDs of
+// labels outside the workspace are skipped.
+func (r *labelRepository) FindByIDs(ws models.Workspace, ids []uint) ([]models.Label, error) {
+	labels := []models.Label{}
+	if len(ids) == 0 {
+		return labels, nil
+	}
+	if err := inWorkspace(r.db, ws).Where("id IN ?", ids).Find(&labels).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return labels, nil
+}
+
+// FindByName returns the label of the workspace with the name, compared
+// case-insensitively
+func (r *labelRepository) FindByName(ws models.Workspace, name string) (*models.Label, error) {
+	var label models.Label
+	err := inWorkspace(r.db, ws).Where("LOWER(name) = ?", strings.ToLower(name)).First(&label).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &label, nil
+}
+
+func (r *labelRepository) List(ws models.Workspace) ([]models.Label, error) {
+	var labels []models.Label
+	if err := inWorkspace(r.db, ws).Order("name").Find(&labels).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return labels, nil
+}
+
+// Update stores the name and color of the label. Like Create it yields
+// errors.ErrLabelExists if another label of the workspace has the name.
+func (r *labelRepository) Update(label *models.Label) error {
+	err := r.db.Model(label).Select("Name", "Color").Updates(label).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errors.ErrLabelExists
+		}
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// Delete deletes the label and detaches it from all todos
+func (r *labelRepository) Delete(ws models.Workspace, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM todo_labels WHERE label_id = ?", id).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+
+		result := inWorkspace(tx, ws).Delete(&models.Label{}, id)
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrResourceNotFound
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/netf/gofiber-boilerplate/internal/db/dbtest"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestLabelNamesAreUnique(t *testing.T) {
+	db := dbtest.Open(t)
+	organizationID := uint(7)
+	organization := models.Workspace{UserID: 1, OrganizationID: organizationID}
+	createLabels(t, db, personal, "Urgent")
+
+	create := func(label models.Label) error {
+		// A savepoint keeps the test's transaction usable after the violation
+		return db.Transaction(func(tx *gorm.DB) error {
+			return NewLabelRepository(tx).Create(&label)
+		})
+	}
+
+	err := create(models.Label{UserID: personal.UserID, Name: "urgent"})
+	assert.ErrorIs(t, err, errors.ErrLabelExists)
+
+	// The name is free in other workspaces
+	assert.NoError(t, create(models.Label{UserID: 2, Name: "urgent"}))
+	assert.NoError(t, create(models.Label{UserID: organization.UserID, OrganizationID: &organizationID, Name: "urgent"}))
+	err = create(models.Label{UserID: 3, OrganizationID: &organizationID, Name: "URGENT"})
+	assert.ErrorIs(t, err, errors.ErrLabelExists)
+
+	// Renaming a label to a taken name fails the same way
+	home := createLabels(t, db, personal, "home")["home"]
+	home.Name = "URGENT"
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return NewLabelRepository(tx).Update(&home)
+	})
+	assert.ErrorIs(t, err, errors.ErrLabelExists)
+}
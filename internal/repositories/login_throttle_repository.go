@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleRepository handles database operations for failed login counters
+type LoginThrottleRepository interface {
+	Find(subjects ...string) ([]models.LoginThrottle, error)
+	RecordFailure(subject string, now time.Time, resetBefore time.Time) (*models.LoginThrottle, error)
+	Lock(subject string, until time.Time) error
+	Reset(subject string) error
+}
+
+type loginThrottleRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginThrottleRepository creates a new LoginThrottleRepository instance
+func NewLoginThrottleRepository(db *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db}
+}
+
+func (r *loginThrottleRepository) Find(subjects ...string) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	if err := r.db.Where("subject IN ?", subjects).Find(&throttles).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return throttles, nil
+}
+
+// RecordFailure atomically increments the failure counter of subject and
+// returns the updated row. The count starts over if neither the last failure
+// nor the end of the last lockout is later than resetBefore.
+func (r *loginThrottleRepository) RecordFailure(subject string, now time.Time, resetBefore time.Time) (*models.LoginThrottle, error) {
+	throttle := &models.LoginThrottle{Subject: subject, Failures: 1, LastFailureAt: now}
+	err := r.db.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "subject"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures": gorm.Expr(
+					"CASE WHEN GREATEST(login_throttles.last_failure_at, COALESCE(login_throttles.locked_until, login_throttles.last_failure_at)) < ? THEN 1 ELSE login_throttles.failures + 1 END",
+					resetBefore,
+				),
+				"last_failure_at": now,
+				"updated_at":      now,
+			}),
+		},
+		clause.Returning{},
+	).Create(throttle).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return throttle, nil
+}
+
+func (r *loginThrottleRepository) Lock(subject string, until time.Time) error {
+	err := r.db.Model(&models.LoginThrottle{}).
+		Where("subject = ?", subject).
+		Update("locked_until", until).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *loginThrottleRepository) Reset(subject string) error {
+	if err := r.db.Where("subject = ?", subject).Delete(&models.LoginThrottle{}).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// OAuthClientRepository handles database operations for the third-party
+// applications registered with the OAuth authorization server
+type OAuthClientRepository interface {
+	Create(client *models.OAuthClient) error
+	FindByClientID(clientID string) (*models.OAuthClient, error)
+	List() ([]models.OAuthClient, error)
+	Revoke(id uint) error
+}
+
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthClientRepository creates a new OAuthClientRepository instance
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &oauthClientRepository{db}
+}
+
+func (r *oauthClientRepository) Create(client *models.OAuthClient) error {
+	if err := r.db.Create(client).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// FindByClientID returns the client with the given client ID, including
+// revoked clients, or errors.ErrResourceNotFound
+func (r *oauthClientRepository) FindByClientID(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) List() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	if err := r.db.Order("id").Find(&clients).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return clients, nil
+}
+
+// Revoke marks a client as revoked. Unknown and already revoked clients
+// yield errors.ErrResourceNotFound.
+func (r *oauthClientRepository) Revoke(id uint) error {
+	result := r.db.Model(&models.OAuthClient{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrResourceNotFound
+	}
+	return nil
+}
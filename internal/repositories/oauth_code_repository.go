@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// OAuthCodeRepository handles database operations for OAuth authorization codes
+type OAuthCodeRepository interface {
+	Create(code *models.OAuthAuthorizationCode) error
+	Consume(codeHash string) (*models.OAuthAuthorizationCode, error)
+}
+
+type oauthCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthCodeRepository creates a new OAuthCodeRepository instance
+func NewOAuthCodeRepository(db *gorm.DB) OAuthCodeRepository {
+	return &oauthCodeRepository{db}
+}
+
+func (r *oauthCodeRepository) Create(code *models.OAuthAuthorizationCode) error {
+	if err := r.db.Create(code).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// Consume marks an unused, unexpired code as used and returns it. Unknown,
+// used and expired codes yield errors.ErrInvalidToken, and of two concurrent
+// calls with the same code only one succeeds.
+func (r *oauthCodeRepository) Consume(codeHash string) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		if err := tx.Where("code_hash = ?", codeHash).First(&code).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.ErrInvalidToken
+			}
+			return errors.ErrDatabaseOperation
+		}
+		if code.UsedAt != nil || now.After(code.ExpiresAt) {
+			return errors.ErrInvalidToken
+		}
+
+		result := tx.Model(&models.OAuthAuthorizationCode{}).
+			Where("id = ? AND used_at IS NULL", code.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrInvalidToken
+		}
+		code.UsedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
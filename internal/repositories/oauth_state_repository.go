@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OAuthStateRepository handles database operations for external logins in
+// progress
+type OAuthStateRepository interface {
+	Create(state *models.OAuthState) error
+	Consume(stateHash string) (*models.OAuthState, error)
+	DeleteExpired(now time.Time) error
+}
+
+type oauthStateRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthStateRepository creates a new OAuthStateRepository instance
+func NewOAuthStateRepository(db *gorm.DB) OAuthStateRepository {
+	return &oauthStateRepository{db}
+}
+
+func (r *oauthStateRepository) Create(state *models.OAuthState) error {
+	if err := r.db.Create(state).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// Consume deletes the state with the given hash and returns it. Unknown and
+// expired states yield errors.ErrInvalidToken, and of two concurrent calls
+// with the same state only one succeeds.
+func (r *oauthStateRepository) Consume(stateHash string) (*models.OAuthState, error) {
+	var states []models.OAuthState
+	err := r.db.Clauses(clause.Returning{}).
+		Where("state_hash = ?", stateHash).
+		Delete(&states).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	if len(states) == 0 || time.Now().After(states[0].ExpiresAt) {
+		return nil, errors.ErrInvalidToken
+	}
+	return &states[0], nil
+}
+
+func (r *oauthStateRepository) DeleteExpired(now time.Time) error {
+	if err := r.db.Where("expires_at <= ?", now).Delete(&models.OAuthState{}).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
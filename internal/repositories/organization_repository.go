@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrganizationRepository handles database operations for organizations and
+// their members
+type OrganizationRepository interface {
+	Create(organization *models.Organization, owner uint) error
+	FindByID(id uint) (*models.Organization, error)
+	ListForUser(userID uint) ([]models.Organization, error)
+	Delete(id uint) error
+	FindMember(organizationID, userID uint) (*models.OrganizationMember, error)
+	ListMembers(organizationID uint) ([]models.OrganizationMember, error)
+	AddMember(member *models.OrganizationMember) error
+	UpdateMemberRole(organizationID, userID uint, role string) error
+	RemoveMember(organizationID, userID uint) error
+	CountOwners(organizationID uint) (int64, error)
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+// NewOrganizationRepository creates a new OrganizationRepository instance
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db}
+}
+
+// Create stores the organization and makes the user with the ID owner its first owner
+func (r *organizationRepository) Create(organization *models.Organization, owner uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return tx.Omit("User").Create(&models.OrganizationMember{
+			OrganizationID: organization.ID,
+			UserID:         owner,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	organization.Role = models.OrgRoleOwner
+	return nil
+}
+
+func (r *organizationRepository) FindByID(id uint) (*models.Organization, error) {
+	var organization models.Organization
+	if err := r.db.First(&organization, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &organization, nil
+}
+
+// ListForUser returns the organizations the user is a member of with the user's role in each
+func (r *organizationRepository) ListForUser(userID uint) ([]models.Organization, error) {
+	var organizations []models.Organization
+	err := r.db.Model(&models.Organization{}).
+		Select("organizations.*, organization_members.role AS role").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userID).
+		Order("organizations.name").
+		Scan(&organizations).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return organizations, nil
+}
+
+// Delete removes the organization with its members and invitations. Its
+// todos are deleted too.
+func (r *organizationRepository) Delete(id uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Organization{}, id)
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrResourceNotFound
+		}
+
+		err := tx.Exec("DELETE FROM todo_labels WHERE label_id IN (SELECT id FROM labels WHERE organization_id = ?)", id).Error
+		if err != nil {
+			return errors.ErrDatabaseOperation
+		}
+
+		for _, model := range []interface{}{&models.OrganizationMember{}, &models.OrganizationInvitation{}, &models.Todo{}, &models.Label{}, &models.Project{}} {
+			if err := tx.Where("organization_id = ?", id).Delete(model).Error; err != nil {
+				return errors.ErrDatabaseOperation
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// FindMember returns the user's membership in the organization, or
+// errors.ErrResourceNotFound if the user is not a member
+func (r *organizationRepository) FindMember(organizationID, userID uint) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &member, nil
+}
+
+func (r *organizationRepository) ListMembers(organizationID uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := r.db.Preload("User").
+		Where("organization_id = ?", organizationID).
+		Order("created_at").
+		Find(&members).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return members, nil
+}
+
+// AddMember stores the membership. Adding an existing member yields
+// errors.ErrUserAlreadyExists.
+func (r *organizationRepository) AddMember(member *models.OrganizationMember) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Omit("User").Create(member)
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrUserAlreadyExists
+	}
+	return nil
+}
+
+func (r *organizationRepository) UpdateMemberRole(organizationID, userID uint, role string) error {
+	result := r.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		Update("role", role)
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrResourceNotFound
+	}
+	return nil
+}
+
+func (r *organizationRepository) RemoveMember(organizationID, userID uint) error {
+	result := r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		Delete(&models.OrganizationMember{})
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrResourceNotFound
+	}
+	return nil
+}
+
+func (r *organizationRepository) CountOwners(organizationID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", organizationID, models.OrgRoleOwner).
+		Count(&count).Error
+	if err != nil {
+		return 0, errors.ErrDatabaseOperation
+	}
+	return count, nil
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// PasswordHistoryRepository handles database operations for the hashes of
+// users' previous passwords
+type PasswordHistoryRepository interface {
+	Add(entry *models.PasswordHistory, keep int) error
+	Recent(userID uint, limit int) ([]models.PasswordHistory, error)
+}
+
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordHistoryRepository creates a new PasswordHistoryRepository instance
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db}
+}
+
+// Add stores the entry and deletes all but the keep most recent entries of the user
+func (r *passwordHistoryRepository) Add(entry *models.PasswordHistory, keep int) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+
+		kept := tx.Model(&models.PasswordHistory{}).
+			Select("id").
+			Where("user_id = ?", entry.UserID).
+			Order("id DESC").
+			Limit(keep)
+		return tx.Where("user_id = ? AND id NOT IN (?)", entry.UserID, kept).
+			Delete(&models.PasswordHistory{}).Error
+	})
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// Recent returns the user's limit most recent entries, newest first
+func (r *passwordHistoryRepository) Recent(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return entries, nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// ProjectRepository handles database operations for projects. Like
+// TodoRepository every method is scoped to a workspace, and to the
+// workspace's projects if it is restricted.
+type ProjectRepository interface {
+	Create(project *models.Project) error
+	FindByID(ws models.Workspace, id uint) (*models.Project, error)
+	List(ws models.Workspace, includeArchived bool) ([]models.Project, error)
+	Update(project *models.Project) error
+	Delete(ws models.Workspace, id uint) error
+	CountTodos(ws models.Workspace, ids []uint) (map[uint]models.ProjectCounts, error)
+}
+
+type projectRepository struct {
+	db *gorm.DB
+}
+
+// NewProjectRepository creates a new ProjectRepository instance
+func NewProjectRepository(db *gorm.DB) ProjectRepository {
+	return &projectRepository{db}
+}
+
+// projectsInWorkspace restricts a query to the projects of the workspace
+func projectsInWorkspace(db *gorm.DB, ws models.Workspace) *gorm.DB {
+	db = inWorkspace(db, ws)
+	if ws.Restricted() {
+		db = db.Where("id IN ?", ws.ProjectIDs)
+	}
+	return db
+}
+
+func (r *projectRepository) Create(project *models.Project) error {
+	if err := r.db.Create(project).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *projectRepository) FindByID(ws models.Workspace, id uint) (*models.Project, error) {
+	var project models.Project
+	err := projectsInWorkspace(r.db, ws).First(&project, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &project, nil
+}
+
+// List returns the projects of the workspace by name, leaving out archived
+// projects unless includeArchived is set
+func (r *projectRepository) List(ws models.Workspace, includeArchived bool) ([]models.Project, error) {
+	projects := []models.Project{}
+	query := projectsInWorkspace(r.db, ws)
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+	if err := query.Order("name").Find(&projects).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return projects, nil
+}
+
+// Update stores the name, description, color and archived flag of the project
+func (r *projectRepository) Update(project *models.Project) error {
+	err := r.db.Model(project).Select("Name", "Description", "Color", "Archived").Updates(project).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// Delete deletes the project. Its todos are kept without a project.
+func (r *projectRepository) Delete(ws models.Workspace, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := projectsInWorkspace(tx, ws).Delete(&models.Project{}, id)
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrResourceNotFound
+		}
+
+		err := tx.Model(&models.Todo{}).Where("project_id = ?", id).UpdateColumn("project_id", nil).Error
+		if err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		return nil
+	})
+}
+
+// CountTodos counts the todos of each of the projects with the given IDs
+func (r *projectRepository) CountTodos(ws models.Workspace, ids []uint) (map[uint]models.ProjectCounts, error) {
+	counts := make(map[uint]models.ProjectCounts, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ProjectID uint
+		Status    string
+		Count     int64
+	}
+	err := todosInWorkspace(r.db.Model(&models.Todo{}), ws).
+		Select("project_id, status, COUNT(*) AS count").
+		Where("project_id IN ?", ids).
+		Group("project_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	for _, row := range rows {
+		c := counts[row.ProjectID]
+		c.Total += row.Count
+		switch row.Status {
+		case models.TodoStatusDone:
+			c.Done += row.Count
+		case models.TodoStatusCancelled:
+		default:
+			c.Open += row.Count
+		}
+		counts[row.ProjectID] = c
+	}
+	return counts, nil
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/netf/gofiber-boilerplate/internal/db/dbtest"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountTodos(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := NewProjectRepository(db)
+
+	var projects [3]models.Project
+	for i, name := range []string{"Busy", "Empty", "Other"} {
+		projects[i] = models.Project{UserID: personal.UserID, Name: name}
+		require.NoError(t, repo.Create(&projects[i]))
+	}
+	busy, empty, other := projects[0].ID, projects[1].ID, projects[2].ID
+
+	for _, status := range []string{models.TodoStatusTodo, models.TodoStatusInProgress, models.TodoStatusBlocked, models.TodoStatusDone, models.TodoStatusDone, models.TodoStatusCancelled} {
+		todo := models.Todo{UserID: personal.UserID, Title: "Busy todo", Status: status, ProjectID: &busy}
+		require.NoError(t, NewTodoRepository(db).Create(&todo))
+	}
+	createTodo(t, db, personal, "Other todo", &other)
+	// Another user's todo in the project does not count
+	createTodo(t, db, models.Workspace{UserID: 2}, "Foreign todo", &busy)
+
+	counts, err := repo.CountTodos(personal, []uint{busy, empty})
+	require.NoError(t, err)
+	assert.Equal(t, models.ProjectCounts{Open: 3, Done: 2, Total: 6}, counts[busy])
+	assert.Equal(t, models.ProjectCounts{}, counts[empty])
+	assert.NotContains(t, counts, other)
+
+	counts, err = repo.CountTodos(personal, nil)
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+}
+
+func TestListProjects(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := NewProjectRepository(db)
+
+	for _, project := range []models.Project{
+		{UserID: personal.UserID, Name: "Beta"},
+		{UserID: personal.UserID, Name: "Alpha", Archived: true},
+		{UserID: 2, Name: "Foreign"},
+	} {
+		require.NoError(t, repo.Create(&project))
+	}
+
+	projects, err := repo.List(personal, false)
+	require.NoError(t, err)
+	require.Len(t, projects, 1)
+	assert.Equal(t, "Beta", projects[0].Name)
+
+	projects, err = repo.List(personal, true)
+	require.NoError(t, err)
+	require.Len(t, projects, 2)
+	assert.Equal(t, "Alpha", projects[0].Name)
+	assert.Equal(t, "Beta", projects[1].Name)
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeRepository handles database operations for two-factor recovery codes
+type RecoveryCodeRepository interface {
+	Replace(userID uint, codeHashes []string) error
+	Use(userID uint, codeHash string) error
+	DeleteForUser(userID uint) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository creates a new RecoveryCodeRepository instance
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db}
+}
+
+// Replace discards all codes of the user and stores the new ones
+func (r *recoveryCodeRepository) Replace(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+
+		codes := make([]models.RecoveryCode, len(codeHashes))
+		for i, codeHash := range codeHashes {
+			codes[i] = models.RecoveryCode{UserID: userID, CodeHash: codeHash}
+		}
+		if err := tx.Create(&codes).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		return nil
+	})
+}
+
+// Use marks an unused code as used, returning errors.ErrInvalidMFACode if
+// there is no such code
+func (r *recoveryCodeRepository) Use(userID uint, codeHash string) error {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (r *recoveryCodeRepository) DeleteForUser(userID uint) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
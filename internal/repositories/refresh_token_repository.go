@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenRepository handles database operations for refresh tokens
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByHash(tokenHash string) (*models.RefreshToken, error)
+	Rotate(old *models.RefreshToken, next *models.RefreshToken) error
+	RevokeFamily(familyID string) error
+	RevokeAllForUser(userID uint) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository creates a new RefreshTokenRepository instance
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db}
+}
+
+func (r *refreshTokenRepository) Create(token *models.RefreshToken) error {
+	if err := r.db.Create(token).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) FindByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &token, nil
+}
+
+// Rotate stores next and marks old as replaced by it. If old has already been
+// revoked, for example by a concurrent refresh, nothing is written and
+// errors.ErrTokenReused is returned.
+func (r *refreshTokenRepository) Rotate(old *models.RefreshToken, next *models.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", old.ID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": next.ID})
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrTokenReused
+		}
+		return nil
+	})
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	err := r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(userID uint) error {
+	err := r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationRepository handles database operations for revoked access tokens
+type RevocationRepository interface {
+	RevokeToken(token *models.RevokedToken) error
+	RevokeAllForUser(userID uint, before time.Time) error
+	ListRevokedTokensSince(since time.Time) ([]models.RevokedToken, error)
+	ListUserRevocationsSince(since time.Time) ([]models.UserTokenRevocation, error)
+	DeleteExpired(now time.Time) error
+}
+
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+// NewRevocationRepository creates a new RevocationRepository instance
+func NewRevocationRepository(db *gorm.DB) RevocationRepository {
+	return &revocationRepository{db}
+}
+
+func (r *revocationRepository) RevokeToken(token *models.RevokedToken) error {
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *revocationRepository) RevokeAllForUser(userID uint, before time.Time) error {
+	revocation := &models.UserTokenRevocation{UserID: userID, RevokedBefore: before}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "updated_at"}),
+	}).Create(revocation).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+func (r *revocationRepository) ListRevokedTokensSince(since time.Time) ([]models.RevokedToken, error) {
+	var tokens []models.RevokedToken
+	if err := r.db.Where("created_at >= ?", since).Find(&tokens).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return tokens, nil
+}
+
+func (r *revocationRepository) ListUserRevocationsSince(since time.Time) ([]models.UserTokenRevocation, error) {
+	var revocations []models.UserTokenRevocation
+	if err := r.db.Where("updated_at >= ?", since).Find(&revocations).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return revocations, nil
+}
+
+func (r *revocationRepository) DeleteExpired(now time.Time) error {
+	if err := r.db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
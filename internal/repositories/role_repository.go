@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// RoleRepository handles database operations for roles and their permissions
+type RoleRepository interface {
+	FindByName(name string) (*models.Role, error)
+	FindByNames(names []string) ([]models.Role, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new RoleRepository instance
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db}
+}
+
+func (r *roleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &role, nil
+}
+
+// FindByNames returns the roles with the given names, or errors.ErrResourceNotFound if any is missing
+func (r *roleRepository) FindByNames(names []string) ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, errors.ErrResourceNotFound
+		}
+	}
+	return roles, nil
+}
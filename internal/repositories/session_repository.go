@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// SessionRepository handles database operations for login sessions
+type SessionRepository interface {
+	Create(session *models.Session) error
+	ListActive(userID uint, now time.Time) ([]models.Session, error)
+	Find(id string) (*models.Session, error)
+	Touch(id string, lastSeenAt time.Time, expiresAt time.Time) error
+	SetOrganization(userID uint, id string, organizationID *uint) error
+	ClearOrganization(organizationID uint, userID uint) error
+	Revoke(userID uint, id string) (*models.Session, error)
+	RevokeAllForUser(userID uint) error
+	ListRevokedSince(since time.Time, now time.Time) ([]models.Session, error)
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new SessionRepository instance
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db}
+}
+
+func (r *sessionRepository) Create(session *models.Session) error {
+	if err := r.db.Create(session).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// ListActive returns the user's sessions that are neither revoked nor expired, most recently used first
+func (r *sessionRepository) ListActive(userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) Find(id string) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.First(&session, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrResourceNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) Touch(id string, lastSeenAt time.Time, expiresAt time.Time) error {
+	err := r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"last_seen_at": lastSeenAt, "expires_at": expiresAt}).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// SetOrganization sets the active organization of one of the user's sessions
+// that is not revoked, nil for the personal space
+func (r *sessionRepository) SetOrganization(userID uint, id string, organizationID *uint) error {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("organization_id", organizationID)
+	if result.Error != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if result.RowsAffected == 0 {
+		return errors.ErrResourceNotFound
+	}
+	return nil
+}
+
+// ClearOrganization switches the user's sessions acting in the organization
+// back to the personal space
+func (r *sessionRepository) ClearOrganization(organizationID uint, userID uint) error {
+	err := r.db.Model(&models.Session{}).
+		Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		Update("organization_id", nil).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// Revoke marks one of the user's sessions as revoked and returns it. Unknown
+// sessions, sessions of other users and sessions that are already revoked
+// yield errors.ErrResourceNotFound.
+func (r *sessionRepository) Revoke(userID uint, id string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrResourceNotFound
+		}
+		if err := tx.First(&session, "id = ?", id).Error; err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) RevokeAllForUser(userID uint) error {
+	err := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// ListRevokedSince returns sessions revoked at or after since that have not expired yet
+func (r *sessionRepository) ListRevokedSince(since time.Time, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	if err := r.db.Where("revoked_at >= ? AND expires_at > ?", since, now).Find(&sessions).Error; err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	return sessions, nil
+}
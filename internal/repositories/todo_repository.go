@@ -1,17 +1,25 @@
 package repositories
 
 import (
+	"strings"
+
 	"github.com/netf/gofiber-boilerplate/internal/models"
 
 	"gorm.io/gorm"
 )
 
+// TodoRepository handles database operations for todos. Every method is
+// scoped to a workspace: the personal todos of a user or the todos of an
+// organization, limited to the workspace's projects if it is restricted.
+// Rows outside the workspace are reported as gorm.ErrRecordNotFound.
 type TodoRepository interface {
 	Create(todo *models.Todo) error
-	GetByID(id uint) (*models.Todo, error)
-	Update(todo *models.Todo) error
-	Delete(id uint) error
-	List(page, pageSize int) ([]models.Todo, int64, error)
+	GetByID(ws models.Workspace, id uint) (*models.Todo, error)
+	Update(ws models.Workspace, todo *models.Todo) error
+	Save(ws models.Workspace, todo *models.Todo, subtaskIDs []uint, next *models.Todo) error
+	Delete(ws models.Workspace, id uint) error
+	Subtree(ws models.Workspace, id uint) ([]models.Todo, error)
+	List(ws models.Workspace, filter models.TodoFilter, page, pageSize int) ([]models.Todo, int64, error)
 }
 
 type todoRepository struct {
@@ -22,35 +30,188 @@ func NewTodoRepository(db *gorm.DB) TodoRepository {
 	return &todoRepository{db}
 }
 
+// inWorkspace restricts a query to the rows of the workspace
+func inWorkspace(db *gorm.DB, ws models.Workspace) *gorm.DB {
+	if ws.OrganizationID != 0 {
+		return db.Where("organization_id = ?", ws.OrganizationID)
+	}
+	return db.Where("organization_id IS NULL AND user_id = ?", ws.UserID)
+}
+
+// todosInWorkspace restricts a query to the todos of the workspace, and to
+// those in its projects if the workspace is restricted to some
+func todosInWorkspace(db *gorm.DB, ws models.Workspace) *gorm.DB {
+	db = inWorkspace(db, ws)
+	if ws.Restricted() {
+		db = db.Where("project_id IN ?", ws.ProjectIDs)
+	}
+	return db
+}
+
+// Create stores the todo and attaches its Labels, which must already exist
 func (r *todoRepository) Create(todo *models.Todo) error {
-	return r.db.Create(todo).Error
+	return r.db.Omit("Labels.*").Create(todo).Error
 }
 
-func (r *todoRepository) GetByID(id uint) (*models.Todo, error) {
+func (r *todoRepository) GetByID(ws models.Workspace, id uint) (*models.Todo, error) {
 	var todo models.Todo
-	err := r.db.First(&todo, id).Error
+	err := todosInWorkspace(r.db, ws).Preload("Labels").First(&todo, id).Error
 	return &todo, err
 }
 
-func (r *todoRepository) Update(todo *models.Todo) error {
-	return r.db.Save(todo).Error
+// Update stores the todo and replaces its labels with Labels
+func (r *todoRepository) Update(ws models.Workspace, todo *models.Todo) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return update(tx, ws, todo)
+	})
 }
 
-func (r *todoRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Todo{}, id).Error
+// Save stores the todo like Update, gives the subtasks with the given IDs its
+// status and creates next, the todo for the next occurrence of a recurring
+// todo, unless it is nil, all in one transaction
+func (r *todoRepository) Save(ws models.Workspace, todo *models.Todo, subtaskIDs []uint, next *models.Todo) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := update(tx, ws, todo); err != nil {
+			return err
+		}
+		if len(subtaskIDs) > 0 {
+			err := todosInWorkspace(tx.Model(&models.Todo{}), ws).
+				Where("id IN ?", subtaskIDs).
+				Updates(map[string]interface{}{
+					"status":       todo.Status,
+					"completed":    todo.Completed,
+					"completed_at": todo.CompletedAt,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		return tx.Omit("Labels.*").Create(next).Error
+	})
 }
 
-func (r *todoRepository) List(page, pageSize int) ([]models.Todo, int64, error) {
+func update(tx *gorm.DB, ws models.Workspace, todo *models.Todo) error {
+	result := todosInWorkspace(tx.Model(&models.Todo{}), ws).
+		Where("id = ?", todo.ID).
+		Select("*").
+		Omit("ID", "UserID", "OrganizationID", "CreatedAt", "DeletedAt", "Labels").
+		Updates(todo)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return tx.Model(todo).Omit("Labels.*").Association("Labels").Replace(todo.Labels)
+}
+
+// Delete deletes the todo together with its subtasks in one transaction
+func (r *todoRepository) Delete(ws models.Workspace, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		subtree, err := subtree(tx, ws, id)
+		if err != nil {
+			return err
+		}
+
+		ids := []uint{id}
+		for _, subtask := range subtree {
+			ids = append(ids, subtask.ID)
+		}
+		result := todosInWorkspace(tx, ws).Delete(&models.Todo{}, ids)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// Subtree returns the subtasks of the todo at any depth, level by level
+func (r *todoRepository) Subtree(ws models.Workspace, id uint) ([]models.Todo, error) {
+	return subtree(r.db, ws, id)
+}
+
+func subtree(db *gorm.DB, ws models.Workspace, id uint) ([]models.Todo, error) {
+	var subtree []models.Todo
+	seen := map[uint]bool{id: true}
+	parents := []uint{id}
+	for len(parents) > 0 {
+		var level []models.Todo
+		err := todosInWorkspace(db, ws).Preload("Labels").Where("parent_id IN ?", parents).Order("id").Find(&level).Error
+		if err != nil {
+			return nil, err
+		}
+
+		parents = parents[:0]
+		for _, todo := range level {
+			if !seen[todo.ID] {
+				seen[todo.ID] = true
+				subtree = append(subtree, todo)
+				parents = append(parents, todo.ID)
+			}
+		}
+	}
+	return subtree, nil
+}
+
+func (r *todoRepository) List(ws models.Workspace, filter models.TodoFilter, page, pageSize int) ([]models.Todo, int64, error) {
 	var todos []models.Todo
 	var total int64
 
 	offset := (page - 1) * pageSize
 
-	err := r.db.Model(&models.Todo{}).Count(&total).Error
+	err := filtered(todosInWorkspace(r.db.Model(&models.Todo{}), ws), filter).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.Offset(offset).Limit(pageSize).Find(&todos).Error
+	err = filtered(todosInWorkspace(r.db, ws), filter).
+		Preload("Labels").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&todos).Error
 	return todos, total, err
 }
+
+// filtered restricts a query of todos to those matching the filter
+func filtered(db *gorm.DB, filter models.TodoFilter) *gorm.DB {
+	if len(filter.Labels) > 0 {
+		names := make([]string, 0, len(filter.Labels))
+		seen := map[string]bool{}
+		for _, name := range filter.Labels {
+			name = strings.ToLower(name)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+
+		labeled := db.Session(&gorm.Session{NewDB: true}).
+			Table("todo_labels").
+			Select("todo_labels.todo_id").
+			Joins("JOIN labels ON labels.id = todo_labels.label_id").
+			Where("LOWER(labels.name) IN ?", names)
+		if filter.MatchAllLabels {
+			labeled = labeled.Group("todo_labels.todo_id").Having("COUNT(DISTINCT LOWER(labels.name)) = ?", len(names))
+		}
+		db = db.Where("id IN (?)", labeled)
+	}
+
+	switch {
+	case filter.ProjectID != 0:
+		db = db.Where("project_id = ?", filter.ProjectID)
+	case !filter.IncludeArchived:
+		archived := db.Session(&gorm.Session{NewDB: true}).
+			Model(&models.Project{}).
+			Select("id").
+			Where("archived = ?", true)
+		db = db.Where("project_id IS NULL OR project_id NOT IN (?)", archived)
+	}
+	return db
+}
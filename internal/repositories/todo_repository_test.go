@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/netf/gofiber-boilerplate/internal/db/dbtest"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var personal = models.Workspace{UserID: 1}
+
+func createLabels(t *testing.T, db *gorm.DB, ws models.Workspace, names ...string) map[string]models.Label {
+	t.Helper()
+	labels := map[string]models.Label{}
+	for _, name := range names {
+		label := models.Label{UserID: ws.UserID, Name: name}
+		require.NoError(t, NewLabelRepository(db).Create(&label))
+		labels[name] = label
+	}
+	return labels
+}
+
+func createTodo(t *testing.T, db *gorm.DB, ws models.Workspace, title string, projectID *uint, labels ...models.Label) models.Todo {
+	t.Helper()
+	todo := models.Todo{UserID: ws.UserID, Title: title, Status: models.TodoStatusTodo, ProjectID: projectID, Labels: labels}
+	require.NoError(t, NewTodoRepository(db).Create(&todo))
+	return todo
+}
+
+func titles(todos []models.Todo) []string {
+	titles := []string{}
+	for _, todo := range todos {
+		titles = append(titles, todo.Title)
+	}
+	return titles
+}
+
+func TestListTodosByLabel(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := NewTodoRepository(db)
+
+	labels := createLabels(t, db, personal, "Urgent", "home", "work")
+	createTodo(t, db, personal, "Both", nil, labels["Urgent"], labels["home"])
+	createTodo(t, db, personal, "Urgent only", nil, labels["Urgent"])
+	createTodo(t, db, personal, "Home only", nil, labels["home"])
+	createTodo(t, db, personal, "Work only", nil, labels["work"])
+	createTodo(t, db, personal, "Unlabeled", nil)
+
+	// Another user's todo with labels of the same names is never listed
+	other := models.Workspace{UserID: 2}
+	otherLabels := createLabels(t, db, other, "urgent", "home")
+	createTodo(t, db, other, "Other user", nil, otherLabels["urgent"], otherLabels["home"])
+
+	testCases := []struct {
+		name     string
+		filter   models.TodoFilter
+		expected []string
+	}{
+		{name: "No Labels", filter: models.TodoFilter{}, expected: []string{"Both", "Urgent only", "Home only", "Work only", "Unlabeled"}},
+		{name: "Any", filter: models.TodoFilter{Labels: []string{"urgent", "home"}}, expected: []string{"Both", "Urgent only", "Home only"}},
+		{name: "All", filter: models.TodoFilter{Labels: []string{"urgent", "HOME"}, MatchAllLabels: true}, expected: []string{"Both"}},
+		{name: "All Of One", filter: models.TodoFilter{Labels: []string{"work"}, MatchAllLabels: true}, expected: []string{"Work only"}},
+		{name: "All With Repeated Name", filter: models.TodoFilter{Labels: []string{"Urgent", "urgent"}, MatchAllLabels: true}, expected: []string{"Both", "Urgent only"}},
+		{name: "All With Unknown Label", filter: models.TodoFilter{Labels: []string{"urgent", "missing"}, MatchAllLabels: true}, expected: []string{}},
+		{name: "Any With Unknown Label", filter: models.TodoFilter{Labels: []string{"missing"}}, expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			todos, total, err := repo.List(personal, tc.filter, 1, 100)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, titles(todos))
+			assert.Equal(t, int64(len(tc.expected)), total)
+		})
+	}
+}
+
+func TestListTodosOfArchivedProjects(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := NewTodoRepository(db)
+
+	open := models.Project{UserID: personal.UserID, Name: "Open"}
+	archived := models.Project{UserID: personal.UserID, Name: "Archived", Archived: true}
+	require.NoError(t, NewProjectRepository(db).Create(&open))
+	require.NoError(t, NewProjectRepository(db).Create(&archived))
+	createTodo(t, db, personal, "In open project", &open.ID)
+	createTodo(t, db, personal, "In archived project", &archived.ID)
+	createTodo(t, db, personal, "Without project", nil)
+
+	testCases := []struct {
+		name     string
+		filter   models.TodoFilter
+		expected []string
+	}{
+		{name: "Default", filter: models.TodoFilter{}, expected: []string{"In open project", "Without project"}},
+		{name: "Include Archived", filter: models.TodoFilter{IncludeArchived: true}, expected: []string{"In open project", "In archived project", "Without project"}},
+		{name: "Archived Project", filter: models.TodoFilter{ProjectID: archived.ID}, expected: []string{"In archived project"}},
+		{name: "Open Project", filter: models.TodoFilter{ProjectID: open.ID, IncludeArchived: true}, expected: []string{"In open project"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			todos, total, err := repo.List(personal, tc.filter, 1, 100)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, titles(todos))
+			assert.Equal(t, int64(len(tc.expected)), total)
+		})
+	}
+}
+
+func TestTodoSubtree(t *testing.T) {
+	db := dbtest.Open(t)
+	repo := NewTodoRepository(db)
+
+	top := createTodo(t, db, personal, "Top", nil)
+	child := models.Todo{UserID: personal.UserID, Title: "Child", Status: models.TodoStatusTodo, ParentID: &top.ID}
+	require.NoError(t, repo.Create(&child))
+	grandchild := models.Todo{UserID: personal.UserID, Title: "Grandchild", Status: models.TodoStatusTodo, ParentID: &child.ID}
+	require.NoError(t, repo.Create(&grandchild))
+	createTodo(t, db, personal, "Other", nil)
+
+	top.Status = models.TodoStatusDone
+	top.Completed = true
+	next := models.Todo{UserID: personal.UserID, Title: "Next", Status: models.TodoStatusTodo}
+	require.NoError(t, repo.Save(personal, &top, []uint{grandchild.ID}, &next))
+	assert.NotZero(t, next.ID)
+	subtree, err := repo.Subtree(personal, top.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Child", "Grandchild"}, titles(subtree))
+	assert.Equal(t, models.TodoStatusTodo, subtree[0].Status)
+	assert.Equal(t, models.TodoStatusDone, subtree[1].Status)
+	assert.True(t, subtree[1].Completed)
+
+	require.NoError(t, repo.Delete(personal, top.ID))
+	todos, _, err := repo.List(personal, models.TodoFilter{}, 1, 100)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Other", "Next"}, titles(todos))
+	assert.ErrorIs(t, repo.Delete(personal, top.ID), gorm.ErrRecordNotFound)
+}
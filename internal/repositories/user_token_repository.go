@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"gorm.io/gorm"
+)
+
+// UserTokenRepository handles database operations for email verification and
+// password reset tokens
+type UserTokenRepository interface {
+	Create(token *models.UserToken) error
+	Find(purpose, tokenHash string) (*models.UserToken, error)
+	Consume(purpose, tokenHash string) (*models.UserToken, error)
+	InvalidateForUser(userID uint, purpose string) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewUserTokenRepository creates a new UserTokenRepository instance
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db}
+}
+
+func (r *userTokenRepository) Create(token *models.UserToken) error {
+	if err := r.db.Create(token).Error; err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
+
+// Find returns an unused, unexpired token for purpose without using it up.
+// Unknown, used and expired tokens yield errors.ErrInvalidToken.
+func (r *userTokenRepository) Find(purpose, tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, time.Now()).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token for purpose as used and returns
+// it. Unknown, used and expired tokens yield errors.ErrInvalidToken, and of
+// two concurrent calls with the same token only one succeeds.
+func (r *userTokenRepository) Consume(purpose, tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		err := tx.Where("token_hash = ? AND purpose = ?", tokenHash, purpose).First(&token).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.ErrInvalidToken
+			}
+			return errors.ErrDatabaseOperation
+		}
+		if token.UsedAt != nil || now.After(token.ExpiresAt) {
+			return errors.ErrInvalidToken
+		}
+
+		result := tx.Model(&models.UserToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return errors.ErrDatabaseOperation
+		}
+		if result.RowsAffected == 0 {
+			return errors.ErrInvalidToken
+		}
+		token.UsedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateForUser marks all unused tokens of the user for purpose as used
+func (r *userTokenRepository) InvalidateForUser(userID uint, purpose string) error {
+	err := r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	return nil
+}
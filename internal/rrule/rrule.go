@@ -0,0 +1,435 @@
+// Package rrule parses and expands recurrence rules in the RRULE format of
+// RFC 5545 (iCalendar).
+//
+// The DAILY, WEEKLY, MONTHLY and YEARLY frequencies are supported together
+// with the INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST rule
+// parts, which covers the rules calendar applications create for recurring
+// events. Other rule parts are rejected rather than ignored.
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a rule
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencies = map[string]Frequency{
+	"DAILY":   Daily,
+	"WEEKLY":  Weekly,
+	"MONTHLY": Monthly,
+	"YEARLY":  Yearly,
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// maxYears bounds how far after its start a series is expanded. The
+// Gregorian calendar repeats every 400 years, so a rule that matches no day
+// in that time only matches in later cycles if its interval skips the
+// matching days, and expanding a series scans at most about 146,000 days.
+const maxYears = 400
+
+// cycles is the number of periods of each frequency in maxYears
+var cycles = map[Frequency]int{
+	Daily:   146097,
+	Weekly:  20871,
+	Monthly: maxYears * 12,
+	Yearly:  maxYears,
+}
+
+// sampleYears are years of every kind the calendar has: leap years and
+// common years starting on each day of the week. Months of them start on
+// every day of the week with every length.
+var sampleYears = func() []int {
+	var years []int
+	seen := map[[2]int]bool{}
+	for year := 2000; len(years) < 14; year++ {
+		kind := [2]int{int(date(year, time.January, 1).Weekday()), date(year, time.December, 31).YearDay()}
+		if !seen[kind] {
+			seen[kind] = true
+			years = append(years, year)
+		}
+	}
+	return years
+}()
+
+// Weekday is a BYDAY value. A non-zero N selects only the Nth occurrence of
+// the day within the month or year, counted from the end if negative.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Count is the number of occurrences of the series, or 0 for no limit
+	Count int
+	// Until is the last time an occurrence may fall on, or zero for no limit.
+	// If UntilLocal is set it is a wall clock time in the location of the
+	// series rather than an instant.
+	Until      time.Time
+	UntilLocal bool
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// Parse parses a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH". A leading
+// "RRULE:" is allowed.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	hasFreq := false
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s is given more than once", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			var found bool
+			if r.Freq, found = frequencies[value]; !found {
+				return nil, fmt.Errorf("unsupported FREQ %s", value)
+			}
+			hasFreq = true
+		case "INTERVAL":
+			r.Interval, err = positive(name, value)
+		case "COUNT":
+			r.Count, err = positive(name, value)
+		case "UNTIL":
+			r.Until, r.UntilLocal, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseList(name, value, -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseList(name, value, 1, 12)
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			var found bool
+			if r.WeekStart, found = weekdays[value]; !found {
+				return nil, fmt.Errorf("invalid WKST %s", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasFreq {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot both be given")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, fmt.Errorf("numbered BYDAY values need FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+	if !r.matchable() {
+		return nil, fmt.Errorf("BYMONTH, BYMONTHDAY and BYDAY never match the same day")
+	}
+	return r, nil
+}
+
+// matchable reports whether any day matches the BYMONTH, BYMONTHDAY and
+// BYDAY parts of the rule, such as the first Monday of a month on the 31st
+// or the 30th of February not doing so
+func (r *Rule) matchable() bool {
+	for _, year := range sampleYears {
+		if r.Freq == Yearly && len(r.ByMonth) == 0 && len(r.ByDay) > 0 {
+			// Numbered days count within the whole year
+			yearStart, yearEnd := date(year, time.January, 1), date(year, time.December, 31)
+			for day := yearStart; !day.After(yearEnd); day = day.AddDate(0, 0, 1) {
+				if r.matchesMonthDay(day) && r.matchesDay(day, yearStart, yearEnd) {
+					return true
+				}
+			}
+			continue
+		}
+		for m := time.January; m <= time.December; m++ {
+			if month := date(year, m, 1); r.matchesMonth(month) && len(r.monthDays(month, 1)) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func positive(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %s", name, value)
+	}
+	return n, nil
+}
+
+func parseList(name, value string, lo, hi int) ([]int, error) {
+	var list []int
+	for _, v := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < lo || n > hi || n == 0 {
+			return nil, fmt.Errorf("invalid %s value %s", name, v)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	var list []Weekday
+	for _, v := range strings.Split(value, ",") {
+		if len(v) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY value %s", v)
+		}
+		day, found := weekdays[v[len(v)-2:]]
+		if !found {
+			return nil, fmt.Errorf("invalid BYDAY value %s", v)
+		}
+		wd := Weekday{Day: day}
+		if ordinal := v[:len(v)-2]; ordinal != "" {
+			n, err := strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY value %s", v)
+			}
+			wd.N = n
+		}
+		list = append(list, wd)
+	}
+	return list, nil
+}
+
+// parseUntil parses a DATE or DATE-TIME. Times without a trailing Z and dates
+// are local to the series; a date includes the whole day.
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid UNTIL %s", value)
+}
+
+// After returns up to n occurrences of the series that starts at start and
+// follows the rule, in order, that are later than t. Occurrences keep the
+// wall clock time of start in its location, also across daylight saving
+// time changes. start itself is an occurrence only if it matches the rule.
+func (r *Rule) After(start, t time.Time, n int) []time.Time {
+	var occurrences []time.Time
+	if n < 1 {
+		return occurrences
+	}
+	r.each(start, func(occurrence time.Time) bool {
+		if occurrence.After(t) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return len(occurrences) < n
+	})
+	return occurrences
+}
+
+// each calls yield with the occurrences of the series in order until yield
+// returns false or the series ends
+func (r *Rule) each(start time.Time, yield func(time.Time) bool) {
+	loc := start.Location()
+	until := r.Until
+	if r.UntilLocal {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, loc)
+	}
+
+	count := 0
+	periods := cycles[r.Freq]/r.Interval + 1
+	for period := 0; period < periods; period++ {
+		for _, day := range r.period(start, period) {
+			occurrence := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+			if occurrence.Before(start) {
+				continue
+			}
+			if !until.IsZero() && occurrence.After(until) {
+				return
+			}
+			count++
+			if !yield(occurrence) || (r.Count > 0 && count >= r.Count) {
+				return
+			}
+		}
+	}
+}
+
+// period returns the days of the given interval after the one containing
+// start that match the rule, in order. Days are midnight UTC.
+func (r *Rule) period(start time.Time, period int) []time.Time {
+	first := date(start.Year(), start.Month(), start.Day())
+	step := period * r.Interval
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := first.AddDate(0, 0, step)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesDay(day, day, day) {
+			days = append(days, day)
+		}
+	case Weekly:
+		offset := (int(first.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := first.AddDate(0, 0, step*7-offset)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if !r.matchesMonth(day) {
+				continue
+			}
+			if len(r.ByDay) == 0 && day.Weekday() != first.Weekday() {
+				continue
+			}
+			if r.matchesDay(day, day, day) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		month := date(first.Year(), first.Month(), 1).AddDate(0, step, 0)
+		if r.matchesMonth(month) {
+			days = r.monthDays(month, first.Day())
+		}
+	case Yearly:
+		year := first.Year() + step
+		switch {
+		case len(r.ByMonth) > 0:
+			for m := time.January; m <= time.December; m++ {
+				if r.matchesMonth(date(year, m, 1)) {
+					days = append(days, r.monthDays(date(year, m, 1), first.Day())...)
+				}
+			}
+		case len(r.ByDay) > 0:
+			// Numbered days count within the whole year
+			yearStart, yearEnd := date(year, time.January, 1), date(year, time.December, 31)
+			for day := yearStart; !day.After(yearEnd); day = day.AddDate(0, 0, 1) {
+				if r.matchesMonthDay(day) && r.matchesDay(day, yearStart, yearEnd) {
+					days = append(days, day)
+				}
+			}
+		case len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				days = append(days, r.monthDays(date(year, m, 1), first.Day())...)
+			}
+		default:
+			if day := date(year, first.Month(), first.Day()); day.Month() == first.Month() {
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// monthDays returns the days of the month that match BYMONTHDAY and BYDAY,
+// with numbered days counted within the month. Without either it returns the
+// day of the month of the series start, if the month has it.
+func (r *Rule) monthDays(month time.Time, startDay int) []time.Time {
+	monthEnd := month.AddDate(0, 1, -1)
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if startDay > monthEnd.Day() {
+			return nil
+		}
+		return []time.Time{month.AddDate(0, 0, startDay-1)}
+	}
+
+	var days []time.Time
+	for day := month; !day.After(monthEnd); day = day.AddDate(0, 0, 1) {
+		if r.matchesMonthDay(day) && r.matchesDay(day, month, monthEnd) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func (r *Rule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if day.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := date(day.Year(), day.Month()+1, 0).Day()
+	for _, n := range r.ByMonthDay {
+		if n == day.Day() || (n < 0 && last+n+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesDay reports whether the day matches BYDAY, counting numbered days
+// within the range from first to last
+func (r *Rule) matchesDay(day, first, last time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	nth := int(day.Sub(first).Hours()/24)/7 + 1
+	nthLast := -(int(last.Sub(day).Hours()/24)/7 + 1)
+	for _, wd := range r.ByDay {
+		if wd.Day == day.Weekday() && (wd.N == 0 || wd.N == nth || wd.N == nthLast) {
+			return true
+		}
+	}
+	return false
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,225 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// dates formats occurrences as dates for compact comparisons
+func dates(occurrences []time.Time) []string {
+	list := make([]string, len(occurrences))
+	for i, t := range occurrences {
+		list[i] = t.Format("2006-01-02")
+	}
+	return list
+}
+
+// Examples from RFC 5545 section 3.8.5.3, all starting at 09:00 New York time
+func TestAfterRFC5545Examples(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     string
+		start    string
+		n        int
+		expected []string
+	}{
+		{
+			name:     "Daily For 10 Occurrences",
+			rule:     "FREQ=DAILY;COUNT=10",
+			start:    "1997-09-02",
+			n:        20,
+			expected: []string{"1997-09-02", "1997-09-03", "1997-09-04", "1997-09-05", "1997-09-06", "1997-09-07", "1997-09-08", "1997-09-09", "1997-09-10", "1997-09-11"},
+		},
+		{
+			name:     "Every Other Day",
+			rule:     "FREQ=DAILY;INTERVAL=2",
+			start:    "1997-09-02",
+			n:        4,
+			expected: []string{"1997-09-02", "1997-09-04", "1997-09-06", "1997-09-08"},
+		},
+		{
+			name:     "Daily Until December 24",
+			rule:     "FREQ=DAILY;UNTIL=19971224T000000Z",
+			start:    "1997-12-20",
+			n:        10,
+			expected: []string{"1997-12-20", "1997-12-21", "1997-12-22", "1997-12-23"},
+		},
+		{
+			name:     "Weekly On Tuesday And Thursday For Five Weeks",
+			rule:     "FREQ=WEEKLY;COUNT=10;WKST=SU;BYDAY=TU,TH",
+			start:    "1997-09-02",
+			n:        20,
+			expected: []string{"1997-09-02", "1997-09-04", "1997-09-09", "1997-09-11", "1997-09-16", "1997-09-18", "1997-09-23", "1997-09-25", "1997-09-30", "1997-10-02"},
+		},
+		{
+			name:     "Every Other Week On Monday, Wednesday And Friday",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;WKST=SU;BYDAY=MO,WE,FR",
+			start:    "1997-09-01",
+			n:        6,
+			expected: []string{"1997-09-01", "1997-09-03", "1997-09-05", "1997-09-15", "1997-09-17", "1997-09-19"},
+		},
+		{
+			name:     "Monthly On The First Friday",
+			rule:     "FREQ=MONTHLY;COUNT=10;BYDAY=1FR",
+			start:    "1997-09-05",
+			n:        3,
+			expected: []string{"1997-09-05", "1997-10-03", "1997-11-07"},
+		},
+		{
+			name:     "Every Other Month On The First And Last Sunday",
+			rule:     "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU",
+			start:    "1997-09-07",
+			n:        4,
+			expected: []string{"1997-09-07", "1997-09-28", "1997-11-02", "1997-11-30"},
+		},
+		{
+			name:     "Monthly On The Third To Last Day",
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-3",
+			start:    "1997-09-28",
+			n:        3,
+			expected: []string{"1997-09-28", "1997-10-29", "1997-11-28"},
+		},
+		{
+			name:     "Friday The 13th",
+			rule:     "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			start:    "1997-09-02",
+			n:        3,
+			expected: []string{"1998-02-13", "1998-03-13", "1998-11-13"},
+		},
+		{
+			name:     "Monthly On The 31st Skips Short Months",
+			rule:     "FREQ=MONTHLY",
+			start:    "1997-10-31",
+			n:        3,
+			expected: []string{"1997-10-31", "1997-12-31", "1998-01-31"},
+		},
+		{
+			name:     "Yearly In June And July",
+			rule:     "FREQ=YEARLY;COUNT=10;BYMONTH=6,7",
+			start:    "1997-06-10",
+			n:        4,
+			expected: []string{"1997-06-10", "1997-07-10", "1998-06-10", "1998-07-10"},
+		},
+		{
+			name:     "Every 20th Monday Of The Year",
+			rule:     "FREQ=YEARLY;BYDAY=20MO",
+			start:    "1997-05-19",
+			n:        3,
+			expected: []string{"1997-05-19", "1998-05-18", "1999-05-17"},
+		},
+		{
+			name:     "Every Thursday In March",
+			rule:     "FREQ=YEARLY;BYMONTH=3;BYDAY=TH",
+			start:    "1997-03-13",
+			n:        5,
+			expected: []string{"1997-03-13", "1997-03-20", "1997-03-27", "1998-03-05", "1998-03-12"},
+		},
+		{
+			name:     "Leap Day",
+			rule:     "FREQ=YEARLY",
+			start:    "2024-02-29",
+			n:        2,
+			expected: []string{"2024-02-29", "2028-02-29"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := Parse(tc.rule)
+			require.NoError(t, err)
+
+			day, err := time.ParseInLocation("2006-01-02", tc.start, newYork)
+			require.NoError(t, err)
+			start := day.Add(9 * time.Hour)
+
+			occurrences := rule.After(start, start.Add(-time.Second), tc.n)
+			assert.Equal(t, tc.expected, dates(occurrences))
+			for _, occurrence := range occurrences {
+				assert.Equal(t, 9, occurrence.Hour())
+			}
+		})
+	}
+}
+
+func TestAfterSkipsEarlierOccurrences(t *testing.T) {
+	rule, err := Parse("RRULE:FREQ=WEEKLY;BYDAY=MO;COUNT=3")
+	require.NoError(t, err)
+
+	start := time.Date(2024, 5, 6, 17, 0, 0, 0, time.UTC)
+	// Occurrences before t still count towards COUNT
+	occurrences := rule.After(start, start, 10)
+	assert.Equal(t, []string{"2024-05-13", "2024-05-20"}, dates(occurrences))
+
+	assert.Empty(t, rule.After(start, start.AddDate(0, 1, 0), 10))
+}
+
+func TestAfterKeepsWallClockAcrossDST(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;UNTIL=20240312")
+	require.NoError(t, err)
+
+	// Daylight saving time starts in New York on 2024-03-10
+	start := time.Date(2024, 3, 9, 9, 0, 0, 0, newYork)
+	occurrences := rule.After(start, start.Add(-time.Second), 10)
+	require.Len(t, occurrences, 4)
+	for _, occurrence := range occurrences {
+		assert.Equal(t, 9, occurrence.Hour())
+	}
+	assert.Equal(t, 23*time.Hour, occurrences[1].Sub(occurrences[0]))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=3;UNTIL=20240101T000000Z",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=MONTHLY;BYSETPOS=-1;BYDAY=MO",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;COUNT",
+		"FREQ=YEARLY;BYDAY=1MO;BYMONTHDAY=31",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		"FREQ=MONTHLY;BYDAY=6FR",
+		"FREQ=MONTHLY;BYDAY=-1SU;BYMONTHDAY=1,2,3",
+		"FREQ=DAILY;BYMONTH=4,6;BYMONTHDAY=31",
+		"FREQ=WEEKLY;BYMONTH=2;BYMONTHDAY=-30",
+	} {
+		_, err := Parse(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestParseRarelyMatchingRules(t *testing.T) {
+	for _, rule := range []string{
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29",
+		"FREQ=YEARLY;BYDAY=53TH",
+		"FREQ=YEARLY;BYDAY=-1MO;BYMONTHDAY=31",
+		"FREQ=MONTHLY;BYDAY=5FR;BYMONTHDAY=31",
+	} {
+		_, err := Parse(rule)
+		assert.NoError(t, err, rule)
+	}
+}
+
+func TestAfterEndsForSeriesThatNeverRecur(t *testing.T) {
+	// Every fourth year from a common year is never a leap year
+	rule, err := Parse("FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29")
+	require.NoError(t, err)
+	start := time.Date(2023, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	began := time.Now()
+	assert.Empty(t, rule.After(start, start, 1))
+	assert.Less(t, time.Since(began), time.Second)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyPrefix makes keys recognisable, e.g. by secret scanners, and tells
+// them apart from JWTs in a bearer token
+const APIKeyPrefix = "gfb_"
+
+// lastUsedResolution limits how often LastUsedAt is written for a busy key
+const lastUsedResolution = time.Minute
+
+// APIKeyService manages API keys and authenticates requests made with them
+type APIKeyService interface {
+	CreateAPIKey(userID uint, name string, scopes []string, projectIDs []uint, expiresAt *time.Time) (*models.APIKey, error)
+	ListAPIKeys(userID uint) ([]models.APIKey, error)
+	RevokeAPIKey(userID, id uint) error
+	AuthenticateAPIKey(key string) (*models.User, *models.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo        repositories.APIKeyRepository
+	authRepo    repositories.AuthRepository
+	projectRepo repositories.ProjectRepository
+}
+
+// NewAPIKeyService creates a new instance of APIKeyService
+func NewAPIKeyService(repo repositories.APIKeyRepository, authRepo repositories.AuthRepository, projectRepo repositories.ProjectRepository) APIKeyService {
+	return &apiKeyService{repo: repo, authRepo: authRepo, projectRepo: projectRepo}
+}
+
+// CreateAPIKey creates a key for the user. Scopes must be a subset of the
+// user's permissions and projectIDs must name projects in the user's
+// personal space, where API keys act. The plaintext key is only available in
+// the Key field of the result.
+func (s *apiKeyService) CreateAPIKey(userID uint, name string, scopes []string, projectIDs []uint, expiresAt *time.Time) (*models.APIKey, error) {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expiry must be in the future", errors.ErrInvalidInput)
+	}
+
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := make(map[string]bool)
+	for _, permission := range user.PermissionNames() {
+		granted[permission] = true
+	}
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return nil, fmt.Errorf("%w: scope %q is not granted to the user", errors.ErrInvalidInput, scope)
+		}
+	}
+
+	for _, id := range projectIDs {
+		_, err := s.projectRepo.FindByID(models.Workspace{UserID: userID}, id)
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return nil, fmt.Errorf("%w: project %d does not exist", errors.ErrInvalidInput, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	token, _, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	key := APIKeyPrefix + token
+
+	apiKey := &models.APIKey{
+		UserID:     userID,
+		Name:       name,
+		Prefix:     key[:len(APIKeyPrefix)+6],
+		Scopes:     scopes,
+		ProjectIDs: projectIDs,
+		KeyHash:    hashToken(key),
+		ExpiresAt:  expiresAt,
+		Key:        key,
+	}
+	if apiKey.Scopes == nil {
+		apiKey.Scopes = []string{}
+	}
+	if apiKey.ProjectIDs == nil {
+		apiKey.ProjectIDs = []uint{}
+	}
+	if err := s.repo.Create(apiKey); err != nil {
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+// ListAPIKeys returns all keys of the user, including revoked and expired ones
+func (s *apiKeyService) ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	return s.repo.ListByUser(userID)
+}
+
+// RevokeAPIKey revokes one of the user's keys
+func (s *apiKeyService) RevokeAPIKey(userID, id uint) error {
+	return s.repo.Revoke(userID, id)
+}
+
+// AuthenticateAPIKey resolves a key to its owner. Unknown, revoked and
+// expired keys yield errors.ErrInvalidToken.
+func (s *apiKeyService) AuthenticateAPIKey(key string) (*models.User, *models.APIKey, error) {
+	apiKey, err := s.repo.FindByHash(hashToken(key))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt)) {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	user, err := s.authRepo.FindUserByID(apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			return nil, nil, errors.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, nil, errors.ErrAccountDisabled
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > lastUsedResolution {
+		if err := s.repo.TouchLastUsed(apiKey.ID, now); err != nil {
+			log.Error().Err(err).Uint("api_key_id", apiKey.ID).Msg("Failed to record API key usage")
+		}
+		apiKey.LastUsedAt = &now
+	}
+
+	return user, apiKey, nil
+}
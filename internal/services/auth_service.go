@@ -1,64 +1,173 @@
 package services
 
 import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/netf/gofiber-boilerplate/config"
 	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/mailer"
 	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/password"
 	"github.com/netf/gofiber-boilerplate/internal/repositories"
-	"github.com/netf/gofiber-boilerplate/internal/utils"
 	"github.com/rs/zerolog/log"
-	"gorm.io/gorm"
 )
 
+// maxUserAgentLength bounds the User-Agent stored with a session
+const maxUserAgentLength = 512
+
 // AuthService defines the interface for authentication-related operations
 type AuthService interface {
 	AuthenticateUser(name, password string) (*models.User, error)
 	RegisterUser(name, password, email string) (*models.User, error)
+	CreateSession(userID uint, userAgent, ip string) (*models.RefreshToken, error)
+	RotateRefreshToken(refreshToken string) (*models.User, *models.RefreshToken, error)
+	Logout(userID uint, jti string, expiresAt time.Time, sessionID string) error
+	LogoutAll(userID uint) error
+	SendVerificationEmail(email string) error
+	VerifyEmail(token string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(token, password string) error
+	GetProfile(userID uint) (*models.User, error)
+	UpdateProfile(userID uint, request models.UpdateProfileRequest) (*models.User, error)
+	ChangePassword(userID uint, currentPassword, newPassword, currentSessionID string) error
+	RequestEmailChange(userID uint, email, password string) error
+	ConfirmEmailChange(token string) error
 }
 
 // authService implements the AuthService interface
 type authService struct {
-	authRepo repositories.AuthRepository
+	authRepo        repositories.AuthRepository
+	refreshRepo     repositories.RefreshTokenRepository
+	sessionRepo     repositories.SessionRepository
+	revocations     RevocationService
+	roleRepo        repositories.RoleRepository
+	userTokenRepo   repositories.UserTokenRepository
+	hasher          *password.Hasher
+	passwordPolicy  PasswordPolicyService
+	mailer          mailer.Mailer
+	refreshTokenTTL time.Duration
+	appURL          string
+	verificationTTL time.Duration
+	resetTTL        time.Duration
+	requireVerified bool
+	// dummyHash is verified against when the user does not exist so that
+	// unknown names take as long to reject as wrong passwords
+	dummyHash []byte
 }
 
 // NewAuthService creates a new instance of AuthService
-func NewAuthService(authRepo repositories.AuthRepository) AuthService {
-	return &authService{authRepo: authRepo}
+func NewAuthService(authRepo repositories.AuthRepository, refreshRepo repositories.RefreshTokenRepository, sessionRepo repositories.SessionRepository, revocations RevocationService, roleRepo repositories.RoleRepository, userTokenRepo repositories.UserTokenRepository, hasher *password.Hasher, passwordPolicy PasswordPolicyService, mail mailer.Mailer, cfg *config.Config) AuthService {
+	dummyHash, err := hasher.Hash("dummy password")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to compute dummy password hash")
+	}
+	return &authService{
+		authRepo:        authRepo,
+		refreshRepo:     refreshRepo,
+		sessionRepo:     sessionRepo,
+		revocations:     revocations,
+		roleRepo:        roleRepo,
+		userTokenRepo:   userTokenRepo,
+		hasher:          hasher,
+		passwordPolicy:  passwordPolicy,
+		mailer:          mail,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+		appURL:          strings.TrimSuffix(cfg.AppURL, "/"),
+		verificationTTL: cfg.EmailVerificationTTL,
+		resetTTL:        cfg.PasswordResetTTL,
+		requireVerified: cfg.RequireEmailVerified,
+		dummyHash:       dummyHash,
+	}
 }
 
-// AuthenticateUser authenticates a user with the given credentials
+// AuthenticateUser authenticates a user with the given credentials. If the
+// user has two-factor authentication enabled the user is returned together
+// with errors.ErrMFARequired and the login must be completed with MFAService.
+// Users who have not verified their email address are rejected with
+// errors.ErrEmailNotVerified when verification is required. Disabled users
+// and users who must reset their password are rejected even if the password
+// is correct.
 func (s *authService) AuthenticateUser(name, password string) (*models.User, error) {
 	if name == "" || password == "" {
 		return nil, errors.New("username and password are required")
 	}
 
-	hashedPassword, err := utils.PwHash([]byte(password))
+	user, err := s.authRepo.FindUserByName(name)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to hash password")
-		return nil, err
+		if !errors.Is(err, errors.ErrUserNotFound) {
+			log.Error().Err(err).Str("name", name).Msg("Failed to find user")
+			return nil, err
+		}
+		s.hasher.Verify(password, s.dummyHash)
+		return nil, errors.ErrInvalidCredentials
 	}
 
-	user, err := s.authRepo.FindUserByCredentials(name, hashedPassword)
+	ok, needsRehash, err := s.hasher.Verify(password, user.Pass)
 	if err != nil {
-		log.Error().Err(err).Str("name", name).Msg("Failed to find user")
-		return nil, err
+		log.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to verify password")
+		return nil, errors.ErrInvalidCredentials
+	}
+	if !ok {
+		return nil, errors.ErrInvalidCredentials
+	}
+
+	if user.DisabledAt != nil {
+		return nil, errors.ErrAccountDisabled
+	}
+	if user.PasswordResetRequired {
+		return nil, errors.ErrPasswordResetRequired
+	}
+
+	// Transparently upgrade legacy or outdated hashes now that we know the password
+	if needsRehash {
+		if hashedPassword, err := s.hasher.Hash(password); err != nil {
+			log.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to rehash password")
+		} else if err := s.authRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+			log.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to store rehashed password")
+		} else {
+			user.Pass = hashedPassword
+		}
+	}
+
+	if s.requireVerified && user.EmailVerifiedAt == nil {
+		return nil, errors.ErrEmailNotVerified
+	}
+
+	if user.TOTPEnabled {
+		return user, errors.ErrMFARequired
 	}
 
 	return user, nil
 }
 
-// RegisterUser creates a new user account
+// RegisterUser creates a new user account and sends a verification email.
+// Failing to send the email does not fail the registration; the user can ask
+// for it again with SendVerificationEmail.
 func (s *authService) RegisterUser(name, password, email string) (*models.User, error) {
 	// Check if user already exists
 	existingUser, err := s.authRepo.FindUserByName(name)
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+	if err != nil && !errors.Is(err, errors.ErrUserNotFound) {
 		return nil, err
 	}
 	if existingUser != nil {
 		return nil, errors.ErrUserAlreadyExists
 	}
 
+	if err := s.passwordPolicy.Validate(&models.User{Name: name, Email: email}, password); err != nil {
+		return nil, err
+	}
+
 	// Hash the password
-	hashedPassword, err := utils.PwHash([]byte(password))
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// New users get the default role
+	role, err := s.roleRepo.FindByName(models.RoleUser)
 	if err != nil {
 		return nil, err
 	}
@@ -68,11 +177,441 @@ func (s *authService) RegisterUser(name, password, email string) (*models.User,
 		Name:  name,
 		Pass:  hashedPassword,
 		Email: email,
+		Roles: []models.Role{{ID: role.ID, Name: role.Name}},
 	}
 
 	if err := s.authRepo.CreateUser(newUser); err != nil {
 		return nil, err
 	}
+	if err := s.passwordPolicy.Record(newUser.ID, hashedPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.sendVerificationEmail(newUser); err != nil {
+		log.Error().Err(err).Uint("user_id", newUser.ID).Msg("Failed to send verification email")
+	}
 
 	return newUser, nil
 }
+
+// CreateSession records a new login session for the client and issues the
+// first refresh token of it. The plaintext token is only available in the
+// Token field of the result; its FamilyID is the session ID.
+func (s *authService) CreateSession(userID uint, userAgent, ip string) (*models.RefreshToken, error) {
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.refreshTokenTTL)
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	session := &models.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	refreshToken := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  session.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		Token:     token,
+	}
+	if err := s.refreshRepo.Create(refreshToken); err != nil {
+		return nil, err
+	}
+
+	return refreshToken, nil
+}
+
+// RotateRefreshToken exchanges a valid refresh token for a new one in the same
+// family and returns the user it belongs to. Presenting a token that has
+// already been rotated revokes the whole family, since either the legitimate
+// client or an attacker is holding a stolen copy.
+func (s *authService) RotateRefreshToken(refreshToken string) (*models.User, *models.RefreshToken, error) {
+	current, err := s.refreshRepo.FindByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if current.RevokedAt != nil {
+		if current.ReplacedByID != nil {
+			s.revokeFamily(current)
+			return nil, nil, errors.ErrTokenReused
+		}
+		return nil, nil, errors.ErrInvalidToken
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	user, err := s.authRepo.FindUserByID(current.UserID)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			return nil, nil, errors.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next := &models.RefreshToken{
+		UserID:    current.UserID,
+		FamilyID:  current.FamilyID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+		Token:     token,
+	}
+	if err := s.refreshRepo.Rotate(current, next); err != nil {
+		if errors.Is(err, errors.ErrTokenReused) {
+			s.revokeFamily(current)
+		}
+		return nil, nil, err
+	}
+
+	if err := s.sessionRepo.Touch(next.FamilyID, time.Now(), next.ExpiresAt); err != nil {
+		log.Error().Err(err).Str("session_id", next.FamilyID).Msg("Failed to update session")
+	}
+
+	// The new access token keeps acting in the session's organization
+	if session, err := s.sessionRepo.Find(next.FamilyID); err != nil {
+		log.Error().Err(err).Str("session_id", next.FamilyID).Msg("Failed to load session organization")
+	} else if session.OrganizationID != nil {
+		next.OrganizationID = *session.OrganizationID
+	}
+
+	return user, next, nil
+}
+
+// Logout revokes the access token identified by jti and the session it
+// belongs to, including the session's refresh tokens
+func (s *authService) Logout(userID uint, jti string, expiresAt time.Time, sessionID string) error {
+	if err := s.revocations.RevokeToken(jti, userID, expiresAt); err != nil {
+		return err
+	}
+	if sessionID == "" {
+		return nil
+	}
+	if err := s.revocations.RevokeSession(userID, sessionID); err != nil && !errors.Is(err, errors.ErrResourceNotFound) {
+		return err
+	}
+	return s.refreshRepo.RevokeFamily(sessionID)
+}
+
+// LogoutAll revokes every access and refresh token of the user
+func (s *authService) LogoutAll(userID uint) error {
+	if err := s.revocations.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.refreshRepo.RevokeAllForUser(userID)
+}
+
+// SendVerificationEmail sends a new verification link to the owner of email
+// if there is an unverified account for it. Unknown addresses are ignored so
+// that callers cannot tell which addresses are registered.
+func (s *authService) SendVerificationEmail(email string) error {
+	user, err := s.authRepo.FindUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return s.sendVerificationEmail(user)
+}
+
+// VerifyEmail marks the email address of the token's user as verified
+func (s *authService) VerifyEmail(token string) error {
+	userToken, err := s.userTokenRepo.Consume(models.TokenPurposeVerifyEmail, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	return s.authRepo.MarkEmailVerified(userToken.UserID, time.Now())
+}
+
+// RequestPasswordReset emails a password reset link to the owner of email.
+// Unknown addresses are ignored so that callers cannot tell which addresses
+// are registered.
+func (s *authService) RequestPasswordReset(email string) error {
+	user, err := s.authRepo.FindUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Only the most recent link works
+	if err := s.userTokenRepo.InvalidateForUser(user.ID, models.TokenPurposeResetPassword); err != nil {
+		return err
+	}
+
+	token, err := s.issueUserToken(user.ID, models.TokenPurposeResetPassword, s.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Follow the link below to choose a new password:\n\n" +
+			s.appURL + "/reset-password?token=" + url.QueryEscape(token) + "\n\n" +
+			"The link expires in " + s.resetTTL.String() + ". If you did not ask for a password reset you can ignore this email.\n",
+	})
+}
+
+// ResetPassword sets a new password for the token's user and logs the user
+// out everywhere. Since the token was delivered by email, it also verifies
+// the email address. The token stays valid if the password is rejected by
+// the password policy.
+func (s *authService) ResetPassword(token, password string) error {
+	userToken, err := s.userTokenRepo.Find(models.TokenPurposeResetPassword, hashToken(token))
+	if err != nil {
+		return err
+	}
+	user, err := s.authRepo.FindUserByID(userToken.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.passwordPolicy.Validate(user, password); err != nil {
+		return err
+	}
+
+	if _, err := s.userTokenRepo.Consume(models.TokenPurposeResetPassword, hashToken(token)); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := s.authRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+	if err := s.passwordPolicy.Record(user.ID, hashedPassword); err != nil {
+		return err
+	}
+	if err := s.authRepo.MarkEmailVerified(user.ID, time.Now()); err != nil {
+		return err
+	}
+
+	return s.LogoutAll(user.ID)
+}
+
+// GetProfile returns the user with the given ID
+func (s *authService) GetProfile(userID uint) (*models.User, error) {
+	return s.authRepo.FindUserByID(userID)
+}
+
+// UpdateProfile applies the fields set in request to the user and returns the updated user
+func (s *authService) UpdateProfile(userID uint, request models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Name != nil && *request.Name != user.Name {
+		existingUser, err := s.authRepo.FindUserByName(*request.Name)
+		if err != nil && !errors.Is(err, errors.ErrUserNotFound) {
+			return nil, err
+		}
+		if existingUser != nil {
+			return nil, errors.ErrUserAlreadyExists
+		}
+		if err := s.authRepo.UpdateName(userID, *request.Name); err != nil {
+			return nil, err
+		}
+		user.Name = *request.Name
+	}
+
+	return user, nil
+}
+
+// ChangePassword replaces the user's password after checking the current one
+// and logs out every session except currentSessionID
+func (s *authService) ChangePassword(userID uint, currentPassword, newPassword, currentSessionID string) error {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	ok, _, err := s.hasher.Verify(currentPassword, user.Pass)
+	if err != nil || !ok {
+		return errors.ErrInvalidCredentials
+	}
+
+	if err := s.passwordPolicy.Validate(user, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.authRepo.UpdatePassword(userID, hashedPassword); err != nil {
+		return err
+	}
+	if err := s.passwordPolicy.Record(userID, hashedPassword); err != nil {
+		return err
+	}
+
+	return s.revokeOtherSessions(userID, currentSessionID)
+}
+
+// RequestEmailChange sends a confirmation link to a new email address after
+// checking the user's password. The address is only changed once the link is
+// followed.
+func (s *authService) RequestEmailChange(userID uint, email, password string) error {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	ok, _, err := s.hasher.Verify(password, user.Pass)
+	if err != nil || !ok {
+		return errors.ErrInvalidCredentials
+	}
+
+	existingUser, err := s.authRepo.FindUserByEmail(email)
+	if err != nil && !errors.Is(err, errors.ErrUserNotFound) {
+		return err
+	}
+	if existingUser != nil {
+		return errors.ErrUserAlreadyExists
+	}
+
+	if err := s.authRepo.SetPendingEmail(userID, email); err != nil {
+		return err
+	}
+	// Only the link for the most recently requested address works
+	if err := s.userTokenRepo.InvalidateForUser(userID, models.TokenPurposeChangeEmail); err != nil {
+		return err
+	}
+
+	token, err := s.issueUserToken(userID, models.TokenPurposeChangeEmail, s.verificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Follow the link below to use this address for your account:\n\n" +
+			s.appURL + "/confirm-email?token=" + url.QueryEscape(token) + "\n\n" +
+			"The link expires in " + s.verificationTTL.String() + ". If you did not ask for this change you can ignore this email.\n",
+	})
+}
+
+// ConfirmEmailChange switches the token's user to the pending email address
+func (s *authService) ConfirmEmailChange(token string) error {
+	userToken, err := s.userTokenRepo.Consume(models.TokenPurposeChangeEmail, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	user, err := s.authRepo.FindUserByID(userToken.UserID)
+	if err != nil {
+		return err
+	}
+	if user.PendingEmail == "" {
+		return errors.ErrInvalidToken
+	}
+
+	// The address may have been registered since the change was requested
+	existingUser, err := s.authRepo.FindUserByEmail(user.PendingEmail)
+	if err != nil && !errors.Is(err, errors.ErrUserNotFound) {
+		return err
+	}
+	if existingUser != nil {
+		return errors.ErrUserAlreadyExists
+	}
+
+	return s.authRepo.ConfirmPendingEmail(user.ID, time.Now())
+}
+
+// revokeOtherSessions logs out every active session of the user except keepSessionID
+func (s *authService) revokeOtherSessions(userID uint, keepSessionID string) error {
+	sessions, err := s.sessionRepo.ListActive(userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := s.revocations.RevokeSession(userID, session.ID); err != nil && !errors.Is(err, errors.ErrResourceNotFound) {
+			return err
+		}
+		if err := s.refreshRepo.RevokeFamily(session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *authService) sendVerificationEmail(user *models.User) error {
+	token, err := s.issueUserToken(user.ID, models.TokenPurposeVerifyEmail, s.verificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Follow the link below to verify your email address:\n\n" +
+			s.appURL + "/verify-email?token=" + url.QueryEscape(token) + "\n\n" +
+			"The link expires in " + s.verificationTTL.String() + ".\n",
+	})
+}
+
+// issueUserToken stores a new single-use token and returns its plaintext value
+func (s *authService) issueUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.userTokenRepo.Create(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *authService) revokeFamily(token *models.RefreshToken) {
+	log.Warn().Uint("user_id", token.UserID).Str("family_id", token.FamilyID).Msg("Refresh token reuse detected, revoking token family")
+	if err := s.refreshRepo.RevokeFamily(token.FamilyID); err != nil {
+		log.Error().Err(err).Str("family_id", token.FamilyID).Msg("Failed to revoke refresh token family")
+	}
+}
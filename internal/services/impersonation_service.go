@@ -0,0 +1,85 @@
+package services
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+)
+
+// ImpersonationService lets admins act as other users and keeps the audit
+// trail of everything they do while impersonating
+type ImpersonationService interface {
+	Start(actorID, userID uint, tokenID, reason, ip string) (actor *models.User, user *models.User, err error)
+	CheckActor(actorID uint) error
+	Record(entry *models.ImpersonationLog) error
+	ListLogs(userID uint, page, pageSize int) ([]models.ImpersonationLog, int64, error)
+}
+
+type impersonationService struct {
+	authRepo repositories.AuthRepository
+	logRepo  repositories.ImpersonationLogRepository
+}
+
+// NewImpersonationService creates a new instance of ImpersonationService
+func NewImpersonationService(authRepo repositories.AuthRepository, logRepo repositories.ImpersonationLogRepository) ImpersonationService {
+	return &impersonationService{authRepo: authRepo, logRepo: logRepo}
+}
+
+// Start checks that the actor may impersonate the user and records why before
+// the caller issues the token identified by tokenID. Disabled users and
+// other admins cannot be impersonated.
+func (s *impersonationService) Start(actorID, userID uint, tokenID, reason, ip string) (*models.User, *models.User, error) {
+	actor, err := s.authRepo.FindUserByID(actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.DisabledAt != nil || contains(user.PermissionNames(), models.PermUsersAdmin) {
+		return nil, nil, errors.ErrCannotImpersonate
+	}
+
+	err = s.logRepo.Create(&models.ImpersonationLog{
+		ActorID: actor.ID,
+		UserID:  user.ID,
+		TokenID: tokenID,
+		Reason:  reason,
+		IP:      ip,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return actor, user, nil
+}
+
+// CheckActor returns errors.ErrActorNotAdmin if the admin acting as another
+// user has since been deleted, disabled or lost the admin permission, which
+// ends the impersonation even though its token is still valid
+func (s *impersonationService) CheckActor(actorID uint) error {
+	actor, err := s.authRepo.FindUserByID(actorID)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return errors.ErrActorNotAdmin
+	}
+	if err != nil {
+		return err
+	}
+	if actor.DisabledAt != nil || !contains(actor.PermissionNames(), models.PermUsersAdmin) {
+		return errors.ErrActorNotAdmin
+	}
+	return nil
+}
+
+// Record adds a request made with an impersonation token to the audit trail
+func (s *impersonationService) Record(entry *models.ImpersonationLog) error {
+	return s.logRepo.Create(entry)
+}
+
+// ListLogs returns a page of the audit trail, optionally limited to the
+// impersonations of one user
+func (s *impersonationService) ListLogs(userID uint, page, pageSize int) ([]models.ImpersonationLog, int64, error) {
+	return s.logRepo.List(userID, page, pageSize)
+}
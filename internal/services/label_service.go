@@ -0,0 +1,98 @@
+package services
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+)
+
+// LabelService manages the labels of a workspace. Label names are unique
+// within a workspace regardless of case. Labels are shared by all projects,
+// so a workspace restricted to some projects cannot change them.
+type LabelService interface {
+	CreateLabel(ws models.Workspace, request models.LabelRequest) (*models.Label, error)
+	ListLabels(ws models.Workspace) ([]models.Label, error)
+	UpdateLabel(ws models.Workspace, id uint, request models.LabelRequest) (*models.Label, error)
+	DeleteLabel(ws models.Workspace, id uint) error
+}
+
+type labelService struct {
+	repo repositories.LabelRepository
+}
+
+// NewLabelService creates a new instance of LabelService
+func NewLabelService(repo repositories.LabelRepository) LabelService {
+	return &labelService{repo: repo}
+}
+
+func (s *labelService) CreateLabel(ws models.Workspace, request models.LabelRequest) (*models.Label, error) {
+	if ws.Restricted() {
+		return nil, errors.ErrProjectRestricted
+	}
+	if err := s.checkNameFree(ws, request.Name, 0); err != nil {
+		return nil, err
+	}
+
+	label := &models.Label{
+		UserID: ws.UserID,
+		Name:   request.Name,
+		Color:  request.Color,
+	}
+	if ws.OrganizationID != 0 {
+		organizationID := ws.OrganizationID
+		label.OrganizationID = &organizationID
+	}
+	if err := s.repo.Create(label); err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
+func (s *labelService) ListLabels(ws models.Workspace) ([]models.Label, error) {
+	return s.repo.List(ws)
+}
+
+// UpdateLabel renames or recolors the label. Todos refer to labels by ID, so
+// the change shows on every todo with the label.
+func (s *labelService) UpdateLabel(ws models.Workspace, id uint, request models.LabelRequest) (*models.Label, error) {
+	if ws.Restricted() {
+		return nil, errors.ErrProjectRestricted
+	}
+	label, err := s.repo.FindByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNameFree(ws, request.Name, id); err != nil {
+		return nil, err
+	}
+
+	label.Name = request.Name
+	label.Color = request.Color
+	if err := s.repo.Update(label); err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
+func (s *labelService) DeleteLabel(ws models.Workspace, id uint) error {
+	if ws.Restricted() {
+		return errors.ErrProjectRestricted
+	}
+	return s.repo.Delete(ws, id)
+}
+
+// checkNameFree returns errors.ErrLabelExists if a label other than the one
+// with ID except already has the name
+func (s *labelService) checkNameFree(ws models.Workspace, name string, except uint) error {
+	existing, err := s.repo.FindByName(ws, name)
+	if errors.Is(err, errors.ErrResourceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != except {
+		return errors.ErrLabelExists
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/config"
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+// LoginThrottleService tracks failed logins per account and per client IP and
+// locks either out for an exponentially growing period once a limit is reached
+type LoginThrottleService interface {
+	Check(name, ip string) error
+	RecordFailure(name, ip string) error
+	RecordSuccess(name string) error
+	UnlockUser(userID uint) error
+}
+
+type loginThrottleService struct {
+	repo            repositories.LoginThrottleRepository
+	authRepo        repositories.AuthRepository
+	accountMaxFails int
+	ipMaxFails      int
+	window          time.Duration
+	lockoutBase     time.Duration
+	lockoutMax      time.Duration
+	now             func() time.Time
+}
+
+// NewLoginThrottleService creates a new instance of LoginThrottleService
+func NewLoginThrottleService(repo repositories.LoginThrottleRepository, authRepo repositories.AuthRepository, cfg *config.Config) LoginThrottleService {
+	return &loginThrottleService{
+		repo:            repo,
+		authRepo:        authRepo,
+		accountMaxFails: cfg.LoginAccountMaxFails,
+		ipMaxFails:      cfg.LoginIPMaxFails,
+		window:          cfg.LoginFailureWindow,
+		lockoutBase:     cfg.LoginLockoutBase,
+		lockoutMax:      cfg.LoginLockoutMax,
+		now:             time.Now,
+	}
+}
+
+// Check returns an *errors.LockoutError if the account or the IP is locked
+func (s *loginThrottleService) Check(name, ip string) error {
+	account := models.AccountThrottleSubject(name)
+	throttles, err := s.repo.Find(account, models.IPThrottleSubject(ip))
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	var lockout *errors.LockoutError
+	for _, throttle := range throttles {
+		if throttle.LockedUntil == nil || !throttle.LockedUntil.After(now) {
+			continue
+		}
+		if throttle.Subject == account {
+			return &errors.LockoutError{Err: errors.ErrAccountLocked, RetryAfter: throttle.LockedUntil.Sub(now)}
+		}
+		lockout = &errors.LockoutError{Err: errors.ErrTooManyAttempts, RetryAfter: throttle.LockedUntil.Sub(now)}
+	}
+	if lockout != nil {
+		return lockout
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login for the account name and the IP
+func (s *loginThrottleService) RecordFailure(name, ip string) error {
+	if err := s.recordFailure(models.AccountThrottleSubject(name), s.accountMaxFails); err != nil {
+		return err
+	}
+	return s.recordFailure(models.IPThrottleSubject(ip), s.ipMaxFails)
+}
+
+// RecordSuccess clears the failures of the account. Failures of the IP are
+// kept so that one valid account cannot be used to reset the counter while
+// guessing passwords for others.
+func (s *loginThrottleService) RecordSuccess(name string) error {
+	return s.repo.Reset(models.AccountThrottleSubject(name))
+}
+
+// UnlockUser clears the failures and any lockout of the user's account
+func (s *loginThrottleService) UnlockUser(userID uint) error {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.Reset(models.AccountThrottleSubject(user.Name))
+}
+
+func (s *loginThrottleService) recordFailure(subject string, maxFails int) error {
+	if maxFails <= 0 {
+		return nil
+	}
+
+	now := s.now()
+	throttle, err := s.repo.RecordFailure(subject, now, now.Add(-s.window))
+	if err != nil {
+		return err
+	}
+	if throttle.Failures < maxFails {
+		return nil
+	}
+
+	lockout := s.lockoutDuration(throttle.Failures - maxFails)
+	log.Warn().Str("subject", subject).Int("failures", throttle.Failures).Dur("lockout", lockout).Msg("Locking out login after repeated failures")
+	return s.repo.Lock(subject, now.Add(lockout))
+}
+
+// lockoutDuration doubles the base lockout for every failure past the limit
+func (s *loginThrottleService) lockoutDuration(excess int) time.Duration {
+	lockout := s.lockoutBase
+	for i := 0; i < excess && lockout < s.lockoutMax; i++ {
+		lockout *= 2
+	}
+	if lockout > s.lockoutMax {
+		lockout = s.lockoutMax
+	}
+	return lockout
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ repositories.LoginThrottleRepository = (*fakeLoginThrottleRepository)(nil)
+
+// fakeLoginThrottleRepository keeps throttles in memory with the same reset
+// rule as the SQL of loginThrottleRepository.RecordFailure
+type fakeLoginThrottleRepository struct {
+	throttles map[string]*models.LoginThrottle
+}
+
+func newFakeLoginThrottleRepository() *fakeLoginThrottleRepository {
+	return &fakeLoginThrottleRepository{throttles: map[string]*models.LoginThrottle{}}
+}
+
+func (r *fakeLoginThrottleRepository) Find(subjects ...string) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	for _, subject := range subjects {
+		if throttle, ok := r.throttles[subject]; ok {
+			throttles = append(throttles, *throttle)
+		}
+	}
+	return throttles, nil
+}
+
+func (r *fakeLoginThrottleRepository) RecordFailure(subject string, now time.Time, resetBefore time.Time) (*models.LoginThrottle, error) {
+	throttle, ok := r.throttles[subject]
+	if !ok {
+		throttle = &models.LoginThrottle{Subject: subject}
+		r.throttles[subject] = throttle
+	}
+
+	latest := throttle.LastFailureAt
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(latest) {
+		latest = *throttle.LockedUntil
+	}
+	if latest.Before(resetBefore) {
+		throttle.Failures = 1
+	} else {
+		throttle.Failures++
+	}
+	throttle.LastFailureAt = now
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *fakeLoginThrottleRepository) Lock(subject string, until time.Time) error {
+	if throttle, ok := r.throttles[subject]; ok {
+		throttle.LockedUntil = &until
+	}
+	return nil
+}
+
+func (r *fakeLoginThrottleRepository) Reset(subject string) error {
+	delete(r.throttles, subject)
+	return nil
+}
+
+// testThrottle returns a throttle locking accounts after 3 failures and IPs
+// after 5 within 15 minutes, whose clock is moved with the returned function
+func testThrottle() (*loginThrottleService, *fakeLoginThrottleRepository, func(time.Duration)) {
+	repo := newFakeLoginThrottleRepository()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &loginThrottleService{
+		repo:            repo,
+		accountMaxFails: 3,
+		ipMaxFails:      5,
+		window:          15 * time.Minute,
+		lockoutBase:     time.Minute,
+		lockoutMax:      time.Hour,
+		now:             func() time.Time { return now },
+	}
+	return s, repo, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLockoutDuration(t *testing.T) {
+	s, _, _ := testThrottle()
+
+	testCases := []struct {
+		excess   int
+		expected time.Duration
+	}{
+		{excess: 0, expected: time.Minute},
+		{excess: 1, expected: 2 * time.Minute},
+		{excess: 3, expected: 8 * time.Minute},
+		{excess: 5, expected: 32 * time.Minute},
+		{excess: 6, expected: time.Hour},
+		{excess: 100, expected: time.Hour},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, s.lockoutDuration(tc.excess), "excess %d", tc.excess)
+	}
+}
+
+func TestAccountLockout(t *testing.T) {
+	s, _, advance := testThrottle()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	}
+	assert.NoError(t, s.Check("jane", "10.0.0.1"))
+
+	require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	err := s.Check("jane", "10.0.0.2")
+	var lockout *errors.LockoutError
+	require.True(t, errors.As(err, &lockout))
+	assert.True(t, errors.Is(err, errors.ErrAccountLocked))
+	assert.Equal(t, time.Minute, lockout.RetryAfter)
+	assert.NoError(t, s.Check("john", "10.0.0.2"))
+
+	advance(time.Minute)
+	assert.NoError(t, s.Check("jane", "10.0.0.1"))
+
+	// The count goes on after the lockout ends, doubling the next one
+	require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	err = s.Check("jane", "10.0.0.1")
+	require.True(t, errors.As(err, &lockout))
+	assert.Equal(t, 2*time.Minute, lockout.RetryAfter)
+}
+
+func TestIPLockout(t *testing.T) {
+	s, _, _ := testThrottle()
+
+	names := []string{"alice", "bob", "carol", "dave", "erin"}
+	for _, name := range names {
+		require.NoError(t, s.RecordFailure(name, "10.0.0.1"))
+	}
+
+	err := s.Check("frank", "10.0.0.1")
+	assert.True(t, errors.Is(err, errors.ErrTooManyAttempts))
+	assert.NoError(t, s.Check("frank", "10.0.0.2"))
+}
+
+func TestFailuresExpireAfterWindow(t *testing.T) {
+	s, repo, advance := testThrottle()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	}
+	advance(15*time.Minute + time.Second)
+
+	require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	assert.NoError(t, s.Check("jane", "10.0.0.1"))
+	assert.Equal(t, 1, repo.throttles[models.AccountThrottleSubject("jane")].Failures)
+
+	// A lockout that ended more than the window ago starts the count over
+	for i := 0; i < 2; i++ {
+		require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	}
+	require.Error(t, s.Check("jane", "10.0.0.1"))
+	advance(time.Minute + 15*time.Minute + time.Second)
+
+	require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	assert.NoError(t, s.Check("jane", "10.0.0.1"))
+	assert.Equal(t, 1, repo.throttles[models.AccountThrottleSubject("jane")].Failures)
+}
+
+func TestSuccessResetsAccountOnly(t *testing.T) {
+	s, repo, _ := testThrottle()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	}
+	require.NoError(t, s.RecordSuccess("jane"))
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, s.RecordFailure("jane", "10.0.0.1"))
+	}
+	assert.NoError(t, s.Check("jane", "10.0.0.1"))
+	assert.Equal(t, 2, repo.throttles[models.AccountThrottleSubject("jane")].Failures)
+
+	// The IP keeps counting, so the next failure from it locks it out
+	assert.Equal(t, 4, repo.throttles[models.IPThrottleSubject("10.0.0.1")].Failures)
+	require.NoError(t, s.RecordFailure("john", "10.0.0.1"))
+	assert.True(t, errors.Is(s.Check("john", "10.0.0.1"), errors.ErrTooManyAttempts))
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/netf/gofiber-boilerplate/internal/totp"
+)
+
+const recoveryCodeCount = 10
+
+// MFAService manages TOTP two-factor authentication
+type MFAService interface {
+	BeginEnrollment(userID uint) (*models.MFAEnrollResponse, error)
+	ConfirmEnrollment(userID uint, code string) ([]string, error)
+	Disable(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	VerifyLogin(userID uint, code string) (*models.User, error)
+}
+
+type mfaService struct {
+	authRepo     repositories.AuthRepository
+	recoveryRepo repositories.RecoveryCodeRepository
+	issuer       string
+}
+
+// NewMFAService creates a new instance of MFAService. The issuer is shown
+// next to the account name in authenticator apps.
+func NewMFAService(authRepo repositories.AuthRepository, recoveryRepo repositories.RecoveryCodeRepository, issuer string) MFAService {
+	return &mfaService{authRepo: authRepo, recoveryRepo: recoveryRepo, issuer: issuer}
+}
+
+// BeginEnrollment generates a new secret for the user. It only takes effect
+// once a code for it is confirmed with ConfirmEnrollment.
+func (s *mfaService) BeginEnrollment(userID uint) (*models.MFAEnrollResponse, error) {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPPendingSecret = secret
+	if err := s.authRepo.UpdateTOTP(user); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(secret, s.issuer, user.Name),
+	}, nil
+}
+
+// ConfirmEnrollment enables two-factor authentication if code is valid for
+// the pending secret and returns a fresh set of recovery codes
+func (s *mfaService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.ErrMFAAlreadyEnabled
+	}
+	if user.TOTPPendingSecret == "" {
+		return nil, errors.ErrMFANotEnabled
+	}
+
+	step, ok := totp.Validate(user.TOTPPendingSecret, code, time.Now())
+	if !ok {
+		return nil, errors.ErrInvalidMFACode
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPSecret = user.TOTPPendingSecret
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = step
+	if err := s.authRepo.UpdateTOTP(user); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(userID)
+}
+
+// Disable turns two-factor authentication off after checking a current code
+func (s *mfaService) Disable(userID uint, code string) error {
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCode(user, code); err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = 0
+	if err := s.authRepo.UpdateTOTP(user); err != nil {
+		return err
+	}
+
+	return s.recoveryRepo.DeleteForUser(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current code
+func (s *mfaService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCode(user, code); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(userID)
+}
+
+// VerifyLogin completes a login that returned errors.ErrMFARequired. The
+// code may be a TOTP code or an unused recovery code.
+func (s *mfaService) VerifyLogin(userID uint, code string) (*models.User, error) {
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, errors.ErrAccountDisabled
+	}
+	if err := s.checkCode(user, code); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *mfaService) enabledUser(userID uint) (*models.User, error) {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.ErrMFANotEnabled
+	}
+	return user, nil
+}
+
+// checkCode accepts a TOTP code that has not been used before or consumes a recovery code
+func (s *mfaService) checkCode(user *models.User, code string) error {
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		advanced, err := s.authRepo.AdvanceTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return errors.ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	return s.recoveryRepo.Use(user.ID, hashToken(normalizeRecoveryCode(code)))
+}
+
+func (s *mfaService) newRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(b) // 8 characters
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(code)
+	}
+
+	if err := s.recoveryRepo.Replace(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode tolerates lower case input and missing or extra separators
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+)
+
+// oauthClientIDPrefix makes client IDs recognisable
+const oauthClientIDPrefix = "gfbc_"
+
+// OAuthGrant is what an access token is issued for: a user, the client
+// acting on the user's behalf and the permissions the client was granted
+type OAuthGrant struct {
+	User     *models.User
+	ClientID string
+	Scopes   []string
+}
+
+// OAuthService implements an OAuth 2.0 authorization server with the
+// authorization code grant, which requires PKCE, and the client credentials
+// grant
+type OAuthService interface {
+	CreateClient(request models.CreateOAuthClientRequest) (*models.OAuthClient, error)
+	ListClients() ([]models.OAuthClient, error)
+	RevokeClient(id uint) error
+	Authorize(userID uint, request models.OAuthAuthorizeRequest) (*models.OAuthConsentResponse, error)
+	Decide(userID uint, request models.OAuthAuthorizeRequest) (string, error)
+	ExchangeCode(request models.OAuthTokenRequest) (*OAuthGrant, error)
+	ClientCredentials(request models.OAuthTokenRequest) (*OAuthGrant, error)
+}
+
+type oauthService struct {
+	clientRepo repositories.OAuthClientRepository
+	codeRepo   repositories.OAuthCodeRepository
+	authRepo   repositories.AuthRepository
+	codeTTL    time.Duration
+}
+
+// NewOAuthService creates a new instance of OAuthService
+func NewOAuthService(clientRepo repositories.OAuthClientRepository, codeRepo repositories.OAuthCodeRepository, authRepo repositories.AuthRepository, codeTTL time.Duration) OAuthService {
+	return &oauthService{
+		clientRepo: clientRepo,
+		codeRepo:   codeRepo,
+		authRepo:   authRepo,
+		codeTTL:    codeTTL,
+	}
+}
+
+// CreateClient registers a client. Confidential clients get a secret, which
+// is only available in the Secret field of the result.
+func (s *oauthService) CreateClient(request models.CreateOAuthClientRequest) (*models.OAuthClient, error) {
+	known := make(map[string]bool, len(models.DefaultPermissions))
+	for _, permission := range models.DefaultPermissions {
+		known[permission.Name] = true
+	}
+	for _, scope := range request.Scopes {
+		if !known[scope] {
+			return nil, fmt.Errorf("%w: unknown scope %q", errors.ErrInvalidInput, scope)
+		}
+	}
+
+	for _, uri := range request.RedirectURIs {
+		if u, err := url.Parse(uri); err != nil || u.Fragment != "" {
+			return nil, fmt.Errorf("%w: invalid redirect URI %q", errors.ErrInvalidInput, uri)
+		}
+	}
+
+	if request.UserID != nil {
+		if !request.Confidential {
+			return nil, fmt.Errorf("%w: only confidential clients can act as a user", errors.ErrInvalidInput)
+		}
+		if _, err := s.authRepo.FindUserByID(*request.UserID); err != nil {
+			if errors.Is(err, errors.ErrUserNotFound) {
+				return nil, fmt.Errorf("%w: user %d not found", errors.ErrInvalidInput, *request.UserID)
+			}
+			return nil, err
+		}
+	}
+
+	clientID, _, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     oauthClientIDPrefix + clientID[:24],
+		Name:         request.Name,
+		RedirectURIs: request.RedirectURIs,
+		Scopes:       request.Scopes,
+		Confidential: request.Confidential,
+		UserID:       request.UserID,
+	}
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+
+	if client.Confidential {
+		secret, secretHash, err := newOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		client.Secret = secret
+		client.SecretHash = secretHash
+	}
+
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s *oauthService) ListClients() ([]models.OAuthClient, error) {
+	return s.clientRepo.List()
+}
+
+// RevokeClient stops the client from obtaining new tokens. Access tokens
+// already issued stay valid until they expire.
+func (s *oauthService) RevokeClient(id uint) error {
+	return s.clientRepo.Revoke(id)
+}
+
+// Authorize validates an authorization request of the user and returns what
+// the user must be asked to consent to
+func (s *oauthService) Authorize(userID uint, request models.OAuthAuthorizeRequest) (*models.OAuthConsentResponse, error) {
+	client, redirectURI, err := s.validateAuthorization(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OAuthConsentResponse{
+		ClientID:    client.ClientID,
+		ClientName:  client.Name,
+		RedirectURI: redirectURI,
+		Scopes:      grantableScopes(parseScope(request.Scope, client.Scopes), user.PermissionNames()),
+	}, nil
+}
+
+// Decide records the user's decision on an authorization request and returns
+// the client redirect URI to send the user to. When the user approves it
+// carries a new authorization code, otherwise an access_denied error.
+func (s *oauthService) Decide(userID uint, request models.OAuthAuthorizeRequest) (string, error) {
+	client, redirectURI, err := s.validateAuthorization(&request)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	if request.State != "" {
+		params.Set("state", request.State)
+	}
+
+	if !request.Approve {
+		params.Set("error", errors.OAuthAccessDenied)
+		return withQuery(redirectURI, params), nil
+	}
+
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := grantableScopes(parseScope(request.Scope, client.Scopes), user.PermissionNames())
+	if len(scopes) == 0 {
+		params.Set("error", errors.OAuthInvalidScope)
+		params.Set("error_description", "none of the requested scopes are granted to the user")
+		return withQuery(redirectURI, params), nil
+	}
+
+	code, codeHash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.codeRepo.Create(&models.OAuthAuthorizationCode{
+		CodeHash:      codeHash,
+		ClientID:      client.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		CodeChallenge: request.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.codeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params.Set("code", code)
+	return withQuery(redirectURI, params), nil
+}
+
+// validateAuthorization checks an authorization request and returns the
+// client and the redirect URI to use. A request without redirect_uri uses
+// the client's only registered URI.
+func (s *oauthService) validateAuthorization(request *models.OAuthAuthorizeRequest) (*models.OAuthClient, string, error) {
+	client, err := s.clientRepo.FindByClientID(request.ClientID)
+	if err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return nil, "", errors.NewOAuthError(errors.OAuthInvalidClient, "unknown client")
+		}
+		return nil, "", err
+	}
+	if client.RevokedAt != nil {
+		return nil, "", errors.NewOAuthError(errors.OAuthInvalidClient, "unknown client")
+	}
+
+	redirectURI := request.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.HasRedirectURI(redirectURI) {
+		return nil, "", errors.NewOAuthError(errors.OAuthInvalidRequest, "redirect_uri is not registered for the client")
+	}
+
+	if request.ResponseType != "code" {
+		return nil, "", errors.NewOAuthError(errors.OAuthUnsupportedResponseType, "only the code response type is supported")
+	}
+	if request.CodeChallenge == "" || request.CodeChallengeMethod != "S256" {
+		return nil, "", errors.NewOAuthError(errors.OAuthInvalidRequest, "a code_challenge with method S256 is required")
+	}
+	for _, scope := range parseScope(request.Scope, nil) {
+		if !contains(client.Scopes, scope) {
+			return nil, "", errors.NewOAuthError(errors.OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed for the client", scope))
+		}
+	}
+
+	return client, redirectURI, nil
+}
+
+// ExchangeCode redeems an authorization code. The permissions granted are
+// checked against the user's current permissions.
+func (s *oauthService) ExchangeCode(request models.OAuthTokenRequest) (*OAuthGrant, error) {
+	client, err := s.authenticateClient(request)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.codeRepo.Consume(hashToken(request.Code))
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidToken) {
+			return nil, errors.NewOAuthError(errors.OAuthInvalidGrant, "invalid or expired code")
+		}
+		return nil, err
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != request.RedirectURI {
+		return nil, errors.NewOAuthError(errors.OAuthInvalidGrant, "code was not issued to this client or redirect_uri")
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, request.CodeVerifier) {
+		return nil, errors.NewOAuthError(errors.OAuthInvalidGrant, "invalid code_verifier")
+	}
+
+	return s.grant(client, code.UserID, code.Scopes)
+}
+
+// ClientCredentials issues a grant to a confidential client acting as its
+// configured user
+func (s *oauthService) ClientCredentials(request models.OAuthTokenRequest) (*OAuthGrant, error) {
+	client, err := s.authenticateClient(request)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential || client.UserID == nil {
+		return nil, errors.NewOAuthError(errors.OAuthUnauthorizedClient, "client credentials grant is not enabled for the client")
+	}
+
+	scopes := parseScope(request.Scope, client.Scopes)
+	for _, scope := range scopes {
+		if !contains(client.Scopes, scope) {
+			return nil, errors.NewOAuthError(errors.OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed for the client", scope))
+		}
+	}
+
+	return s.grant(client, *client.UserID, scopes)
+}
+
+func (s *oauthService) grant(client *models.OAuthClient, userID uint, scopes []string) (*OAuthGrant, error) {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			return nil, errors.NewOAuthError(errors.OAuthInvalidGrant, "user no longer exists")
+		}
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, errors.NewOAuthError(errors.OAuthInvalidGrant, "user is disabled")
+	}
+
+	return &OAuthGrant{
+		User:     user,
+		ClientID: client.ClientID,
+		Scopes:   grantableScopes(scopes, user.PermissionNames()),
+	}, nil
+}
+
+// authenticateClient resolves the client of a token request. Confidential
+// clients must present their secret.
+func (s *oauthService) authenticateClient(request models.OAuthTokenRequest) (*models.OAuthClient, error) {
+	invalid := errors.NewOAuthError(errors.OAuthInvalidClient, "client authentication failed")
+
+	client, err := s.clientRepo.FindByClientID(request.ClientID)
+	if err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if client.RevokedAt != nil {
+		return nil, invalid
+	}
+
+	if client.Confidential {
+		presented := hashToken(request.ClientSecret)
+		if request.ClientSecret == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(client.SecretHash)) != 1 {
+			return nil, invalid
+		}
+	}
+	return client, nil
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against its S256 challenge
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// parseScope splits a space separated scope parameter, returning fallback
+// when it is empty
+func parseScope(scope string, fallback []string) []string {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return fallback
+	}
+	return scopes
+}
+
+// grantableScopes returns the scopes the user holds the permission for
+func grantableScopes(scopes, permissions []string) []string {
+	granted := []string{}
+	for _, scope := range scopes {
+		if contains(permissions, scope) && !contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// withQuery adds params to the query of uri, keeping any it already has
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
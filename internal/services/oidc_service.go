@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/oidc"
+	"github.com/netf/gofiber-boilerplate/internal/password"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+// externalLoginTimeout bounds the requests made to a provider during a login
+const externalLoginTimeout = 10 * time.Second
+
+// invalidNameChars matches the characters dropped when deriving a user name
+// from an external identity
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// OIDCService logs users in with external identity providers
+type OIDCService interface {
+	Providers() []string
+	BeginLogin(ctx context.Context, provider string) (authURL, state string, err error)
+	CompleteLogin(ctx context.Context, provider, state, code string) (*models.User, error)
+	ListIdentities(userID uint) ([]models.UserIdentity, error)
+	UnlinkIdentity(userID, id uint) error
+}
+
+type oidcService struct {
+	providers    map[string]*oidc.Provider
+	names        []string
+	stateRepo    repositories.OAuthStateRepository
+	identityRepo repositories.IdentityRepository
+	authRepo     repositories.AuthRepository
+	roleRepo     repositories.RoleRepository
+	hasher       *password.Hasher
+	stateTTL     time.Duration
+}
+
+// NewOIDCService creates a new instance of OIDCService
+func NewOIDCService(providers []*oidc.Provider, stateRepo repositories.OAuthStateRepository, identityRepo repositories.IdentityRepository, authRepo repositories.AuthRepository, roleRepo repositories.RoleRepository, hasher *password.Hasher, stateTTL time.Duration) OIDCService {
+	s := &oidcService{
+		providers:    make(map[string]*oidc.Provider, len(providers)),
+		names:        []string{},
+		stateRepo:    stateRepo,
+		identityRepo: identityRepo,
+		authRepo:     authRepo,
+		roleRepo:     roleRepo,
+		hasher:       hasher,
+		stateTTL:     stateTTL,
+	}
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
+		s.names = append(s.names, provider.Name())
+	}
+	return s
+}
+
+// Providers returns the names of the configured providers
+func (s *oidcService) Providers() []string {
+	return s.names
+}
+
+// BeginLogin records a new login with provider and returns the URL the user
+// must be sent to together with the state of the login. The caller must tie
+// the state to the user's browser and only complete the login from there.
+// errors.ErrExternalLoginFailed is returned if the provider cannot be
+// reached.
+func (s *oidcService) BeginLogin(ctx context.Context, provider string) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", errors.ErrUnknownProvider
+	}
+
+	state, stateHash, err := newOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, externalLoginTimeout)
+	defer cancel()
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", provider).Msg("Login provider unavailable")
+		return "", "", errors.ErrExternalLoginFailed
+	}
+
+	now := time.Now()
+	if err := s.stateRepo.DeleteExpired(now); err != nil {
+		log.Error().Err(err).Msg("Failed to delete expired login states")
+	}
+
+	err = s.stateRepo.Create(&models.OAuthState{
+		StateHash:    stateHash,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(s.stateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteLogin redeems the code the provider redirected back with and
+// returns the user it identifies.
+//
+// Known identities log in their linked user. Otherwise the identity is linked
+// to the user with the same email address if both the provider and this
+// application have verified it, and a new user is created if there is none.
+// An existing user whose email address is unverified is never linked and
+// errors.ErrUserAlreadyExists is returned instead.
+//
+// Like AuthService.AuthenticateUser, disabled users are rejected and users
+// with two-factor authentication are returned with errors.ErrMFARequired.
+func (s *oidcService) CompleteLogin(ctx context.Context, provider, state, code string) (*models.User, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, errors.ErrUnknownProvider
+	}
+
+	pending, err := s.stateRepo.Consume(hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if pending.Provider != provider {
+		return nil, errors.ErrInvalidToken
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, externalLoginTimeout)
+	defer cancel()
+
+	external, err := p.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", provider).Msg("External login failed")
+		return nil, errors.ErrExternalLoginFailed
+	}
+
+	user, err := s.resolveUser(provider, external)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DisabledAt != nil {
+		return nil, errors.ErrAccountDisabled
+	}
+	if user.TOTPEnabled {
+		return user, errors.ErrMFARequired
+	}
+
+	return user, nil
+}
+
+// resolveUser finds or creates the user an external identity belongs to
+func (s *oidcService) resolveUser(provider string, external *oidc.Identity) (*models.User, error) {
+	identity, err := s.identityRepo.Find(provider, external.Subject)
+	if err == nil {
+		if err := s.identityRepo.TouchLastLogin(identity.ID, time.Now()); err != nil {
+			log.Error().Err(err).Uint("identity_id", identity.ID).Msg("Failed to record identity login")
+		}
+		user, err := s.authRepo.FindUserByID(identity.UserID)
+		if errors.Is(err, errors.ErrUserNotFound) {
+			// The linked user was deleted
+			return nil, errors.ErrAccountDisabled
+		}
+		return user, err
+	}
+	if !errors.Is(err, errors.ErrResourceNotFound) {
+		return nil, err
+	}
+
+	if external.Email == "" || !external.EmailVerified {
+		return nil, errors.ErrEmailNotVerified
+	}
+
+	now := time.Now()
+	identity = &models.UserIdentity{
+		Provider:    provider,
+		Subject:     external.Subject,
+		Email:       external.Email,
+		LastLoginAt: &now,
+	}
+
+	user, err := s.authRepo.FindUserByEmail(external.Email)
+	if err == nil {
+		if user.EmailVerifiedAt == nil {
+			return nil, errors.ErrUserAlreadyExists
+		}
+		identity.UserID = user.ID
+		if err := s.identityRepo.Create(identity); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, errors.ErrUserNotFound) {
+		return nil, err
+	}
+
+	return s.createUser(identity, external)
+}
+
+// createUser registers a new user for an external identity. The user gets a
+// random password, which can be replaced through a password reset.
+func (s *oidcService) createUser(identity *models.UserIdentity, external *oidc.Identity) (*models.User, error) {
+	name, err := s.availableName(external)
+	if err != nil {
+		return nil, err
+	}
+
+	randomPassword, _, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.hasher.Hash(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.roleRepo.FindByName(models.RoleUser)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Name:            name,
+		Pass:            hashedPassword,
+		Email:           external.Email,
+		EmailVerifiedAt: &now,
+		Roles:           []models.Role{{ID: role.ID, Name: role.Name}},
+	}
+	if err := s.identityRepo.CreateWithUser(user, identity); err != nil {
+		return nil, err
+	}
+
+	// Reload to get the permissions of the role
+	return s.authRepo.FindUserByID(user.ID)
+}
+
+// availableName derives an unused user name from the username or email
+// address of an external identity
+func (s *oidcService) availableName(external *oidc.Identity) (string, error) {
+	base := external.Username
+	if base == "" {
+		base, _, _ = strings.Cut(external.Email, "@")
+	}
+	base = invalidNameChars.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+
+	name := base
+	for attempt := 0; attempt < 5; attempt++ {
+		_, err := s.authRepo.FindUserByName(name)
+		if errors.Is(err, errors.ErrUserNotFound) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s-%04d", base, suffix.Int64())
+	}
+	return "", errors.ErrUserAlreadyExists
+}
+
+func (s *oidcService) ListIdentities(userID uint) ([]models.UserIdentity, error) {
+	return s.identityRepo.ListForUser(userID)
+}
+
+func (s *oidcService) UnlinkIdentity(userID, id uint) error {
+	return s.identityRepo.Delete(userID, id)
+}
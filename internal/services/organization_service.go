@@ -0,0 +1,370 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/mailer"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+// OrganizationService manages organizations, their members and invitations.
+// Organizations the user is not a member of are reported as
+// errors.ErrResourceNotFound, and actions the user's role does not allow as
+// errors.ErrInsufficientRole.
+type OrganizationService interface {
+	CreateOrganization(userID uint, name string) (*models.Organization, error)
+	ListOrganizations(userID uint) ([]models.Organization, error)
+	GetOrganization(userID, id uint) (*models.Organization, error)
+	DeleteOrganization(userID, id uint) error
+	ListMembers(userID, id uint) ([]models.OrganizationMember, error)
+	SetMemberRole(userID, id, memberID uint, role string) error
+	RemoveMember(userID, id, memberID uint) error
+	Invite(userID, id uint, email, role string) (*models.OrganizationInvitation, error)
+	ListInvitations(userID, id uint) ([]models.OrganizationInvitation, error)
+	RevokeInvitation(userID, id, invitationID uint) error
+	AcceptInvitation(userID uint, token string) (*models.Organization, error)
+	DeclineInvitation(userID uint, token string) error
+	SwitchOrganization(userID uint, sessionID string, organizationID uint) (*models.User, error)
+	IsMember(organizationID, userID uint) (bool, error)
+}
+
+type organizationService struct {
+	repo           repositories.OrganizationRepository
+	invitationRepo repositories.InvitationRepository
+	sessionRepo    repositories.SessionRepository
+	authRepo       repositories.AuthRepository
+	mailer         mailer.Mailer
+	appURL         string
+	inviteTTL      time.Duration
+}
+
+// NewOrganizationService creates a new instance of OrganizationService.
+// Invitations link to appURL and expire after inviteTTL.
+func NewOrganizationService(repo repositories.OrganizationRepository, invitationRepo repositories.InvitationRepository, sessionRepo repositories.SessionRepository, authRepo repositories.AuthRepository, mail mailer.Mailer, appURL string, inviteTTL time.Duration) OrganizationService {
+	return &organizationService{
+		repo:           repo,
+		invitationRepo: invitationRepo,
+		sessionRepo:    sessionRepo,
+		authRepo:       authRepo,
+		mailer:         mail,
+		appURL:         strings.TrimSuffix(appURL, "/"),
+		inviteTTL:      inviteTTL,
+	}
+}
+
+// CreateOrganization creates an organization with the user as its owner
+func (s *organizationService) CreateOrganization(userID uint, name string) (*models.Organization, error) {
+	organization := &models.Organization{Name: name}
+	if err := s.repo.Create(organization, userID); err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (s *organizationService) ListOrganizations(userID uint) ([]models.Organization, error) {
+	return s.repo.ListForUser(userID)
+}
+
+func (s *organizationService) GetOrganization(userID, id uint) (*models.Organization, error) {
+	member, err := s.requireRole(userID, id, models.OrgRoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	organization, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	organization.Role = member.Role
+	return organization, nil
+}
+
+// DeleteOrganization deletes the organization with its todos. Only owners may
+// delete it. Sessions acting in it fall back to the personal space.
+func (s *organizationService) DeleteOrganization(userID, id uint) error {
+	if _, err := s.requireRole(userID, id, models.OrgRoleOwner); err != nil {
+		return err
+	}
+
+	members, err := s.repo.ListMembers(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	for _, member := range members {
+		s.leaveSessions(id, member.UserID)
+	}
+	return nil
+}
+
+func (s *organizationService) ListMembers(userID, id uint) ([]models.OrganizationMember, error) {
+	if _, err := s.requireRole(userID, id, models.OrgRoleMember); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(id)
+}
+
+// SetMemberRole changes the role of a member. Only owners may change roles,
+// and the last owner cannot step down.
+func (s *organizationService) SetMemberRole(userID, id, memberID uint, role string) error {
+	if _, ok := models.OrgRoleRank[role]; !ok {
+		return errors.ErrInvalidInput
+	}
+	if _, err := s.requireRole(userID, id, models.OrgRoleOwner); err != nil {
+		return err
+	}
+
+	member, err := s.repo.FindMember(id, memberID)
+	if err != nil {
+		return err
+	}
+	if member.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+		if err := s.keepOwner(id); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.UpdateMemberRole(id, memberID, role)
+}
+
+// RemoveMember removes a member from the organization. Every member may
+// leave; removing others requires admin, and removing owners or admins
+// requires owner. The last owner cannot leave.
+func (s *organizationService) RemoveMember(userID, id, memberID uint) error {
+	actor, err := s.requireRole(userID, id, models.OrgRoleMember)
+	if err != nil {
+		return err
+	}
+
+	member := actor
+	if memberID != userID {
+		if member, err = s.repo.FindMember(id, memberID); err != nil {
+			return err
+		}
+		required := models.OrgRoleAdmin
+		if member.Role != models.OrgRoleMember {
+			required = models.OrgRoleOwner
+		}
+		if !hasOrgRole(actor, required) {
+			return errors.ErrInsufficientRole
+		}
+	}
+
+	if member.Role == models.OrgRoleOwner {
+		if err := s.keepOwner(id); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.RemoveMember(id, memberID); err != nil {
+		return err
+	}
+	s.leaveSessions(id, memberID)
+	return nil
+}
+
+// Invite emails an invitation to join the organization with the role.
+// Admins may invite members and admins, owners may also invite owners. The
+// plaintext token is only available in the Token field of the result.
+func (s *organizationService) Invite(userID, id uint, email, role string) (*models.OrganizationInvitation, error) {
+	if _, ok := models.OrgRoleRank[role]; !ok {
+		return nil, errors.ErrInvalidInput
+	}
+	actor, err := s.requireRole(userID, id, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !hasOrgRole(actor, role) {
+		return nil, errors.ErrInsufficientRole
+	}
+
+	organization, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	inviter, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	invitation := &models.OrganizationInvitation{
+		OrganizationID: id,
+		Email:          email,
+		Role:           role,
+		InvitedByID:    userID,
+		TokenHash:      tokenHash,
+		ExpiresAt:      time.Now().Add(s.inviteTTL),
+		Token:          token,
+	}
+	if err := s.invitationRepo.Create(invitation); err != nil {
+		return nil, err
+	}
+	invitation.Organization = *organization
+
+	err = s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "You have been invited to " + organization.Name,
+		Body: "Hi,\n\n" +
+			inviter.Name + " has invited you to join " + organization.Name + ". Follow the link below to accept or decline the invitation:\n\n" +
+			s.appURL + "/invitations?token=" + url.QueryEscape(token) + "\n\n" +
+			"The link expires in " + s.inviteTTL.String() + ". You need an account with this email address to accept it.\n",
+	})
+	if err != nil {
+		log.Error().Err(err).Uint("invitation_id", invitation.ID).Msg("Failed to send invitation email")
+	}
+
+	return invitation, nil
+}
+
+func (s *organizationService) ListInvitations(userID, id uint) ([]models.OrganizationInvitation, error) {
+	if _, err := s.requireRole(userID, id, models.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.invitationRepo.ListPending(id)
+}
+
+func (s *organizationService) RevokeInvitation(userID, id, invitationID uint) error {
+	if _, err := s.requireRole(userID, id, models.OrgRoleAdmin); err != nil {
+		return err
+	}
+	return s.invitationRepo.Delete(id, invitationID)
+}
+
+// AcceptInvitation makes the user a member of the organization the invitation
+// is for. The invitation must have been sent to the user's verified email
+// address.
+func (s *organizationService) AcceptInvitation(userID uint, token string) (*models.Organization, error) {
+	invitation, user, err := s.invitationFor(userID, token)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt == nil {
+		return nil, errors.ErrEmailNotVerified
+	}
+
+	member := &models.OrganizationMember{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+	}
+	if err := s.invitationRepo.Accept(invitation, member); err != nil {
+		return nil, err
+	}
+
+	organization := invitation.Organization
+	organization.Role = invitation.Role
+	return &organization, nil
+}
+
+// DeclineInvitation declines an invitation sent to the user's email address
+func (s *organizationService) DeclineInvitation(userID uint, token string) error {
+	invitation, _, err := s.invitationFor(userID, token)
+	if err != nil {
+		return err
+	}
+	return s.invitationRepo.Decline(invitation.ID)
+}
+
+// SwitchOrganization makes the organization the active one of the session,
+// or the personal space if organizationID is 0, and returns the user to issue
+// a new access token for
+func (s *organizationService) SwitchOrganization(userID uint, sessionID string, organizationID uint) (*models.User, error) {
+	var active *uint
+	if organizationID != 0 {
+		if _, err := s.requireRole(userID, organizationID, models.OrgRoleMember); err != nil {
+			return nil, err
+		}
+		active = &organizationID
+	}
+
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, errors.ErrAccountDisabled
+	}
+
+	if err := s.sessionRepo.SetOrganization(userID, sessionID, active); err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// IsMember reports whether the user is a member of the organization
+func (s *organizationService) IsMember(organizationID, userID uint) (bool, error) {
+	_, err := s.repo.FindMember(organizationID, userID)
+	if errors.Is(err, errors.ErrResourceNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// requireRole returns the user's membership if the user has at least the role in the organization
+func (s *organizationService) requireRole(userID, id uint, role string) (*models.OrganizationMember, error) {
+	member, err := s.repo.FindMember(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasOrgRole(member, role) {
+		return nil, errors.ErrInsufficientRole
+	}
+	return member, nil
+}
+
+// keepOwner fails with errors.ErrLastOwner unless the organization has
+// another owner besides the one about to be removed or demoted
+func (s *organizationService) keepOwner(id uint) error {
+	owners, err := s.repo.CountOwners(id)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errors.ErrLastOwner
+	}
+	return nil
+}
+
+// invitationFor returns the pending invitation with the token if it was sent
+// to the user's email address, together with the user
+func (s *organizationService) invitationFor(userID uint, token string) (*models.OrganizationInvitation, *models.User, error) {
+	invitation, err := s.invitationRepo.FindPending(hashToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, nil, errors.ErrInvitationMismatch
+	}
+	return invitation, user, nil
+}
+
+// leaveSessions switches the user's sessions in the organization back to the
+// personal space. Failing to do so is only logged since the organization
+// claim is checked against the membership on every request anyway.
+func (s *organizationService) leaveSessions(id, userID uint) {
+	if err := s.sessionRepo.ClearOrganization(id, userID); err != nil {
+		log.Error().Err(err).Uint("organization_id", id).Uint("user_id", userID).Msg("Failed to reset sessions of former member")
+	}
+}
+
+func hasOrgRole(member *models.OrganizationMember, role string) bool {
+	return models.OrgRoleRank[member.Role] >= models.OrgRoleRank[role]
+}
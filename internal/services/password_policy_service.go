@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/password"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+)
+
+// PasswordPolicyService checks new passwords against the password policy and
+// remembers the passwords users have had to prevent reusing them
+type PasswordPolicyService interface {
+	Validate(user *models.User, newPassword string) error
+	Record(userID uint, hash []byte) error
+}
+
+type passwordPolicyService struct {
+	policy      password.Policy
+	historyRepo repositories.PasswordHistoryRepository
+	hasher      *password.Hasher
+	historySize int
+}
+
+// NewPasswordPolicyService creates a new instance of PasswordPolicyService.
+// The last historySize passwords of a user cannot be reused; 0 allows reuse.
+func NewPasswordPolicyService(policy password.Policy, historyRepo repositories.PasswordHistoryRepository, hasher *password.Hasher, historySize int) PasswordPolicyService {
+	return &passwordPolicyService{
+		policy:      policy,
+		historyRepo: historyRepo,
+		hasher:      hasher,
+		historySize: historySize,
+	}
+}
+
+// Validate returns an *errors.PasswordPolicyError listing every rule the new
+// password breaks. user is the account the password is for; a user without
+// an ID is being registered and has no previous passwords.
+func (s *passwordPolicyService) Validate(user *models.User, newPassword string) error {
+	reasons, err := s.policy.Check(newPassword, user.Name, user.Email)
+	if err != nil {
+		return err
+	}
+
+	if user.ID != 0 && s.historySize > 0 {
+		reused, err := s.reused(user, newPassword)
+		if err != nil {
+			return err
+		}
+		if reused {
+			reasons = append(reasons, fmt.Sprintf("must not be one of your last %d passwords", s.historySize))
+		}
+	}
+
+	if len(reasons) > 0 {
+		return &errors.PasswordPolicyError{Reasons: reasons}
+	}
+	return nil
+}
+
+// Record adds the hash of a password that was just set to the user's history
+func (s *passwordPolicyService) Record(userID uint, hash []byte) error {
+	if s.historySize == 0 {
+		return nil
+	}
+	return s.historyRepo.Add(&models.PasswordHistory{UserID: userID, Hash: hash}, s.historySize)
+}
+
+// reused reports whether newPassword matches the user's current password or
+// one of the recorded ones. The current password is checked separately since
+// it was not recorded if it was set before the history was enabled.
+func (s *passwordPolicyService) reused(user *models.User, newPassword string) (bool, error) {
+	entries, err := s.historyRepo.Recent(user.ID, s.historySize)
+	if err != nil {
+		return false, err
+	}
+
+	hashes := make([][]byte, 0, len(entries)+1)
+	if len(entries) == 0 || !bytes.Equal(entries[0].Hash, user.Pass) {
+		hashes = append(hashes, user.Pass)
+	}
+	for _, entry := range entries {
+		hashes = append(hashes, entry.Hash)
+	}
+	if len(hashes) > s.historySize {
+		hashes = hashes[:s.historySize]
+	}
+
+	for _, hash := range hashes {
+		if ok, _, err := s.hasher.Verify(newPassword, hash); err == nil && ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
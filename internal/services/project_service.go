@@ -0,0 +1,121 @@
+package services
+
+import (
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+)
+
+// ProjectService manages the projects of a workspace. Projects are returned
+// with the counts of their todos. A workspace restricted to some projects
+// can neither create projects nor delete them.
+type ProjectService interface {
+	CreateProject(ws models.Workspace, request models.ProjectRequest) (*models.Project, error)
+	GetProject(ws models.Workspace, id uint) (*models.Project, error)
+	ListProjects(ws models.Workspace, includeArchived bool) ([]models.Project, error)
+	UpdateProject(ws models.Workspace, id uint, request models.ProjectRequest) (*models.Project, error)
+	DeleteProject(ws models.Workspace, id uint) error
+}
+
+type projectService struct {
+	repo repositories.ProjectRepository
+}
+
+// NewProjectService creates a new instance of ProjectService
+func NewProjectService(repo repositories.ProjectRepository) ProjectService {
+	return &projectService{repo: repo}
+}
+
+func (s *projectService) CreateProject(ws models.Workspace, request models.ProjectRequest) (*models.Project, error) {
+	if ws.Restricted() {
+		return nil, errors.ErrProjectRestricted
+	}
+
+	project := &models.Project{
+		UserID:      ws.UserID,
+		Name:        request.Name,
+		Description: request.Description,
+		Color:       request.Color,
+		Archived:    request.Archived,
+	}
+	if ws.OrganizationID != 0 {
+		organizationID := ws.OrganizationID
+		project.OrganizationID = &organizationID
+	}
+	if err := s.repo.Create(project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (s *projectService) GetProject(ws models.Workspace, id uint) (*models.Project, error) {
+	project, err := s.repo.FindByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []models.Project{*project}
+	if err := s.count(ws, projects); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+func (s *projectService) ListProjects(ws models.Workspace, includeArchived bool) ([]models.Project, error) {
+	projects, err := s.repo.List(ws, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.count(ws, projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// UpdateProject replaces the name, description and color of the project and
+// archives or restores it
+func (s *projectService) UpdateProject(ws models.Workspace, id uint, request models.ProjectRequest) (*models.Project, error) {
+	project, err := s.repo.FindByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+
+	project.Name = request.Name
+	project.Description = request.Description
+	project.Color = request.Color
+	project.Archived = request.Archived
+	if err := s.repo.Update(project); err != nil {
+		return nil, err
+	}
+
+	projects := []models.Project{*project}
+	if err := s.count(ws, projects); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+// DeleteProject deletes the project and keeps its todos without a project
+func (s *projectService) DeleteProject(ws models.Workspace, id uint) error {
+	if ws.Restricted() {
+		return errors.ErrProjectRestricted
+	}
+	return s.repo.Delete(ws, id)
+}
+
+// count fills in the todo counts of the projects
+func (s *projectService) count(ws models.Workspace, projects []models.Project) error {
+	ids := make([]uint, len(projects))
+	for i, project := range projects {
+		ids[i] = project.ID
+	}
+
+	counts, err := s.repo.CountTodos(ws, ids)
+	if err != nil {
+		return err
+	}
+	for i := range projects {
+		projects[i].Counts = counts[projects[i].ID]
+	}
+	return nil
+}
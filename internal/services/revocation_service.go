@@ -0,0 +1,170 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/rs/zerolog/log"
+)
+
+// RevocationService tracks access tokens that were revoked before they expired
+type RevocationService interface {
+	RevokeToken(jti string, userID uint, expiresAt time.Time) error
+	RevokeSession(userID uint, sessionID string) error
+	RevokeAllForUser(userID uint) error
+	IsRevoked(jti string, sessionID string, userID uint, issuedAt time.Time) (bool, error)
+}
+
+// revocationService keeps an in-memory copy of the revocation tables so that
+// checking a token does not cost a query per request. The copy is refreshed
+// incrementally once it is older than syncInterval, which bounds how long a
+// revocation made on another instance can go unnoticed.
+type revocationService struct {
+	repo         repositories.RevocationRepository
+	sessionRepo  repositories.SessionRepository
+	syncInterval time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]time.Time // jti -> token expiry
+	sessions map[string]time.Time // session ID -> session expiry
+	users    map[uint]time.Time   // user ID -> tokens issued before are revoked
+	lastSync time.Time
+}
+
+// NewRevocationService creates a new instance of RevocationService
+func NewRevocationService(repo repositories.RevocationRepository, sessionRepo repositories.SessionRepository, syncInterval time.Duration) RevocationService {
+	return &revocationService{
+		repo:         repo,
+		sessionRepo:  sessionRepo,
+		syncInterval: syncInterval,
+		tokens:       make(map[string]time.Time),
+		sessions:     make(map[string]time.Time),
+		users:        make(map[uint]time.Time),
+	}
+}
+
+// RevokeToken revokes a single access token until it expires
+func (s *revocationService) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	if err := s.repo.RevokeToken(&models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeSession revokes one of the user's sessions and with it every access
+// token carrying its ID. It returns errors.ErrResourceNotFound if the user
+// has no such active session.
+func (s *revocationService) RevokeSession(userID uint, sessionID string) error {
+	session, err := s.sessionRepo.Revoke(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.sessions[session.ID] = session.ExpiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeAllForUser revokes every access token and session of the user so far
+func (s *revocationService) RevokeAllForUser(userID uint) error {
+	now := time.Now()
+	if err := s.repo.RevokeAllForUser(userID, now); err != nil {
+		return err
+	}
+	// The tokens are already revoked by time above; this only updates the session list
+	if err := s.sessionRepo.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[userID] = now
+	s.mu.Unlock()
+	return nil
+}
+
+// IsRevoked reports whether the token identified by jti, issued to the user
+// at issuedAt for the session, has been revoked
+func (s *revocationService) IsRevoked(jti string, sessionID string, userID uint, issuedAt time.Time) (bool, error) {
+	if err := s.syncIfStale(); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+	if _, ok := s.sessions[sessionID]; ok && sessionID != "" {
+		return true, nil
+	}
+	// issuedAt has second precision, so a token issued within the same second
+	// as the revocation is treated as revoked
+	if before, ok := s.users[userID]; ok && !issuedAt.After(before) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *revocationService) syncIfStale() error {
+	s.mu.RLock()
+	fresh := !s.lastSync.IsZero() && time.Since(s.lastSync) < s.syncInterval
+	s.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Overlap with the previous sync so rows committed while it ran are not missed
+	since := s.lastSync.Add(-time.Second)
+	now := time.Now()
+
+	if err := s.repo.DeleteExpired(now); err != nil {
+		log.Error().Err(err).Msg("Failed to delete expired token revocations")
+	}
+
+	tokens, err := s.repo.ListRevokedTokensSince(since)
+	if err != nil {
+		return err
+	}
+	users, err := s.repo.ListUserRevocationsSince(since)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.sessionRepo.ListRevokedSince(since, now)
+	if err != nil {
+		return err
+	}
+
+	for jti, expiresAt := range s.tokens {
+		if expiresAt.Before(now) {
+			delete(s.tokens, jti)
+		}
+	}
+	for id, expiresAt := range s.sessions {
+		if expiresAt.Before(now) {
+			delete(s.sessions, id)
+		}
+	}
+	for _, token := range tokens {
+		s.tokens[token.JTI] = token.ExpiresAt
+	}
+	for _, session := range sessions {
+		s.sessions[session.ID] = session.ExpiresAt
+	}
+	for _, user := range users {
+		s.users[user.UserID] = user.RevokedBefore
+	}
+
+	s.lastSync = now
+	return nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+)
+
+// SessionService lists and revokes the login sessions of a user
+type SessionService interface {
+	ListSessions(userID uint) ([]models.Session, error)
+	RevokeSession(userID uint, sessionID string) error
+}
+
+type sessionService struct {
+	sessionRepo repositories.SessionRepository
+	refreshRepo repositories.RefreshTokenRepository
+	revocations RevocationService
+}
+
+// NewSessionService creates a new instance of SessionService
+func NewSessionService(sessionRepo repositories.SessionRepository, refreshRepo repositories.RefreshTokenRepository, revocations RevocationService) SessionService {
+	return &sessionService{sessionRepo: sessionRepo, refreshRepo: refreshRepo, revocations: revocations}
+}
+
+// ListSessions returns the user's sessions that are neither revoked nor expired
+func (s *sessionService) ListSessions(userID uint) ([]models.Session, error) {
+	return s.sessionRepo.ListActive(userID, time.Now())
+}
+
+// RevokeSession ends one of the user's sessions. Its access tokens are
+// rejected from then on and its refresh tokens can no longer be used.
+func (s *sessionService) RevokeSession(userID uint, sessionID string) error {
+	if err := s.revocations.RevokeSession(userID, sessionID); err != nil {
+		return err
+	}
+	return s.refreshRepo.RevokeFamily(sessionID)
+}
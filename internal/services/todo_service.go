@@ -1,42 +1,659 @@
 package services
 
 import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
 	"github.com/netf/gofiber-boilerplate/internal/models"
 	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/netf/gofiber-boilerplate/internal/rrule"
+	"gorm.io/gorm"
 )
 
+// dateFormat is the format of the dates of skipped occurrences
+const dateFormat = "2006-01-02"
+
+// TodoService defines the todo operations available to an authenticated user.
+// All methods take the workspace of the request and never touch todos outside
+// of it: another user's personal todos or those of another organization.
 type TodoService interface {
-	CreateTodo(todo *models.Todo) error
-	GetTodoByID(id uint) (*models.Todo, error)
-	UpdateTodo(todo *models.Todo) error
-	DeleteTodo(id uint) error
-	ListTodos(page, pageSize int) ([]models.Todo, int64, error)
+	CreateTodo(ws models.Workspace, todo *models.Todo) error
+	GetTodoByID(ws models.Workspace, id uint) (*models.Todo, error)
+	UpdateTodo(ws models.Workspace, todo *models.Todo) error
+	DeleteTodo(ws models.Workspace, id uint) error
+	ListTodos(ws models.Workspace, filter models.TodoFilter, page, pageSize int) ([]models.Todo, int64, error)
+	MoveTodo(ws models.Workspace, id uint, projectID *uint) (*models.Todo, error)
+	SetParent(ws models.Workspace, id uint, parentID *uint) (*models.Todo, error)
+	GetSubtree(ws models.Workspace, id uint) (*models.Todo, error)
+	SkipOccurrence(ws models.Workspace, id uint) (*models.Todo, error)
+	ListOccurrences(ws models.Workspace, id uint, n int) ([]models.TodoOccurrence, error)
 }
 
 type todoService struct {
-	repo repositories.TodoRepository
+	repo        repositories.TodoRepository
+	labelRepo   repositories.LabelRepository
+	projectRepo repositories.ProjectRepository
+	maxDepth    int
+}
+
+// NewTodoService creates a new instance of TodoService. Subtasks can be
+// nested up to maxDepth levels below a top-level todo.
+func NewTodoService(repo repositories.TodoRepository, labelRepo repositories.LabelRepository, projectRepo repositories.ProjectRepository, maxDepth int) TodoService {
+	return &todoService{repo: repo, labelRepo: labelRepo, projectRepo: projectRepo, maxDepth: maxDepth}
+}
+
+// todoTransitions lists the statuses each status can change to. Keeping the
+// current status is always allowed.
+var todoTransitions = map[string][]string{
+	models.TodoStatusTodo:       {models.TodoStatusInProgress, models.TodoStatusBlocked, models.TodoStatusDone, models.TodoStatusCancelled},
+	models.TodoStatusInProgress: {models.TodoStatusTodo, models.TodoStatusBlocked, models.TodoStatusDone, models.TodoStatusCancelled},
+	models.TodoStatusBlocked:    {models.TodoStatusTodo, models.TodoStatusInProgress, models.TodoStatusCancelled},
+	models.TodoStatusDone:       {models.TodoStatusTodo, models.TodoStatusInProgress},
+	models.TodoStatusCancelled:  {models.TodoStatusTodo},
+}
+
+// CreateTodo stores the todo in the workspace with the requesting user as its owner
+func (s *todoService) CreateTodo(ws models.Workspace, todo *models.Todo) error {
+	todo.ID = 0
+	todo.UserID = ws.UserID
+	todo.OrganizationID = nil
+	if ws.OrganizationID != 0 {
+		organizationID := ws.OrganizationID
+		todo.OrganizationID = &organizationID
+	}
+
+	if todo.Status == "" {
+		todo.Status = models.TodoStatusTodo
+		if todo.Completed {
+			todo.Status = models.TodoStatusDone
+		}
+	}
+	todo.CompletedAt = nil
+	setStatus(todo, todo.Status, "")
+	if err := checkTodo(todo); err != nil {
+		return err
+	}
+	if _, err := recurrence(todo, nil); err != nil {
+		return err
+	}
+	if err := s.attachLabels(ws, todo, nil); err != nil {
+		return err
+	}
+	if todo.ProjectID != nil {
+		if err := s.checkProject(ws, *todo.ProjectID); err != nil {
+			return err
+		}
+	} else if ws.Restricted() {
+		return errors.ErrProjectRestricted
+	}
+	if todo.ParentID != nil {
+		if err := s.checkParent(ws, 0, *todo.ParentID, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.Create(todo); err != nil {
+		return err
+	}
+	localize(todo)
+	return nil
+}
+
+// GetTodoByID returns the todo with the progress of its subtasks
+func (s *todoService) GetTodoByID(ws models.Workspace, id uint) (*models.Todo, error) {
+	todo, err := s.repo.GetByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	subtree, err := s.repo.Subtree(ws, id)
+	if err != nil {
+		return nil, err
+	}
+
+	todo.Progress = progress(subtree)
+	localize(todo)
+	return todo, nil
+}
+
+// GetSubtree returns the todo with its subtasks nested below it, each with
+// the progress of its own subtasks
+func (s *todoService) GetSubtree(ws models.Workspace, id uint) (*models.Todo, error) {
+	todo, err := s.repo.GetByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	subtree, err := s.repo.Subtree(ws, id)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[uint][]models.Todo{}
+	for _, subtask := range subtree {
+		children[*subtask.ParentID] = append(children[*subtask.ParentID], subtask)
+	}
+	nest(todo, children)
+	return todo, nil
+}
+
+// nest attaches the subtasks of the todo and their own subtasks from
+// children, which maps todo IDs to their direct subtasks
+func nest(todo *models.Todo, children map[uint][]models.Todo) []models.Todo {
+	todo.Subtasks = children[todo.ID]
+	subtree := []models.Todo{}
+	for i := range todo.Subtasks {
+		subtree = append(subtree, todo.Subtasks[i])
+		subtree = append(subtree, nest(&todo.Subtasks[i], children)...)
+	}
+	todo.Progress = progress(subtree)
+	localize(todo)
+	return subtree
 }
 
-func NewTodoService(repo repositories.TodoRepository) TodoService {
-	return &todoService{repo}
+// UpdateTodo replaces the todo. A status change must be one of
+// todoTransitions; without a status, completed marks the todo done or
+// reopens it as clients that predate statuses expect.
+func (s *todoService) UpdateTodo(ws models.Workspace, todo *models.Todo) error {
+	existing, err := s.repo.GetByID(ws, todo.ID)
+	if err != nil {
+		return err
+	}
+
+	status := todo.Status
+	if status == "" {
+		status = existing.Status
+		wasDone := existing.Status == models.TodoStatusDone
+		switch {
+		case todo.Completed && !wasDone:
+			status = models.TodoStatusDone
+		case !todo.Completed && wasDone:
+			status = models.TodoStatusTodo
+		}
+	}
+	if status != existing.Status && !contains(todoTransitions[existing.Status], status) {
+		return &errors.TodoStatusError{From: existing.Status, To: status}
+	}
+
+	todo.UserID = existing.UserID
+	todo.OrganizationID = existing.OrganizationID
+	todo.ProjectID = existing.ProjectID
+	todo.ParentID = existing.ParentID
+	todo.CreatedAt = existing.CreatedAt
+	todo.CompletedAt = existing.CompletedAt
+	setStatus(todo, status, existing.Status)
+	// Like its labels, the todo keeps its recurrence unless the update sets
+	// it. A kept rule also keeps the dates it recurs from when the update has
+	// none, as clients that only know completed send.
+	if todo.Recurrence == nil && existing.Recurrence != nil {
+		todo.Recurrence = existing.Recurrence
+		if todo.StartAt == nil && todo.DueAt == nil {
+			todo.StartAt = existing.StartAt
+			todo.DueAt = existing.DueAt
+			if todo.TimeZone == "" {
+				todo.TimeZone = existing.TimeZone
+			}
+		}
+	}
+	if err := checkTodo(todo); err != nil {
+		return err
+	}
+	if todo.RecurrenceExceptions == nil {
+		todo.RecurrenceExceptions = existing.RecurrenceExceptions
+	}
+	rule, err := recurrence(todo, existing)
+	if err != nil {
+		return err
+	}
+	if err := s.attachLabels(ws, todo, existing.Labels); err != nil {
+		return err
+	}
+
+	// Completing a recurring todo hands its rule over to the todo for the
+	// next occurrence, so that completing it again does not repeat this. No
+	// todo is added to an archived project; the completed todo keeps its rule.
+	var next *models.Todo
+	if rule != nil && todo.Status == models.TodoStatusDone && existing.Status != models.TodoStatusDone {
+		archived, err := s.inArchivedProject(ws, todo)
+		if err != nil {
+			return err
+		}
+		if occurrences := upcoming(todo, rule, 1); len(occurrences) > 0 && !archived {
+			next = nextTodo(todo, occurrences[0])
+			todo.Recurrence = nil
+			todo.RecurrenceStart = nil
+			todo.RecurrenceExceptions = nil
+		}
+	}
+
+	// Finishing a todo finishes its open subtasks the same way
+	var cascade []uint
+	if closed(todo.Status) && !closed(existing.Status) {
+		subtree, err := s.repo.Subtree(ws, todo.ID)
+		if err != nil {
+			return err
+		}
+		for _, subtask := range subtree {
+			if closed(subtask.Status) {
+				continue
+			}
+			if subtask.Status == models.TodoStatusBlocked && todo.Status == models.TodoStatusDone {
+				return errors.ErrBlockedSubtask
+			}
+			cascade = append(cascade, subtask.ID)
+		}
+	}
+
+	if err := s.repo.Save(ws, todo, cascade, next); err != nil {
+		return err
+	}
+	if next != nil {
+		localize(next)
+		todo.Next = next
+	}
+	localize(todo)
+	return nil
+}
+
+// DeleteTodo deletes the todo together with its subtasks
+func (s *todoService) DeleteTodo(ws models.Workspace, id uint) error {
+	return s.repo.Delete(ws, id)
+}
+
+// ListTodos lists the todos matching the filter. Listing the todos of a
+// project that is not in the workspace returns errors.ErrUnknownProject.
+func (s *todoService) ListTodos(ws models.Workspace, filter models.TodoFilter, page, pageSize int) ([]models.Todo, int64, error) {
+	if filter.ProjectID != 0 {
+		if _, err := s.projectRepo.FindByID(ws, filter.ProjectID); err != nil {
+			if errors.Is(err, errors.ErrResourceNotFound) {
+				return nil, 0, errors.ErrUnknownProject
+			}
+			return nil, 0, err
+		}
+	}
+
+	todos, total, err := s.repo.List(ws, filter, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range todos {
+		localize(&todos[i])
+	}
+	return todos, total, nil
 }
 
-func (s *todoService) CreateTodo(todo *models.Todo) error {
-	return s.repo.Create(todo)
+// MoveTodo moves the todo into the project, or out of its project if
+// projectID is nil. Todos cannot be moved into archived projects, nor out of
+// the projects a restricted workspace is limited to.
+func (s *todoService) MoveTodo(ws models.Workspace, id uint, projectID *uint) (*models.Todo, error) {
+	todo, err := s.repo.GetByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	if projectID != nil {
+		if err := s.checkProject(ws, *projectID); err != nil {
+			return nil, err
+		}
+	} else if ws.Restricted() {
+		return nil, errors.ErrProjectRestricted
+	}
+
+	todo.ProjectID = projectID
+	if err := s.repo.Update(ws, todo); err != nil {
+		return nil, err
+	}
+	localize(todo)
+	return todo, nil
+}
+
+// SetParent makes the todo a subtask of the todo with ID parentID, or a
+// top-level todo if parentID is nil. The todo keeps its own subtasks.
+func (s *todoService) SetParent(ws models.Workspace, id uint, parentID *uint) (*models.Todo, error) {
+	todo, err := s.repo.GetByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	if parentID != nil {
+		subtree, err := s.repo.Subtree(ws, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkParent(ws, id, *parentID, height(id, subtree)); err != nil {
+			return nil, err
+		}
+	}
+
+	todo.ParentID = parentID
+	if err := s.repo.Update(ws, todo); err != nil {
+		return nil, err
+	}
+	localize(todo)
+	return todo, nil
+}
+
+// checkParent checks that the todo with ID id, whose subtasks are nested
+// height levels deep, can become a subtask of the todo with ID parentID. It
+// returns errors.ErrTodoCycle if the parent is the todo itself or one of its
+// subtasks and errors.ErrTodoTooDeep if the subtasks would be nested deeper
+// than maxDepth. New todos have ID 0.
+func (s *todoService) checkParent(ws models.Workspace, id, parentID uint, height int) error {
+	depth := 0
+	seen := map[uint]bool{}
+	for ancestorID := &parentID; ancestorID != nil && !seen[*ancestorID]; {
+		if *ancestorID == id {
+			return errors.ErrTodoCycle
+		}
+		seen[*ancestorID] = true
+		depth++
+
+		ancestor, err := s.repo.GetByID(ws, *ancestorID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.ErrUnknownParent
+		}
+		if err != nil {
+			return err
+		}
+		ancestorID = ancestor.ParentID
+	}
+
+	if depth+height > s.maxDepth {
+		return errors.ErrTodoTooDeep
+	}
+	return nil
+}
+
+// height returns how many levels of subtasks the subtree of the todo with ID
+// id has
+func height(id uint, subtree []models.Todo) int {
+	depths := map[uint]int{id: 0}
+	deepest := 0
+	// Subtree lists parents before their subtasks
+	for _, subtask := range subtree {
+		depth := depths[*subtask.ParentID] + 1
+		depths[subtask.ID] = depth
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+	return deepest
+}
+
+// progress counts the subtasks in subtree that are not cancelled and how many
+// of them are done
+func progress(subtree []models.Todo) *models.TodoProgress {
+	p := &models.TodoProgress{}
+	for _, subtask := range subtree {
+		switch subtask.Status {
+		case models.TodoStatusCancelled:
+		case models.TodoStatusDone:
+			p.Done++
+			p.Total++
+		default:
+			p.Total++
+		}
+	}
+	return p
+}
+
+// closed reports whether work on a todo with the status is finished
+func closed(status string) bool {
+	return status == models.TodoStatusDone || status == models.TodoStatusCancelled
+}
+
+// SkipOccurrence moves the recurring todo to its next occurrence without
+// completing it and records the skipped date as an exception
+func (s *todoService) SkipOccurrence(ws models.Workspace, id uint) (*models.Todo, error) {
+	todo, err := s.repo.GetByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := recurrence(todo, todo)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, errors.ErrNotRecurring
+	}
+
+	occurrences := upcoming(todo, rule, 1)
+	if len(occurrences) == 0 {
+		return nil, errors.ErrNoOccurrences
+	}
+
+	current := anchor(todo).In(location(todo)).Format(dateFormat)
+	if !contains(todo.RecurrenceExceptions, current) {
+		todo.RecurrenceExceptions = append(todo.RecurrenceExceptions, current)
+	}
+	todo.StartAt, todo.DueAt = shift(todo, occurrences[0])
+	if err := s.repo.Update(ws, todo); err != nil {
+		return nil, err
+	}
+	localize(todo)
+	return todo, nil
+}
+
+// ListOccurrences returns up to n occurrences of the recurring todo after
+// its current one
+func (s *todoService) ListOccurrences(ws models.Workspace, id uint, n int) ([]models.TodoOccurrence, error) {
+	todo, err := s.repo.GetByID(ws, id)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := recurrence(todo, todo)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, errors.ErrNotRecurring
+	}
+
+	occurrences := []models.TodoOccurrence{}
+	for _, t := range upcoming(todo, rule, n) {
+		startAt, dueAt := shift(todo, t)
+		occurrences = append(occurrences, models.TodoOccurrence{StartAt: startAt, DueAt: dueAt})
+	}
+	return occurrences, nil
+}
+
+// recurrence parses the recurrence rule of the todo, returning nil if it has
+// none, and sets the start of its series. The series of an existing todo
+// keeps its start unless the rule changes.
+func recurrence(todo, existing *models.Todo) (*rrule.Rule, error) {
+	if todo.Recurrence == nil || *todo.Recurrence == "" {
+		todo.Recurrence = nil
+		todo.RecurrenceStart = nil
+		todo.RecurrenceExceptions = nil
+		return nil, nil
+	}
+
+	rule, err := rrule.Parse(*todo.Recurrence)
+	if err != nil {
+		return nil, &errors.RecurrenceError{Reason: err.Error()}
+	}
+	start := anchor(todo)
+	if start == nil {
+		return nil, &errors.RecurrenceError{Reason: "due_at or start_at is required"}
+	}
+
+	if existing != nil && existing.Recurrence != nil && *existing.Recurrence == *todo.Recurrence && existing.RecurrenceStart != nil {
+		todo.RecurrenceStart = existing.RecurrenceStart
+	} else {
+		seriesStart := *start
+		todo.RecurrenceStart = &seriesStart
+	}
+	return rule, nil
+}
+
+// anchor returns the date the occurrences of a recurring todo fall on: its
+// due date, or its start date if it has none
+func anchor(todo *models.Todo) *time.Time {
+	if todo.DueAt != nil {
+		return todo.DueAt
+	}
+	return todo.StartAt
+}
+
+// location returns the time zone of the todo, UTC if it has none
+func location(todo *models.Todo) *time.Location {
+	if loc, err := time.LoadLocation(todo.TimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// upcoming returns up to n occurrences of the recurring todo after its
+// current one, leaving out skipped dates
+func upcoming(todo *models.Todo, rule *rrule.Rule, n int) []time.Time {
+	loc := location(todo)
+	current := anchor(todo).In(loc)
+	start := todo.RecurrenceStart.In(loc)
+
+	// Skipped dates can hide at most as many occurrences as there are
+	skipped := map[string]bool{}
+	for _, date := range todo.RecurrenceExceptions {
+		skipped[date] = true
+	}
+	occurrences := []time.Time{}
+	for _, t := range rule.After(start, current, n+len(skipped)) {
+		if !skipped[t.Format(dateFormat)] && len(occurrences) < n {
+			occurrences = append(occurrences, t)
+		}
+	}
+	return occurrences
+}
+
+// shift returns the start and due dates of the recurring todo moved to the
+// occurrence at t, keeping the time between them
+func shift(todo *models.Todo, t time.Time) (*time.Time, *time.Time) {
+	if todo.DueAt == nil {
+		return &t, nil
+	}
+	if todo.StartAt == nil {
+		return nil, &t
+	}
+	startAt := t.Add(-todo.DueAt.Sub(*todo.StartAt))
+	return &startAt, &t
+}
+
+// nextTodo returns a new todo for the occurrence of the recurring todo at t
+func nextTodo(todo *models.Todo, t time.Time) *models.Todo {
+	next := &models.Todo{
+		UserID:               todo.UserID,
+		OrganizationID:       todo.OrganizationID,
+		ProjectID:            todo.ProjectID,
+		ParentID:             todo.ParentID,
+		Title:                todo.Title,
+		Description:          todo.Description,
+		Status:               models.TodoStatusTodo,
+		Priority:             todo.Priority,
+		TimeZone:             todo.TimeZone,
+		Labels:               todo.Labels,
+		Recurrence:           todo.Recurrence,
+		RecurrenceStart:      todo.RecurrenceStart,
+		RecurrenceExceptions: todo.RecurrenceExceptions,
+	}
+	next.StartAt, next.DueAt = shift(todo, t)
+	return next
+}
+
+// checkProject returns errors.ErrUnknownProject if the project is not in the
+// workspace and errors.ErrProjectArchived if it is archived
+func (s *todoService) checkProject(ws models.Workspace, id uint) error {
+	project, err := s.projectRepo.FindByID(ws, id)
+	if errors.Is(err, errors.ErrResourceNotFound) {
+		return errors.ErrUnknownProject
+	}
+	if err != nil {
+		return err
+	}
+	if project.Archived {
+		return errors.ErrProjectArchived
+	}
+	return nil
+}
+
+// inArchivedProject reports whether the todo belongs to an archived project
+func (s *todoService) inArchivedProject(ws models.Workspace, todo *models.Todo) (bool, error) {
+	if todo.ProjectID == nil {
+		return false, nil
+	}
+	err := s.checkProject(ws, *todo.ProjectID)
+	if errors.Is(err, errors.ErrProjectArchived) {
+		return true, nil
+	}
+	return false, err
+}
+
+// attachLabels sets the labels of the todo to the workspace's labels named
+// by LabelIDs, or to current if LabelIDs is nil
+func (s *todoService) attachLabels(ws models.Workspace, todo *models.Todo, current []models.Label) error {
+	if todo.LabelIDs == nil {
+		todo.Labels = current
+		if todo.Labels == nil {
+			todo.Labels = []models.Label{}
+		}
+		return nil
+	}
+
+	labels, err := s.labelRepo.FindByIDs(ws, todo.LabelIDs)
+	if err != nil {
+		return err
+	}
+	for _, id := range todo.LabelIDs {
+		if !containsLabel(labels, id) {
+			return errors.ErrUnknownLabel
+		}
+	}
+	todo.Labels = labels
+	todo.LabelIDs = nil
+	return nil
 }
 
-func (s *todoService) GetTodoByID(id uint) (*models.Todo, error) {
-	return s.repo.GetByID(id)
+func containsLabel(labels []models.Label, id uint) bool {
+	for _, label := range labels {
+		if label.ID == id {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *todoService) UpdateTodo(todo *models.Todo) error {
-	return s.repo.Update(todo)
+// setStatus moves the todo from the previous status to status, deriving
+// Completed and stamping CompletedAt when it becomes done
+func setStatus(todo *models.Todo, status, previous string) {
+	todo.Status = status
+	todo.Completed = status == models.TodoStatusDone
+	switch {
+	case !todo.Completed:
+		todo.CompletedAt = nil
+	case previous != models.TodoStatusDone || todo.CompletedAt == nil:
+		now := time.Now()
+		todo.CompletedAt = &now
+	}
 }
 
-func (s *todoService) DeleteTodo(id uint) error {
-	return s.repo.Delete(id)
+// checkTodo fills in the default priority and rejects a todo that starts
+// after it is due
+func checkTodo(todo *models.Todo) error {
+	if todo.Priority == "" {
+		todo.Priority = models.TodoPriorityNone
+	}
+	if todo.StartAt != nil && todo.DueAt != nil && todo.StartAt.After(*todo.DueAt) {
+		return errors.ErrInvalidSchedule
+	}
+	return nil
 }
 
-func (s *todoService) ListTodos(page, pageSize int) ([]models.Todo, int64, error) {
-	return s.repo.List(page, pageSize)
+// localize expresses the dates of the todo in its time zone
+func localize(todo *models.Todo) {
+	if todo.TimeZone == "" {
+		return
+	}
+	loc, err := time.LoadLocation(todo.TimeZone)
+	if err != nil {
+		return
+	}
+	for _, t := range []*time.Time{todo.StartAt, todo.DueAt, todo.CompletedAt} {
+		if t != nil {
+			*t = t.In(loc)
+		}
+	}
 }
@@ -0,0 +1,779 @@
+package services
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var (
+	_ repositories.TodoRepository    = (*fakeTodoRepository)(nil)
+	_ repositories.ProjectRepository = (*fakeProjectRepository)(nil)
+	_ repositories.LabelRepository   = (*fakeLabelRepository)(nil)
+)
+
+// fakeTodoRepository keeps todos in memory, scoped to workspaces like
+// todoRepository. It hands out copies so that the service cannot change
+// stored todos without calling the repository.
+type fakeTodoRepository struct {
+	todos  map[uint]*models.Todo
+	nextID uint
+}
+
+func newFakeTodoRepository() *fakeTodoRepository {
+	return &fakeTodoRepository{todos: map[uint]*models.Todo{}, nextID: 1}
+}
+
+func inFakeWorkspace(todo *models.Todo, ws models.Workspace) bool {
+	if ws.OrganizationID != 0 {
+		if todo.OrganizationID == nil || *todo.OrganizationID != ws.OrganizationID {
+			return false
+		}
+	} else if todo.OrganizationID != nil || todo.UserID != ws.UserID {
+		return false
+	}
+	if ws.Restricted() {
+		if todo.ProjectID == nil {
+			return false
+		}
+		for _, id := range ws.ProjectIDs {
+			if id == *todo.ProjectID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func copyTodo(todo *models.Todo) *models.Todo {
+	copied := *todo
+	copied.Labels = append([]models.Label{}, todo.Labels...)
+	copied.RecurrenceExceptions = append([]string(nil), todo.RecurrenceExceptions...)
+	copied.LabelIDs = nil
+	copied.Next = nil
+	copied.Progress = nil
+	copied.Subtasks = nil
+	return &copied
+}
+
+func (r *fakeTodoRepository) Create(todo *models.Todo) error {
+	todo.ID = r.nextID
+	r.nextID++
+	todo.CreatedAt = time.Now()
+	r.todos[todo.ID] = copyTodo(todo)
+	return nil
+}
+
+func (r *fakeTodoRepository) get(ws models.Workspace, id uint) (*models.Todo, bool) {
+	todo, ok := r.todos[id]
+	if !ok || !inFakeWorkspace(todo, ws) {
+		return nil, false
+	}
+	return todo, true
+}
+
+func (r *fakeTodoRepository) GetByID(ws models.Workspace, id uint) (*models.Todo, error) {
+	todo, ok := r.get(ws, id)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return copyTodo(todo), nil
+}
+
+func (r *fakeTodoRepository) Update(ws models.Workspace, todo *models.Todo) error {
+	existing, ok := r.get(ws, todo.ID)
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	updated := copyTodo(todo)
+	updated.UserID = existing.UserID
+	updated.OrganizationID = existing.OrganizationID
+	updated.CreatedAt = existing.CreatedAt
+	r.todos[todo.ID] = updated
+	return nil
+}
+
+func (r *fakeTodoRepository) Save(ws models.Workspace, todo *models.Todo, subtaskIDs []uint, next *models.Todo) error {
+	if err := r.Update(ws, todo); err != nil {
+		return err
+	}
+	for _, id := range subtaskIDs {
+		if subtask, ok := r.get(ws, id); ok {
+			subtask.Status = todo.Status
+			subtask.Completed = todo.Completed
+			subtask.CompletedAt = todo.CompletedAt
+		}
+	}
+	if next != nil {
+		return r.Create(next)
+	}
+	return nil
+}
+
+func (r *fakeTodoRepository) Delete(ws models.Workspace, id uint) error {
+	if _, ok := r.get(ws, id); !ok {
+		return gorm.ErrRecordNotFound
+	}
+	subtree, _ := r.Subtree(ws, id)
+	delete(r.todos, id)
+	for _, subtask := range subtree {
+		delete(r.todos, subtask.ID)
+	}
+	return nil
+}
+
+func (r *fakeTodoRepository) Subtree(ws models.Workspace, id uint) ([]models.Todo, error) {
+	var subtree []models.Todo
+	parents := []uint{id}
+	for len(parents) > 0 {
+		var level []models.Todo
+		for _, todo := range r.todos {
+			if todo.ParentID != nil && containsID(parents, *todo.ParentID) && inFakeWorkspace(todo, ws) {
+				level = append(level, *copyTodo(todo))
+			}
+		}
+		sort.Slice(level, func(i, j int) bool { return level[i].ID < level[j].ID })
+
+		parents = nil
+		for _, todo := range level {
+			subtree = append(subtree, todo)
+			parents = append(parents, todo.ID)
+		}
+	}
+	return subtree, nil
+}
+
+func (r *fakeTodoRepository) List(ws models.Workspace, filter models.TodoFilter, page, pageSize int) ([]models.Todo, int64, error) {
+	var todos []models.Todo
+	for _, todo := range r.todos {
+		if inFakeWorkspace(todo, ws) && (filter.ProjectID == 0 || todo.ProjectID != nil && *todo.ProjectID == filter.ProjectID) {
+			todos = append(todos, *copyTodo(todo))
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos, int64(len(todos)), nil
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeProjectRepository keeps projects in memory. Projects are found in any
+// workspace.
+type fakeProjectRepository struct {
+	projects map[uint]*models.Project
+}
+
+func (r *fakeProjectRepository) Create(project *models.Project) error {
+	project.ID = uint(len(r.projects) + 1)
+	r.projects[project.ID] = project
+	return nil
+}
+
+func (r *fakeProjectRepository) FindByID(ws models.Workspace, id uint) (*models.Project, error) {
+	project, ok := r.projects[id]
+	if !ok || ws.Restricted() && !containsID(ws.ProjectIDs, id) {
+		return nil, errors.ErrResourceNotFound
+	}
+	copied := *project
+	return &copied, nil
+}
+
+func (r *fakeProjectRepository) List(ws models.Workspace, includeArchived bool) ([]models.Project, error) {
+	projects := []models.Project{}
+	for _, project := range r.projects {
+		if includeArchived || !project.Archived {
+			projects = append(projects, *project)
+		}
+	}
+	return projects, nil
+}
+
+func (r *fakeProjectRepository) Update(project *models.Project) error {
+	r.projects[project.ID] = project
+	return nil
+}
+
+func (r *fakeProjectRepository) Delete(ws models.Workspace, id uint) error {
+	delete(r.projects, id)
+	return nil
+}
+
+func (r *fakeProjectRepository) CountTodos(ws models.Workspace, ids []uint) (map[uint]models.ProjectCounts, error) {
+	return map[uint]models.ProjectCounts{}, nil
+}
+
+// fakeLabelRepository keeps labels in memory. Labels are found in any
+// workspace.
+type fakeLabelRepository struct {
+	labels []models.Label
+}
+
+func (r *fakeLabelRepository) Create(label *models.Label) error {
+	label.ID = uint(len(r.labels) + 1)
+	r.labels = append(r.labels, *label)
+	return nil
+}
+
+func (r *fakeLabelRepository) FindByID(ws models.Workspace, id uint) (*models.Label, error) {
+	for _, label := range r.labels {
+		if label.ID == id {
+			return &label, nil
+		}
+	}
+	return nil, errors.ErrResourceNotFound
+}
+
+func (r *fakeLabelRepository) FindByIDs(ws models.Workspace, ids []uint) ([]models.Label, error) {
+	labels := []models.Label{}
+	for _, label := range r.labels {
+		if containsID(ids, label.ID) {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+func (r *fakeLabelRepository) FindByName(ws models.Workspace, name string) (*models.Label, error) {
+	return nil, errors.ErrResourceNotFound
+}
+
+func (r *fakeLabelRepository) List(ws models.Workspace) ([]models.Label, error) {
+	return r.labels, nil
+}
+
+func (r *fakeLabelRepository) Update(label *models.Label) error {
+	return nil
+}
+
+func (r *fakeLabelRepository) Delete(ws models.Workspace, id uint) error {
+	return nil
+}
+
+var testWorkspace = models.Workspace{UserID: 1}
+
+// testTodos returns a todo service on fake repositories that nests subtasks
+// at most 3 levels deep
+func testTodos() (*todoService, *fakeTodoRepository, *fakeProjectRepository) {
+	repo := newFakeTodoRepository()
+	projects := &fakeProjectRepository{projects: map[uint]*models.Project{}}
+	s := &todoService{repo: repo, labelRepo: &fakeLabelRepository{}, projectRepo: projects, maxDepth: 3}
+	return s, repo, projects
+}
+
+// addTodo stores a todo in the test workspace
+func addTodo(t *testing.T, repo *fakeTodoRepository, todo models.Todo) *models.Todo {
+	t.Helper()
+	todo.UserID = testWorkspace.UserID
+	if todo.Title == "" {
+		todo.Title = "Test todo"
+	}
+	if todo.Status == "" {
+		todo.Status = models.TodoStatusTodo
+	}
+	todo.Completed = todo.Status == models.TodoStatusDone
+	require.NoError(t, repo.Create(&todo))
+	return &todo
+}
+
+// update sends the stored todo back with changes, as a client replacing it would
+func update(t *testing.T, s *todoService, repo *fakeTodoRepository, id uint, change func(*models.Todo)) (*models.Todo, error) {
+	t.Helper()
+	todo, err := repo.GetByID(testWorkspace, id)
+	require.NoError(t, err)
+	change(todo)
+	return todo, s.UpdateTodo(testWorkspace, todo)
+}
+
+func TestTodoTransitions(t *testing.T) {
+	testCases := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{from: models.TodoStatusTodo, to: models.TodoStatusInProgress, allowed: true},
+		{from: models.TodoStatusTodo, to: models.TodoStatusBlocked, allowed: true},
+		{from: models.TodoStatusTodo, to: models.TodoStatusDone, allowed: true},
+		{from: models.TodoStatusTodo, to: models.TodoStatusCancelled, allowed: true},
+		{from: models.TodoStatusInProgress, to: models.TodoStatusTodo, allowed: true},
+		{from: models.TodoStatusInProgress, to: models.TodoStatusDone, allowed: true},
+		{from: models.TodoStatusBlocked, to: models.TodoStatusInProgress, allowed: true},
+		{from: models.TodoStatusBlocked, to: models.TodoStatusDone, allowed: false},
+		{from: models.TodoStatusDone, to: models.TodoStatusTodo, allowed: true},
+		{from: models.TodoStatusDone, to: models.TodoStatusBlocked, allowed: false},
+		{from: models.TodoStatusDone, to: models.TodoStatusCancelled, allowed: false},
+		{from: models.TodoStatusCancelled, to: models.TodoStatusTodo, allowed: true},
+		{from: models.TodoStatusCancelled, to: models.TodoStatusInProgress, allowed: false},
+		{from: models.TodoStatusCancelled, to: models.TodoStatusDone, allowed: false},
+		{from: models.TodoStatusBlocked, to: models.TodoStatusBlocked, allowed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.from+" to "+tc.to, func(t *testing.T) {
+			s, repo, _ := testTodos()
+			todo := addTodo(t, repo, models.Todo{Status: tc.from})
+
+			_, err := update(t, s, repo, todo.ID, func(todo *models.Todo) { todo.Status = tc.to })
+
+			stored, _ := repo.GetByID(testWorkspace, todo.ID)
+			if tc.allowed {
+				require.NoError(t, err)
+				assert.Equal(t, tc.to, stored.Status)
+				return
+			}
+			var statusErr *errors.TodoStatusError
+			require.True(t, errors.As(err, &statusErr), "%v", err)
+			assert.Equal(t, tc.from, statusErr.From)
+			assert.Equal(t, tc.to, statusErr.To)
+			assert.Equal(t, tc.from, stored.Status)
+		})
+	}
+}
+
+func TestTodoCompletedFollowsStatus(t *testing.T) {
+	testCases := []struct {
+		name              string
+		from              string
+		status            string
+		completed         bool
+		expectedStatus    string
+		expectedCompleted bool
+	}{
+		{name: "Completed Marks Done", from: models.TodoStatusInProgress, completed: true, expectedStatus: models.TodoStatusDone, expectedCompleted: true},
+		{name: "Not Completed Reopens", from: models.TodoStatusDone, completed: false, expectedStatus: models.TodoStatusTodo},
+		{name: "Not Completed Keeps Status", from: models.TodoStatusBlocked, completed: false, expectedStatus: models.TodoStatusBlocked},
+		{name: "Status Wins Over Completed", from: models.TodoStatusTodo, status: models.TodoStatusDone, completed: false, expectedStatus: models.TodoStatusDone, expectedCompleted: true},
+		{name: "Cancelled Is Not Completed", from: models.TodoStatusTodo, status: models.TodoStatusCancelled, completed: true, expectedStatus: models.TodoStatusCancelled},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, repo, _ := testTodos()
+			todo := addTodo(t, repo, models.Todo{Status: tc.from})
+
+			updated, err := update(t, s, repo, todo.ID, func(todo *models.Todo) {
+				todo.Status = tc.status
+				todo.Completed = tc.completed
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, updated.Status)
+			assert.Equal(t, tc.expectedCompleted, updated.Completed)
+
+			stored, _ := repo.GetByID(testWorkspace, todo.ID)
+			assert.Equal(t, tc.expectedStatus, stored.Status)
+			assert.Equal(t, tc.expectedCompleted, stored.Completed)
+		})
+	}
+}
+
+func TestTodoCompletedAt(t *testing.T) {
+	s, repo, _ := testTodos()
+	todo := addTodo(t, repo, models.Todo{})
+
+	before := time.Now()
+	updated, err := update(t, s, repo, todo.ID, func(todo *models.Todo) { todo.Status = models.TodoStatusDone })
+	require.NoError(t, err)
+	require.NotNil(t, updated.CompletedAt)
+	assert.False(t, updated.CompletedAt.Before(before))
+	completedAt := *updated.CompletedAt
+
+	// Staying done keeps the time it was completed, even if the client
+	// sends another one
+	updated, err = update(t, s, repo, todo.ID, func(todo *models.Todo) {
+		todo.Title = "Renamed todo"
+		later := completedAt.Add(time.Hour)
+		todo.CompletedAt = &later
+	})
+	require.NoError(t, err)
+	require.NotNil(t, updated.CompletedAt)
+	assert.True(t, completedAt.Equal(*updated.CompletedAt))
+
+	updated, err = update(t, s, repo, todo.ID, func(todo *models.Todo) { todo.Status = models.TodoStatusInProgress })
+	require.NoError(t, err)
+	assert.Nil(t, updated.CompletedAt)
+	stored, _ := repo.GetByID(testWorkspace, todo.ID)
+	assert.Nil(t, stored.CompletedAt)
+}
+
+func TestCreateTodoStatus(t *testing.T) {
+	s, _, _ := testTodos()
+
+	todo := &models.Todo{Title: "Already done", Completed: true}
+	require.NoError(t, s.CreateTodo(testWorkspace, todo))
+	assert.Equal(t, models.TodoStatusDone, todo.Status)
+	assert.NotNil(t, todo.CompletedAt)
+
+	todo = &models.Todo{Title: "Started", Status: models.TodoStatusInProgress, Completed: true}
+	require.NoError(t, s.CreateTodo(testWorkspace, todo))
+	assert.Equal(t, models.TodoStatusInProgress, todo.Status)
+	assert.False(t, todo.Completed)
+	assert.Nil(t, todo.CompletedAt)
+}
+
+func TestCompletingRecurringTodoInArchivedProject(t *testing.T) {
+	due := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		archived     bool
+		expectedNext bool
+	}{
+		{name: "Open Project", archived: false, expectedNext: true},
+		{name: "Archived Project", archived: true, expectedNext: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, repo, projects := testTodos()
+			projects.projects[1] = &models.Project{ID: 1, UserID: testWorkspace.UserID, Name: "Garden", Archived: tc.archived}
+			projectID := uint(1)
+			rule := "FREQ=WEEKLY"
+			todo := addTodo(t, repo, models.Todo{Title: "Water plants", DueAt: &due, Recurrence: &rule, ProjectID: &projectID})
+
+			updated, err := update(t, s, repo, todo.ID, func(todo *models.Todo) { todo.Status = models.TodoStatusDone })
+			require.NoError(t, err)
+
+			stored, _ := repo.GetByID(testWorkspace, todo.ID)
+			if !tc.expectedNext {
+				assert.Nil(t, updated.Next)
+				assert.Len(t, repo.todos, 1)
+				assert.Equal(t, &rule, stored.Recurrence)
+				return
+			}
+			require.NotNil(t, updated.Next)
+			assert.Len(t, repo.todos, 2)
+			assert.Equal(t, &projectID, updated.Next.ProjectID)
+			assert.Nil(t, stored.Recurrence)
+		})
+	}
+}
+
+// addChain stores n todos, each a subtask of the one before, and returns
+// their IDs from the top
+func addChain(t *testing.T, repo *fakeTodoRepository, n int) []uint {
+	t.Helper()
+	var ids []uint
+	var parentID *uint
+	for i := 0; i < n; i++ {
+		todo := addTodo(t, repo, models.Todo{ParentID: parentID})
+		ids = append(ids, todo.ID)
+		parentID = &todo.ID
+	}
+	return ids
+}
+
+func TestSetParent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		chain    int
+		parent   int
+		height   int
+		expected error
+	}{
+		{name: "Self", chain: 1, parent: 0, height: -1, expected: errors.ErrTodoCycle},
+		{name: "Direct Subtask", chain: 2, parent: 1, height: -1, expected: errors.ErrTodoCycle},
+		{name: "Nested Subtask", chain: 3, parent: 2, height: -1, expected: errors.ErrTodoCycle},
+		{name: "At Max Depth", chain: 2, parent: 1, height: 1},
+		{name: "Over Max Depth", chain: 3, parent: 2, height: 1, expected: errors.ErrTodoTooDeep},
+		{name: "Over Max Depth With Subtasks", chain: 2, parent: 1, height: 2, expected: errors.ErrTodoTooDeep},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, repo, _ := testTodos()
+			chain := addChain(t, repo, tc.chain)
+			// The top of the chain moves below one of its own subtasks
+			// unless height gives the levels of subtasks of another todo
+			// to move
+			id := chain[0]
+			if tc.height >= 0 {
+				id = addChain(t, repo, tc.height+1)[0]
+			}
+			parentID := chain[tc.parent]
+
+			_, err := s.SetParent(testWorkspace, id, &parentID)
+			stored, _ := repo.GetByID(testWorkspace, id)
+			if tc.expected != nil {
+				assert.True(t, errors.Is(err, tc.expected), "%v", err)
+				assert.NotEqual(t, &parentID, stored.ParentID)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &parentID, stored.ParentID)
+		})
+	}
+}
+
+func TestCreateSubtaskDepth(t *testing.T) {
+	s, repo, _ := testTodos()
+	// The last todo of the chain is a subtask 3 levels deep
+	chain := addChain(t, repo, 4)
+
+	todo := &models.Todo{Title: "At max depth", ParentID: &chain[2]}
+	require.NoError(t, s.CreateTodo(testWorkspace, todo))
+
+	todo = &models.Todo{Title: "Over max depth", ParentID: &chain[3]}
+	err := s.CreateTodo(testWorkspace, todo)
+	assert.True(t, errors.Is(err, errors.ErrTodoTooDeep), "%v", err)
+
+	missing := chain[3] + 100
+	todo = &models.Todo{Title: "Unknown parent", ParentID: &missing}
+	err = s.CreateTodo(testWorkspace, todo)
+	assert.True(t, errors.Is(err, errors.ErrUnknownParent), "%v", err)
+}
+
+func TestClosingTodoWithSubtasks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   string
+		subtasks []string
+		expected []string
+		err      error
+	}{
+		{
+			name:     "Done",
+			status:   models.TodoStatusDone,
+			subtasks: []string{models.TodoStatusTodo, models.TodoStatusInProgress, models.TodoStatusCancelled},
+			expected: []string{models.TodoStatusDone, models.TodoStatusDone, models.TodoStatusCancelled},
+		},
+		{
+			name:     "Cancelled",
+			status:   models.TodoStatusCancelled,
+			subtasks: []string{models.TodoStatusTodo, models.TodoStatusBlocked, models.TodoStatusDone},
+			expected: []string{models.TodoStatusCancelled, models.TodoStatusCancelled, models.TodoStatusDone},
+		},
+		{
+			name:     "Done With Blocked Subtask",
+			status:   models.TodoStatusDone,
+			subtasks: []string{models.TodoStatusTodo, models.TodoStatusBlocked},
+			expected: []string{models.TodoStatusTodo, models.TodoStatusBlocked},
+			err:      errors.ErrBlockedSubtask,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, repo, _ := testTodos()
+			todo := addTodo(t, repo, models.Todo{})
+			// The last subtask is nested below the one before it
+			var ids []uint
+			parentID := &todo.ID
+			for i, status := range tc.subtasks {
+				subtask := addTodo(t, repo, models.Todo{ParentID: parentID, Status: status})
+				ids = append(ids, subtask.ID)
+				if i == len(tc.subtasks)-2 {
+					parentID = &subtask.ID
+				}
+			}
+
+			_, err := update(t, s, repo, todo.ID, func(todo *models.Todo) { todo.Status = tc.status })
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), "%v", err)
+				stored, _ := repo.GetByID(testWorkspace, todo.ID)
+				assert.Equal(t, models.TodoStatusTodo, stored.Status)
+			} else {
+				require.NoError(t, err)
+			}
+			for i, id := range ids {
+				stored, _ := repo.GetByID(testWorkspace, id)
+				assert.Equal(t, tc.expected[i], stored.Status, "subtask %d", i)
+				assert.Equal(t, tc.expected[i] == models.TodoStatusDone, stored.Completed, "subtask %d", i)
+			}
+		})
+	}
+}
+
+func TestTodoProgress(t *testing.T) {
+	s, repo, _ := testTodos()
+	todo := addTodo(t, repo, models.Todo{})
+	child := addTodo(t, repo, models.Todo{ParentID: &todo.ID, Status: models.TodoStatusDone})
+	addTodo(t, repo, models.Todo{ParentID: &todo.ID, Status: models.TodoStatusCancelled})
+	addTodo(t, repo, models.Todo{ParentID: &child.ID, Status: models.TodoStatusInProgress})
+	addTodo(t, repo, models.Todo{ParentID: &child.ID, Status: models.TodoStatusDone})
+	addTodo(t, repo, models.Todo{ParentID: &child.ID, Status: models.TodoStatusBlocked})
+
+	found, err := s.GetTodoByID(testWorkspace, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.TodoProgress{Done: 2, Total: 4}, found.Progress)
+
+	tree, err := s.GetSubtree(testWorkspace, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.TodoProgress{Done: 2, Total: 4}, tree.Progress)
+	require.Len(t, tree.Subtasks, 2)
+	assert.Equal(t, &models.TodoProgress{Done: 1, Total: 3}, tree.Subtasks[0].Progress)
+	assert.Equal(t, &models.TodoProgress{}, tree.Subtasks[1].Progress)
+
+	leaf, err := s.GetTodoByID(testWorkspace, tree.Subtasks[0].Subtasks[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.TodoProgress{}, leaf.Progress)
+}
+
+func TestDeleteTodoWithSubtasks(t *testing.T) {
+	s, repo, _ := testTodos()
+	chain := addChain(t, repo, 3)
+	other := addTodo(t, repo, models.Todo{})
+
+	require.NoError(t, s.DeleteTodo(testWorkspace, chain[0]))
+	assert.Len(t, repo.todos, 1)
+	assert.Contains(t, repo.todos, other.ID)
+
+	err := s.DeleteTodo(testWorkspace, chain[0])
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound), "%v", err)
+}
+
+func TestCompletingRecurringTodo(t *testing.T) {
+	s, repo, _ := testTodos()
+	rule := "FREQ=WEEKLY"
+	start := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	label := models.Label{ID: 1, UserID: testWorkspace.UserID, Name: "home"}
+	todo := addTodo(t, repo, models.Todo{
+		Title:                "Water plants",
+		Priority:             models.TodoPriorityHigh,
+		StartAt:              &start,
+		DueAt:                &due,
+		Recurrence:           &rule,
+		RecurrenceStart:      &due,
+		RecurrenceExceptions: []string{"2024-03-11"},
+		Labels:               []models.Label{label},
+	})
+
+	updated, err := update(t, s, repo, todo.ID, func(todo *models.Todo) {
+		todo.Status = models.TodoStatusDone
+		// Omitted like a client that does not know about recurrence would
+		todo.Recurrence = nil
+		todo.RecurrenceExceptions = nil
+	})
+	require.NoError(t, err)
+
+	// The skipped date is passed over
+	next := updated.Next
+	require.NotNil(t, next)
+	nextDue := time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)
+	assert.True(t, nextDue.Equal(*next.DueAt), "%v", next.DueAt)
+	assert.True(t, nextDue.Add(-time.Hour).Equal(*next.StartAt), "%v", next.StartAt)
+	assert.Equal(t, models.TodoStatusTodo, next.Status)
+	assert.Equal(t, "Water plants", next.Title)
+	assert.Equal(t, models.TodoPriorityHigh, next.Priority)
+	assert.Equal(t, []models.Label{label}, next.Labels)
+
+	// The next todo takes the rule over with its series
+	stored, err := repo.GetByID(testWorkspace, next.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &rule, stored.Recurrence)
+	assert.True(t, due.Equal(*stored.RecurrenceStart))
+	assert.Equal(t, []string{"2024-03-11"}, stored.RecurrenceExceptions)
+
+	stored, err = repo.GetByID(testWorkspace, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TodoStatusDone, stored.Status)
+	assert.Nil(t, stored.Recurrence)
+	assert.Nil(t, stored.RecurrenceStart)
+	assert.Empty(t, stored.RecurrenceExceptions)
+
+	// Completing the next todo continues the series
+	updated, err = update(t, s, repo, next.ID, func(todo *models.Todo) { todo.Status = models.TodoStatusDone })
+	require.NoError(t, err)
+	require.NotNil(t, updated.Next)
+	assert.True(t, nextDue.AddDate(0, 0, 7).Equal(*updated.Next.DueAt), "%v", updated.Next.DueAt)
+	assert.Len(t, repo.todos, 3)
+}
+
+func TestCompletingRecurringTodoWithoutDates(t *testing.T) {
+	s, repo, _ := testTodos()
+	rule := "FREQ=WEEKLY"
+	due := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	todo := addTodo(t, repo, models.Todo{Title: "Water plants", DueAt: &due, TimeZone: "Europe/Berlin", Recurrence: &rule, RecurrenceStart: &due})
+
+	// A client that predates statuses and recurrence only sends the title
+	// and completed
+	updated, err := update(t, s, repo, todo.ID, func(todo *models.Todo) {
+		*todo = models.Todo{ID: todo.ID, Title: todo.Title, Completed: true}
+	})
+	require.NoError(t, err)
+	require.NotNil(t, updated.Next)
+	assert.True(t, due.AddDate(0, 0, 7).Equal(*updated.Next.DueAt), "%v", updated.Next.DueAt)
+	assert.Equal(t, "Europe/Berlin", updated.Next.TimeZone)
+
+	stored, _ := repo.GetByID(testWorkspace, todo.ID)
+	assert.Equal(t, models.TodoStatusDone, stored.Status)
+	assert.True(t, due.Equal(*stored.DueAt))
+	assert.Nil(t, stored.Recurrence)
+}
+
+func TestUpdateTodoRecurrence(t *testing.T) {
+	rule := "FREQ=WEEKLY"
+	daily := "FREQ=DAILY"
+	empty := ""
+	due := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	later := due.AddDate(0, 0, 1)
+
+	testCases := []struct {
+		name               string
+		recurrence         *string
+		exceptions         []string
+		expected           *string
+		expectedStart      *time.Time
+		expectedExceptions []string
+	}{
+		{name: "Omitted", expected: &rule, expectedStart: &due, expectedExceptions: []string{"2024-03-11"}},
+		{name: "Same Rule", recurrence: &rule, expected: &rule, expectedStart: &due, expectedExceptions: []string{"2024-03-11"}},
+		{name: "Other Rule", recurrence: &daily, exceptions: []string{}, expected: &daily, expectedStart: &later},
+		{name: "Empty", recurrence: &empty},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, repo, _ := testTodos()
+			todo := addTodo(t, repo, models.Todo{DueAt: &due, Recurrence: &rule, RecurrenceStart: &due, RecurrenceExceptions: []string{"2024-03-11"}})
+
+			_, err := update(t, s, repo, todo.ID, func(todo *models.Todo) {
+				todo.DueAt = &later
+				todo.Recurrence = tc.recurrence
+				todo.RecurrenceExceptions = tc.exceptions
+			})
+			require.NoError(t, err)
+
+			stored, _ := repo.GetByID(testWorkspace, todo.ID)
+			assert.Equal(t, tc.expected, stored.Recurrence)
+			assert.Equal(t, tc.expectedStart, stored.RecurrenceStart)
+			assert.Equal(t, tc.expectedExceptions, stored.RecurrenceExceptions)
+		})
+	}
+}
+
+func TestSkipOccurrence(t *testing.T) {
+	s, repo, _ := testTodos()
+	rule := "FREQ=DAILY;COUNT=3"
+	due := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	todo := addTodo(t, repo, models.Todo{DueAt: &due, Recurrence: &rule, RecurrenceStart: &due})
+
+	skipped, err := s.SkipOccurrence(testWorkspace, todo.ID)
+	require.NoError(t, err)
+	assert.True(t, due.AddDate(0, 0, 1).Equal(*skipped.DueAt), "%v", skipped.DueAt)
+	assert.Equal(t, []string{"2024-03-04"}, skipped.RecurrenceExceptions)
+
+	// Skipped dates stay skipped, and the series ends after COUNT
+	// occurrences
+	occurrences, err := s.ListOccurrences(testWorkspace, todo.ID, 5)
+	require.NoError(t, err)
+	require.Len(t, occurrences, 1)
+	assert.True(t, due.AddDate(0, 0, 2).Equal(*occurrences[0].DueAt), "%v", occurrences[0].DueAt)
+
+	_, err = s.SkipOccurrence(testWorkspace, todo.ID)
+	require.NoError(t, err)
+	_, err = s.SkipOccurrence(testWorkspace, todo.ID)
+	assert.True(t, errors.Is(err, errors.ErrNoOccurrences), "%v", err)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"time"
+
+	"github.com/netf/gofiber-boilerplate/internal/errors"
+	"github.com/netf/gofiber-boilerplate/internal/models"
+	"github.com/netf/gofiber-boilerplate/internal/repositories"
+)
+
+// UserAdminService lets operators manage user accounts
+type UserAdminService interface {
+	ListUsers(query string, page, pageSize int) ([]models.User, int64, error)
+	GetUser(userID uint) (*models.User, error)
+	DisableUser(userID uint) error
+	EnableUser(userID uint) error
+	ForcePasswordReset(userID uint) error
+	SetRoles(userID uint, roleNames []string) (*models.User, error)
+	DeleteUser(userID uint) error
+}
+
+type userAdminService struct {
+	authRepo    repositories.AuthRepository
+	roleRepo    repositories.RoleRepository
+	authService AuthService
+}
+
+// NewUserAdminService creates a new instance of UserAdminService
+func NewUserAdminService(authRepo repositories.AuthRepository, roleRepo repositories.RoleRepository, authService AuthService) UserAdminService {
+	return &userAdminService{authRepo: authRepo, roleRepo: roleRepo, authService: authService}
+}
+
+// ListUsers returns a page of users whose name or email contains query
+func (s *userAdminService) ListUsers(query string, page, pageSize int) ([]models.User, int64, error) {
+	return s.authRepo.ListUsers(query, page, pageSize)
+}
+
+func (s *userAdminService) GetUser(userID uint) (*models.User, error) {
+	return s.authRepo.FindUserByID(userID)
+}
+
+// DisableUser blocks the user from logging in and revokes all of their tokens
+// and sessions. Tokens stay revoked if the user is enabled again.
+func (s *userAdminService) DisableUser(userID uint) error {
+	if _, err := s.authRepo.FindUserByID(userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.authRepo.SetDisabled(userID, &now); err != nil {
+		return err
+	}
+	return s.authService.LogoutAll(userID)
+}
+
+func (s *userAdminService) EnableUser(userID uint) error {
+	if _, err := s.authRepo.FindUserByID(userID); err != nil {
+		return err
+	}
+	return s.authRepo.SetDisabled(userID, nil)
+}
+
+// ForcePasswordReset logs the user out everywhere, blocks logins until the
+// password has been reset and emails the user a reset link
+func (s *userAdminService) ForcePasswordReset(userID uint) error {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authRepo.SetPasswordResetRequired(userID, true); err != nil {
+		return err
+	}
+	if err := s.authService.LogoutAll(userID); err != nil {
+		return err
+	}
+	return s.authService.RequestPasswordReset(user.Email)
+}
+
+// SetRoles replaces the user's roles and, as DisableUser does, revokes all
+// of their tokens and sessions, so the new roles apply from the next login
+func (s *userAdminService) SetRoles(userID uint, roleNames []string) (*models.User, error) {
+	user, err := s.authRepo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.FindByNames(roleNames)
+	if err != nil {
+		if errors.Is(err, errors.ErrResourceNotFound) {
+			return nil, errors.ErrInvalidInput
+		}
+		return nil, err
+	}
+
+	if err := s.authRepo.ReplaceRoles(user, roles); err != nil {
+		return nil, err
+	}
+	if err := s.authService.LogoutAll(userID); err != nil {
+		return nil, err
+	}
+	return s.authRepo.FindUserByID(userID)
+}
+
+// DeleteUser soft-deletes the user and revokes all of their tokens and sessions
+func (s *userAdminService) DeleteUser(userID uint) error {
+	if err := s.authRepo.DeleteUser(userID); err != nil {
+		return err
+	}
+	return s.authService.LogoutAll(userID)
+}